@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/johnkerl/miller/v6/pkg/cli"
 	"github.com/johnkerl/miller/v6/pkg/climain"
@@ -25,8 +26,8 @@ import (
 )
 
 // SaveOutput opens a save file dialog and saves the content to the selected file
-func (a *App) SaveOutput(content string) error {
-	defer RecoverFromPanic("SaveOutput")
+func (a *App) SaveOutput(content string) (err error) {
+	defer RecoverToError("SaveOutput", &err)
 	
 	LogInfo("SaveOutput called", nil)
 	
@@ -54,8 +55,8 @@ func (a *App) SaveOutput(content string) error {
 }
 
 // SelectInputFile opens a file dialog to select an input file
-func (a *App) SelectInputFile() (string, error) {
-	defer RecoverFromPanic("SelectInputFile")
+func (a *App) SelectInputFile() (_ string, err error) {
+	defer RecoverToError("SelectInputFile", &err)
 	
 	LogInfo("SelectInputFile called", nil)
 	
@@ -77,11 +78,35 @@ func (a *App) SelectInputFile() (string, error) {
 // App struct
 type App struct {
 	ctx context.Context
+
+	// executor admits every Miller execution, previews and runs alike
+	executor *executor
+
+	// cache holds the output of recent previews
+	cache *previewCache
+
+	// snapshots hold the record stream between verbs for the input last previewed
+	snapshots *snapshotStore
+
+	// jobs are the background runs started with StartJob
+	jobs jobTable
+
+	// runMu guards runCancel, the cancel func of the RunToFile in flight
+	runMu     sync.Mutex
+	runCancel context.CancelFunc
 }
 
+// ErrPreviewCancelled is returned by a preview that was superseded by a newer one,
+// whether it was running or still waiting for its turn
+var ErrPreviewCancelled = errors.New("preview cancelled")
+
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{}
+	return &App{
+		executor:  newExecutor(defaultExecutionSettings),
+		cache:     newPreviewCache(defaultPreviewCacheBudget),
+		snapshots: newSnapshotStore(defaultSnapshotBudget),
+	}
 }
 
 // startup is called when the app starts. The context is saved
@@ -93,6 +118,21 @@ func (a *App) startup(ctx context.Context) {
 	LogInfo("App startup completed", nil)
 }
 
+// beginPreview waits for the executor to admit a new preview, and returns its
+// context. Under the latest-wins policy it cancels the previews still running
+// or waiting first. The caller must call the returned func when done.
+func (a *App) beginPreview() (context.Context, func(), error) {
+	return a.executor.acquire(a.parentContext(), true)
+}
+
+// parentContext is the context executions derive theirs from
+func (a *App) parentContext() context.Context {
+	if a.ctx == nil {
+		return context.Background()
+	}
+	return a.ctx
+}
+
 // VerbConfig holds the configuration for a single verb
 type VerbConfig struct {
 	Value   string `json:"value"`
@@ -101,8 +141,10 @@ type VerbConfig struct {
 
 // Config holds the application state
 type Config struct {
-	InputPath      string       `json:"inputPath"`
+	InputPath      string       `json:"inputPath"`            // file path, or the input text itself in text mode
+	InputPaths     []string     `json:"inputPaths,omitempty"` // all input files in order, in file mode; overrides InputPath
 	InputMode      string       `json:"inputMode"`
+	Compression    string       `json:"compression,omitempty"` // gzip, bzip2, zstd or zlib; detected from the files when empty
 	InputFormat    string       `json:"inputFormat"`
 	Ragged         bool         `json:"ragged"`
 	Headerless     bool         `json:"headerless"`
@@ -110,31 +152,20 @@ type Config struct {
 	OutputFormat   string       `json:"outputFormat"`
 	Verbs          []VerbConfig `json:"verbs"`
 	Options        string       `json:"options"`
+
+	// Sampling picks the input records previews run on; nil runs them on all
+	Sampling *SamplingConfig `json:"sampling,omitempty"`
 }
 
-// quoteIfNeeded adds quotes around a token if it contains spaces or special characters
-// Prefers single quotes to avoid shell variable expansion of $field references
-func quoteIfNeeded(token string) string {
-	// Check if quoting is needed
-	needsQuoting := strings.Contains(token, " ") || 
-	                strings.Contains(token, "\"") || 
-	                strings.Contains(token, "\\") ||
-	                strings.Contains(token, "$")
-	
-	if !needsQuoting {
-		// No quoting needed
-		return token
+// inputFiles returns the files to read in file mode, in order
+func (c Config) inputFiles() []string {
+	if len(c.InputPaths) > 0 {
+		return c.InputPaths
 	}
-	
-	// If the token contains single quotes, we must use double quotes and escape
-	if strings.Contains(token, "'") {
-		escaped := strings.ReplaceAll(token, "\\", "\\\\")
-		escaped = strings.ReplaceAll(escaped, "\"", "\\\"")
-		return "\"" + escaped + "\""
+	if c.InputPath != "" {
+		return []string{c.InputPath}
 	}
-	
-	// Otherwise, prefer single quotes to prevent shell variable expansion
-	return "'" + token + "'"
+	return nil
 }
 
 // joinVerbTokens joins verb tokens with proper quoting
@@ -146,276 +177,6 @@ func joinVerbTokens(tokens []string) string {
 	return strings.Join(quoted, " ")
 }
 
-// millerVerbs is the complete list of Miller verbs as of Miller v6
-var millerVerbs = map[string]bool{
-	"altkv":                   true,
-	"bar":                     true,
-	"bootstrap":               true,
-	"case":                    true,
-	"cat":                     true,
-	"check":                   true,
-	"clean-whitespace":        true,
-	"count-distinct":          true,
-	"count":                   true,
-	"count-similar":           true,
-	"cut":                     true,
-	"decimate":                true,
-	"fill-down":               true,
-	"fill-empty":              true,
-	"filter":                  true,
-	"flatten":                 true,
-	"format-values":           true,
-	"fraction":                true,
-	"gap":                     true,
-	"grep":                    true,
-	"group-by":                true,
-	"group-like":              true,
-	"gsub":                    true,
-	"having-fields":           true,
-	"head":                    true,
-	"histogram":               true,
-	"json-parse":              true,
-	"json-stringify":          true,
-	"join":                    true,
-	"label":                   true,
-	"latin1-to-utf8":          true,
-	"least-frequent":          true,
-	"merge-fields":            true,
-	"most-frequent":           true,
-	"nest":                    true,
-	"nothing":                 true,
-	"put":                     true,
-	"regularize":              true,
-	"remove-empty-columns":    true,
-	"rename":                  true,
-	"reorder":                 true,
-	"repeat":                  true,
-	"reshape":                 true,
-	"sample":                  true,
-	"sec2gmtdate":             true,
-	"sec2gmt":                 true,
-	"seqgen":                  true,
-	"shuffle":                 true,
-	"skip-trivial-records":    true,
-	"sort":                    true,
-	"sort-within-records":     true,
-	"split":                   true,
-	"ssub":                    true,
-	"stats1":                  true,
-	"stats2":                  true,
-	"step":                    true,
-	"sub":                     true,
-	"summary":                 true,
-	"tac":                     true,
-	"tail":                    true,
-	"tee":                     true,
-	"template":                true,
-	"top":                     true,
-	"utf8-to-latin1":          true,
-	"unflatten":               true,
-	"uniq":                    true,
-	"unspace":                 true,
-	"unsparsify":              true,
-}
-
-// flagsTakingArguments lists flags that require an argument
-var flagsTakingArguments = map[string]bool{
-	"--ifs":              true,
-	"--ofs":              true,
-	"--irs":              true,
-	"--ors":              true,
-	"--ips":              true,
-	"--ops":              true,
-	"--jvstack":          true,
-	"--nr-progress-mod":  true,
-	"--ofmt":             true,
-	"--load":             true,
-	"--mload":            true,
-	"--seed":             true,
-}
-
-// isMillerVerb checks if a token is a known Miller verb
-func isMillerVerb(token string) bool {
-	return millerVerbs[token]
-}
-
-
-// ParseCommand parses an mlr command string and returns a Config
-// Expected format: mlr [--flags] {verb} [-options ...] [then {verb} ...] {filenames}
-func (a *App) ParseCommand(command string) (Config, error) {
-	defer RecoverFromPanic("ParseCommand")
-	
-	LogInfo("Parsing command", logrus.Fields{"command": command})
-	
-	var config Config
-	config.InputMode = "text"
-	config.FieldSeparator = ","
-	
-	// Parse the command string into tokens
-	tokens, err := shellwords.Parse(command)
-	if err != nil {
-		LogError(err, "Failed to parse command string", logrus.Fields{"command": command})
-		return config, fmt.Errorf("error parsing command: %v", err)
-	}
-	
-	if len(tokens) == 0 {
-		return config, fmt.Errorf("empty command")
-	}
-	
-	// Skip "mlr" if it's the first token
-	if tokens[0] == "mlr" {
-		tokens = tokens[1:]
-	}
-	
-	if len(tokens) == 0 {
-		return config, fmt.Errorf("empty command after removing 'mlr'")
-	}
-	
-	var allFlags []string
-	var verbs []VerbConfig
-	var currentVerb []string
-	
-	i := 0
-	
-	// Phase 1: Collect all leading flags that start with --
-	// Handle flags that take arguments (e.g., --ifs tab)
-	for i < len(tokens) && strings.HasPrefix(tokens[i], "--") {
-		flag := tokens[i]
-		allFlags = append(allFlags, flag)
-		i++
-		
-		// If this flag takes an argument, consume the next token as its argument
-		if flagsTakingArguments[flag] && i < len(tokens) {
-			// The next token is the flag's argument, not a verb
-			allFlags = append(allFlags, tokens[i])
-			i++
-		}
-	}
-	
-	// Phase 2: Collect verbs (everything until we hit a filename)
-	// Verbs are separated by "then" and must be known Miller verbs
-	for i < len(tokens) {
-		token := tokens[i]
-		
-		if token == "then" {
-			// Save current verb if any
-			if len(currentVerb) > 0 {
-				verbs = append(verbs, VerbConfig{
-					Value:   joinVerbTokens(currentVerb),
-					Enabled: true,
-				})
-				currentVerb = nil
-			}
-			i++
-		} else if len(currentVerb) == 0 && isMillerVerb(token) {
-			// This is the start of a new verb
-			currentVerb = append(currentVerb, token)
-			i++
-		} else if len(currentVerb) > 0 {
-			// We're already in a verb, add this token to it
-			currentVerb = append(currentVerb, token)
-			i++
-		} else {
-			// Not a verb and not in a verb - might be a filename or unknown token
-			// Treat as potential filename
-			break
-		}
-	}
-	
-	// Phase 3: Check if the last verb ends with file path(s)
-	// File paths typically contain "/" and don't start with "$" (which would be a Miller variable)
-	if len(currentVerb) > 0 {
-		// Work backwards to find where filenames start
-		lastVerbTokenIdx := len(currentVerb) - 1
-		
-		// Check from the end backwards for file paths
-		for lastVerbTokenIdx >= 0 {
-			token := currentVerb[lastVerbTokenIdx]
-			// If it looks like a file path (contains / but isn't a variable)
-			if strings.Contains(token, "/") && !strings.HasPrefix(token, "$") {
-				// This and everything after could be file paths
-				lastVerbTokenIdx--
-			} else {
-				// This looks like a verb or option, stop
-				break
-			}
-		}
-		
-		// If we found file paths at the end
-		if lastVerbTokenIdx < len(currentVerb)-1 {
-			// Extract file paths
-			filePaths := currentVerb[lastVerbTokenIdx+1:]
-			// For now, just use the first file path
-			if len(filePaths) > 0 {
-				config.InputPath = filePaths[0]
-				config.InputMode = "file"
-			}
-			
-			// Keep the verb part (if any)
-			if lastVerbTokenIdx >= 0 {
-				currentVerb = currentVerb[:lastVerbTokenIdx+1]
-			} else {
-				currentVerb = nil
-			}
-		}
-		
-		// Add the verb if there's still content
-		if len(currentVerb) > 0 {
-			verbs = append(verbs, VerbConfig{
-				Value:   joinVerbTokens(currentVerb),
-				Enabled: true,
-			})
-		}
-	}
-	
-	config.Verbs = verbs
-	
-	// Phase 4: Extract UI-specific flags from allFlags
-	// Only extract the few flags that map to UI fields
-	var otherFlags []string
-	
-	for _, flag := range allFlags {
-		// Input format flags
-		if flag == "--icsv" || flag == "--itsv" || flag == "--ijson" || flag == "--ijsonl" {
-			config.InputFormat = flag
-			continue
-		}
-		
-		// Output format flags
-		if flag == "--ocsv" || flag == "--otsv" || flag == "--ojson" || flag == "--ojsonl" || 
-		   flag == "--opprint" || flag == "--omd" || flag == "--oxtab" {
-			config.OutputFormat = flag
-			continue
-		}
-		
-		// CSV-specific UI flags
-		if flag == "--ragged" || flag == "--allow-ragged-csv-input" {
-			config.Ragged = true
-			continue
-		}
-		if flag == "--headerless-csv-input" {
-			config.Headerless = true
-			continue
-		}
-		
-		// Everything else goes to additional flags
-		otherFlags = append(otherFlags, flag)
-	}
-	
-	config.Options = strings.Join(otherFlags, " ")
-	
-	LogInfo("Command parsed successfully", logrus.Fields{
-		"input_format":  config.InputFormat,
-		"output_format": config.OutputFormat,
-		"verbs_count":   len(config.Verbs),
-		"options":       config.Options,
-		"input_path":    config.InputPath,
-		"input_mode":    config.InputMode,
-	})
-	
-	return config, nil
-}
-
 // getLastStatePath returns the path to the last state file
 func getLastStatePath() string {
 	home, err := os.UserHomeDir()
@@ -426,18 +187,22 @@ func getLastStatePath() string {
 }
 
 // SaveLastState saves the current configuration to the auto-save file
-func (a *App) SaveLastState(config Config) error {
+func (a *App) SaveLastState(config Config) (err error) {
+	defer RecoverToError("SaveLastState", &err)
+	
 	return a.SaveConfig(config, getLastStatePath())
 }
 
 // LoadLastState loads the configuration from the auto-save file
-func (a *App) LoadLastState() (Config, error) {
+func (a *App) LoadLastState() (_ Config, err error) {
+	defer RecoverToError("LoadLastState", &err)
+	
 	return a.LoadConfig(getLastStatePath())
 }
 
 // SaveConfig saves the current configuration to a file
-func (a *App) SaveConfig(config Config, path string) error {
-	defer RecoverFromPanic("SaveConfig")
+func (a *App) SaveConfig(config Config, path string) (err error) {
+	defer RecoverToError("SaveConfig", &err)
 	
 	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
@@ -456,8 +221,8 @@ func (a *App) SaveConfig(config Config, path string) error {
 }
 
 // LoadConfig loads the configuration from a file
-func (a *App) LoadConfig(path string) (Config, error) {
-	defer RecoverFromPanic("LoadConfig")
+func (a *App) LoadConfig(path string) (_ Config, err error) {
+	defer RecoverToError("LoadConfig", &err)
 	
 	var config Config
 	data, err := os.ReadFile(path)
@@ -479,30 +244,79 @@ func (a *App) LoadConfig(path string) (Config, error) {
 
 
 
+// pipelineArgs are the mlr arguments for a pipeline: the main flags, then the
+// words of each enabled verb
+type pipelineArgs struct {
+	mainFlags []string
+	verbs     []verbArgs
+}
+
+// verbArgs are the words of one enabled verb and its index in the verb list
+type verbArgs struct {
+	verbIndex int
+	tokens    []string
+}
+
+// flatten lays the arguments out as mlr takes them, with "then" between verbs
+func (p pipelineArgs) flatten() []string {
+	args := append([]string{}, p.mainFlags...)
+	for i, verb := range p.verbs {
+		if i > 0 {
+			args = append(args, "then")
+		}
+		args = append(args, verb.tokens...)
+	}
+	return args
+}
+
+// verbAt returns the verb that the word at argi of the flattened arguments
+// belongs to
+func (p pipelineArgs) verbAt(argi int) (verbArgs, bool) {
+	start := len(p.mainFlags)
+	for _, verb := range p.verbs {
+		// A verb's words and the "then" after it
+		end := start + len(verb.tokens) + 1
+		if argi >= start && argi < end {
+			return verb, true
+		}
+		start = end
+	}
+	return verbArgs{}, false
+}
+
 // constructArgs helper to build the argument list
-func (a *App) constructArgs(verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string) ([]string, error) {
-	var finalArgs []string
+func (a *App) constructArgs(verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string, compression string) (pipelineArgs, error) {
+	var finalArgs pipelineArgs
 
 	// Add input format first
 	if inputFormat != "" {
-		finalArgs = append(finalArgs, inputFormat)
+		finalArgs.mainFlags = append(finalArgs.mainFlags, canonicalFormatFlag(inputFormat, false))
+	}
+
+	// Decompression of the input files
+	if compression != "" {
+		flag, ok := compressionFlags[compression]
+		if !ok {
+			return finalArgs, &PreviewError{Phase: PhaseMainFlagParse, VerbIndex: -1, Token: compression, Message: "unknown compression"}
+		}
+		finalArgs.mainFlags = append(finalArgs.mainFlags, flag)
 	}
 
 	// CSV/TSV specific options
 	if ragged {
-		finalArgs = append(finalArgs, "--ragged")
+		finalArgs.mainFlags = append(finalArgs.mainFlags, "--ragged")
 	}
 	if headerless {
-		finalArgs = append(finalArgs, "--headerless-csv-input")
+		finalArgs.mainFlags = append(finalArgs.mainFlags, "--headerless-csv-input")
 	}
 	if fieldSeparator != "" && fieldSeparator != "," {
-		finalArgs = append(finalArgs, "--ifs")
-		finalArgs = append(finalArgs, fieldSeparator)
+		finalArgs.mainFlags = append(finalArgs.mainFlags, "--ifs")
+		finalArgs.mainFlags = append(finalArgs.mainFlags, fieldSeparator)
 	}
 
 	// Add output format
 	if outputFormat != "" {
-		finalArgs = append(finalArgs, outputFormat)
+		finalArgs.mainFlags = append(finalArgs.mainFlags, canonicalFormatFlag(outputFormat, true))
 	}
 
 	// Parse options (global flags like --icsv, --opprint)
@@ -510,13 +324,12 @@ func (a *App) constructArgs(verbs []VerbConfig, options string, inputFormat stri
 		tokens, err := shellwords.Parse(options)
 		if err != nil {
 			LogError(err, "Failed to parse options", logrus.Fields{"options": options})
-			return nil, fmt.Errorf("error parsing options: %v", err)
+			return finalArgs, &PreviewError{Phase: PhaseOptionsTokenize, VerbIndex: -1, Token: options, Message: fmt.Sprintf("error parsing options: %v", err)}
 		}
-		finalArgs = append(finalArgs, tokens...)
+		finalArgs.mainFlags = append(finalArgs.mainFlags, tokens...)
 	}
 
-	first := true
-	for _, verb := range verbs {
+	for i, verb := range verbs {
 		if !verb.Enabled {
 			continue
 		}
@@ -524,98 +337,115 @@ func (a *App) constructArgs(verbs []VerbConfig, options string, inputFormat stri
 		tokens, err := shellwords.Parse(verb.Value)
 		if err != nil {
 			LogError(err, "Failed to parse verb", logrus.Fields{"verb": verb.Value})
-			return nil, fmt.Errorf("error parsing verb '%s': %v", verb.Value, err)
-		}
-		
-		if !first {
-			finalArgs = append(finalArgs, "then")
+			return finalArgs, verbError(PhaseVerbTokenize, verbs, i, "", fmt.Sprintf("error parsing verb: %v", err))
 		}
-		finalArgs = append(finalArgs, tokens...)
-		first = false
+		finalArgs.verbs = append(finalArgs.verbs, verbArgs{verbIndex: i, tokens: tokens})
 	}
 	
 	// Log the constructed arguments for debugging
 	LogInfo("Constructed Miller arguments", logrus.Fields{
-		"args": finalArgs,
-		"args_string": strings.Join(finalArgs, " "),
+		"args": finalArgs.flatten(),
+		"args_string": strings.Join(finalArgs.flatten(), " "),
 	})
 	
 	return finalArgs, nil
 }
 
 // GetCommand returns the constructed mlr command string
-func (a *App) GetCommand(verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string, inputMode string, inputPath string) (string, error) {
-	args, err := a.constructArgs(verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat)
+func (a *App) GetCommand(verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string, inputMode string, inputPath string) (_ string, err error) {
+	defer RecoverToError("GetCommand", &err)
+	
+	var inputPaths []string
+	if inputPath != "" {
+		inputPaths = []string{inputPath}
+	}
+	
+	// Reproduce the decompression flag the preview would use; a file that can't
+	// be read yet just gets none
+	compression, _ := inputCompression(Config{InputMode: inputMode, InputPaths: inputPaths})
+	return a.commandString(ShellPOSIX, verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat, compression, inputMode, inputPaths)
+}
+
+// GetConfigCommand returns the mlr command string for config, with all of its
+// input files in order
+func (a *App) GetConfigCommand(config Config) (_ string, err error) {
+	defer RecoverToError("GetConfigCommand", &err)
+	
+	compression, _ := inputCompression(config)
+	return a.commandString(ShellPOSIX, config.Verbs, config.Options, config.InputFormat, config.Ragged, config.Headerless, config.FieldSeparator, config.OutputFormat, compression, config.InputMode, config.inputFiles())
+}
+
+// GetShellCommand returns the mlr command string for config quoted for shell:
+// posix, powershell, cmd or fish
+func (a *App) GetShellCommand(config Config, shell string) (_ string, err error) {
+	defer RecoverToError("GetShellCommand", &err)
+	
+	if shell == "" {
+		shell = ShellPOSIX
+	}
+	if _, ok := shellQuoters[shell]; !ok {
+		return "", fmt.Errorf("unknown shell %q", shell)
+	}
+	compression, _ := inputCompression(config)
+	return a.commandString(shell, config.Verbs, config.Options, config.InputFormat, config.Ragged, config.Headerless, config.FieldSeparator, config.OutputFormat, compression, config.InputMode, config.inputFiles())
+}
+
+// commandString builds the mlr command line for display, quoted for the
+// given shell
+func (a *App) commandString(shell string, verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string, compression string, inputMode string, inputPaths []string) (string, error) {
+	args, err := a.constructArgs(verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat, compression)
 	if err != nil {
 		return "", err
 	}
 
-	// Quote arguments for display if they contain spaces or special characters
-	// Always prefer single quotes to avoid shell evaluation of $field references
-	// Only use double quotes if the argument contains single quotes
-	var displayArgs []string
-	for _, arg := range args {
-		needsQuoting := strings.Contains(arg, " ") || strings.Contains(arg, ";") || 
-		                strings.Contains(arg, "$") || strings.Contains(arg, "\"")
-		hasSingleQuote := strings.Contains(arg, "'")
-		
-		if needsQuoting {
-			if hasSingleQuote {
-				// If it contains single quotes, we need to use double quotes and escape internal quotes
-				escaped := strings.ReplaceAll(arg, "\\", "\\\\")
-				escaped = strings.ReplaceAll(escaped, "\"", "\\\"")
-				displayArgs = append(displayArgs, "\""+escaped+"\"")
-			} else {
-				// Prefer single quotes to prevent shell variable expansion
-				displayArgs = append(displayArgs, "'"+arg+"'")
-			}
-		} else {
-			displayArgs = append(displayArgs, arg)
+	// Quote arguments so that the shell, or ParseCommand, reads them back
+	// unchanged
+	words := []string{"mlr"}
+	for _, arg := range args.flatten() {
+		word, err := quoteArg(shell, arg)
+		if err != nil {
+			return "", err
 		}
+		words = append(words, word)
 	}
 
-	cmdStr := "mlr " + strings.Join(displayArgs, " ")
-
-	if inputMode == "file" && inputPath != "" {
-		// If file mode, append the file path
-		if strings.Contains(inputPath, " ") {
-			cmdStr += fmt.Sprintf(" %q", inputPath)
-		} else {
-			cmdStr += " " + inputPath
+	// In file mode, append the file paths in order; in text mode mlr reads stdin
+	if inputMode == "file" {
+		for _, inputPath := range inputPaths {
+			if inputPath == "" {
+				continue
+			}
+			quoted, err := quotePath(shell, inputPath)
+			if err != nil {
+				return "", err
+			}
+			words = append(words, quoted...)
 		}
-	} else {
-		// If text mode, maybe indicate input comes from stdin?
-		// Or just leave it as is, implying pipe.
-		// Let's prepend "cat input | " for clarity? Or just leave it.
-		// The user request is "what the resulting final mlr command looks like".
-		// Usually one runs `mlr [args] [files...]`.
-		// If no files, it reads from stdin.
 	}
 
-	return cmdStr, nil
+	return strings.Join(words, " "), nil
 }
 
-// Preview executes the mlr transformation using the Miller library directly
-func (a *App) Preview(input string, verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string) (string, error) {
-	defer RecoverFromPanic("Preview")
-	
-	LogInfo("Preview transformation started", logrus.Fields{
-		"input_format": inputFormat,
-		"output_format": outputFormat,
-		"verbs_count": len(verbs),
-		"input_size": len(input),
-	})
-	
+// buildPipeline constructs the mlr arguments and parses them into Miller
+// options and a transformer chain
+func (a *App) buildPipeline(verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string, compression string) (*cli.TOptions, []transformers.IRecordTransformer, error) {
 	// Build the command-line arguments as we would pass to mlr
-	args, err := a.constructArgs(verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat)
+	args, err := a.constructArgs(verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat, compression)
 	if err != nil {
 		LogError(err, "Failed to construct args", nil)
-		return "", err
+		return nil, nil, err
+	}
+
+	// Miller exits the process on some bad arguments instead of returning an
+	// error, so check them where that can't take the app down first
+	if err := checkArgs(args, verbs); err != nil {
+		LogError(err, "Invalid Miller arguments", nil)
+		return nil, nil, err
 	}
 
 	// Miller's ParseCommandLine expects args[0] to be the program name (like os.Args)
 	// Prepend "mlr" to match the expected format
-	argsWithProgramName := append([]string{"mlr"}, args...)
+	argsWithProgramName := append([]string{"mlr"}, args.flatten()...)
 	
 	LogInfo("Calling ParseCommandLine", logrus.Fields{
 		"full_args": argsWithProgramName,
@@ -625,42 +455,108 @@ func (a *App) Preview(input string, verbs []VerbConfig, options string, inputFor
 	mlrOptions, recordTransformers, err := climain.ParseCommandLine(argsWithProgramName)
 	if err != nil {
 		LogError(err, "Failed to parse command", logrus.Fields{"args": argsWithProgramName})
-		return "", fmt.Errorf("error parsing command: %v", err)
+		return nil, nil, blameVerb(args, verbs, fmt.Sprintf("error parsing command: %v", err))
 	}
 
-	// Create a temporary file for the input data since Miller's input readers expect file names
-	tmpFile, err := os.CreateTemp("", "mlr-input-*.txt")
+	return mlrOptions, recordTransformers, nil
+}
+
+// configInput returns the files Miller should read for config. In text mode
+// the input text is served from memory until the returned cleanup func is called.
+func configInput(config Config) ([]string, func(), error) {
+	if config.InputMode == "file" {
+		// With no files Miller would read stdin instead
+		if len(config.inputFiles()) == 0 {
+			return nil, nil, errors.New("no input files")
+		}
+		files, err := expandInputPaths(config.inputFiles())
+		if err != nil {
+			return nil, nil, err
+		}
+		return files, func() {}, nil
+	}
+
+	inputName, closeInput, err := memoryInput(config.InputPath)
 	if err != nil {
-		LogError(err, "Failed to create temp file", nil)
-		return "", fmt.Errorf("error creating temp file: %v", err)
+		return nil, nil, err
 	}
-	tmpFileName := tmpFile.Name()
-	defer os.Remove(tmpFileName)
+	return []string{inputName}, closeInput, nil
+}
 
-	// Write input data to temp file
-	if _, err := tmpFile.WriteString(input); err != nil {
-		tmpFile.Close()
-		LogError(err, "Failed to write to temp file", logrus.Fields{"file": tmpFileName})
-		return "", fmt.Errorf("error writing to temp file: %v", err)
+// Preview executes the mlr transformation using the Miller library directly
+func (a *App) Preview(input string, verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string) (_ string, err error) {
+	defer RecoverToError("Preview", &err)
+	
+	result, err := a.PreviewWithProfile(input, verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat)
+	return result.Output, err
+}
+
+// PreviewWithProfile is Preview returning the profile of each verb alongside
+// the output
+func (a *App) PreviewWithProfile(input string, verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string) (_ ProfiledPreview, err error) {
+	defer RecoverToError("PreviewWithProfile", &err)
+	
+	// The same input and arguments give the same output
+	cacheKey, inputKey := "", ""
+	args, argsErr := a.constructArgs(verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat, "")
+	if argsErr == nil {
+		cacheKey, _ = previewCacheKey(&input, nil, args)
+		inputKey, _ = previewCacheKey(&input, nil, pipelineArgs{})
+	}
+	if result, ok := a.cachedPreviewOutput(cacheKey, "Preview"); ok {
+		return result, nil
+	}
+	
+	// Wait for our turn, superseding previews still running or waiting
+	ctx, cancel, err := a.beginPreview()
+	if err != nil {
+		return ProfiledPreview{}, err
 	}
-	tmpFile.Close()
+	defer cancel()
+	
+	LogInfo("Preview transformation started", logrus.Fields{
+		"input_format": inputFormat,
+		"output_format": outputFormat,
+		"verbs_count": len(verbs),
+		"input_size": len(input),
+	})
+	
+	// Build the Miller options and transformer chain as mlr would
+	mlrOptions, recordTransformers, err := a.buildPipeline(verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat, "")
+	if err != nil {
+		return ProfiledPreview{}, err
+	}
+
+	// Miller's input readers expect file names, so hand the input over through an in-memory pipe
+	inputName, closeInput, err := memoryInput(input)
+	if err != nil {
+		return ProfiledPreview{}, err
+	}
+	defer closeInput()
 
 	// Set up output buffer
 	var outputBuffer bytes.Buffer
 	bufferedOutputStream := bufio.NewWriter(&outputBuffer)
 
-	// Run the Miller transformation
-	err = runMillerTransformation([]string{tmpFileName}, mlrOptions, recordTransformers, bufferedOutputStream)
+	// Run the Miller transformation, from a snapshot if the leading verbs are unchanged
+	profile, err := a.runPreviewPipeline(ctx, "Preview", inputKey, args, []string{inputName}, mlrOptions, recordTransformers, verbs, bufferedOutputStream)
+	if errors.Is(err, ErrPreviewCancelled) {
+		LogInfo("Preview transformation cancelled", nil)
+		return ProfiledPreview{}, err
+	}
 	if err != nil {
 		LogError(err, "Miller transformation failed", nil)
-		return "", err
+		return ProfiledPreview{}, err
 	}
 
 	bufferedOutputStream.Flush()
-	result := outputBuffer.String()
+	result := ProfiledPreview{Output: outputBuffer.String(), Profile: profile}
+	if cacheKey != "" {
+		a.cache.put(cacheKey, result.Output, result.Profile)
+	}
 	
 	LogInfo("Preview transformation completed", logrus.Fields{
-		"output_size": len(result),
+		"output_size": len(result.Output),
 	})
 	
 	return result, nil
@@ -668,88 +564,247 @@ func (a *App) Preview(input string, verbs []VerbConfig, options string, inputFor
 
 // PreviewFile executes the mlr transformation on a file directly
 // This avoids reading the entire file into memory first
-func (a *App) PreviewFile(filePath string, verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string) (string, error) {
-	defer RecoverFromPanic("PreviewFile")
+func (a *App) PreviewFile(filePath string, verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string) (_ string, err error) {
+	defer RecoverToError("PreviewFile", &err)
+	
+	return a.PreviewFiles([]string{filePath}, verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat)
+}
+
+// PreviewFiles executes the mlr transformation on several files read one
+// after the other, as in mlr ... jan.csv feb.csv, so FILENAME and FNR apply.
+// Glob patterns and directories are expanded into the files they hold.
+func (a *App) PreviewFiles(filePaths []string, verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string) (_ string, err error) {
+	defer RecoverToError("PreviewFiles", &err)
+	
+	result, err := a.PreviewFilesWithProfile(filePaths, verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat)
+	return result.Output, err
+}
+
+// PreviewFilesWithProfile is PreviewFiles returning the profile of each verb
+// alongside the output
+func (a *App) PreviewFilesWithProfile(filePaths []string, verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string) (_ ProfiledPreview, err error) {
+	defer RecoverToError("PreviewFilesWithProfile", &err)
+	
+	if len(filePaths) == 0 {
+		return ProfiledPreview{}, errors.New("no input files")
+	}
+	filePaths, err = expandInputPaths(filePaths)
+	if err != nil {
+		return ProfiledPreview{}, err
+	}
+	compression, err := filesCompression(filePaths)
+	if err != nil {
+		return ProfiledPreview{}, err
+	}
+	
+	// The same files, unchanged, and arguments give the same output
+	cacheKey, inputKey := "", ""
+	args, argsErr := a.constructArgs(verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat, compression)
+	if argsErr == nil {
+		cacheKey, _ = previewCacheKey(nil, filePaths, args)
+		inputKey, _ = previewCacheKey(nil, filePaths, pipelineArgs{})
+	}
+	if result, ok := a.cachedPreviewOutput(cacheKey, "PreviewFile"); ok {
+		return result, nil
+	}
+	
+	// Wait for our turn, superseding previews still running or waiting
+	ctx, cancel, err := a.beginPreview()
+	if err != nil {
+		return ProfiledPreview{}, err
+	}
+	defer cancel()
 	
 	LogInfo("PreviewFile transformation started", logrus.Fields{
-		"file_path": filePath,
+		"file_paths": filePaths,
+		"compression": compression,
 		"input_format": inputFormat,
 		"output_format": outputFormat,
 		"verbs_count": len(verbs),
 	})
 	
-	// Build the command-line arguments as we would pass to mlr
-	args, err := a.constructArgs(verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat)
+	// Build the Miller options and transformer chain as mlr would
+	mlrOptions, recordTransformers, err := a.buildPipeline(verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat, compression)
 	if err != nil {
-		LogError(err, "Failed to construct args", nil)
-		return "", err
+		return ProfiledPreview{}, err
 	}
 
-	// Miller's ParseCommandLine expects args[0] to be the program name (like os.Args)
-	// Prepend "mlr" to match the expected format
-	argsWithProgramName := append([]string{"mlr"}, args...)
+	// Set up output buffer
+	var outputBuffer bytes.Buffer
+	bufferedOutputStream := bufio.NewWriter(&outputBuffer)
+
+	// Run the Miller transformation directly on the files, or from a snapshot
+	// if the leading verbs are unchanged
+	profile, err := a.runPreviewPipeline(ctx, "PreviewFile", inputKey, args, filePaths, mlrOptions, recordTransformers, verbs, bufferedOutputStream)
+	if errors.Is(err, ErrPreviewCancelled) {
+		LogInfo("PreviewFile transformation cancelled", logrus.Fields{"files": filePaths})
+		return ProfiledPreview{}, err
+	}
+	if err != nil {
+		LogError(err, "Miller transformation failed", logrus.Fields{"files": filePaths})
+		return ProfiledPreview{}, err
+	}
+
+	bufferedOutputStream.Flush()
+	result := ProfiledPreview{Output: outputBuffer.String(), Profile: profile}
+	if cacheKey != "" {
+		a.cache.put(cacheKey, result.Output, result.Profile)
+	}
 	
-	LogInfo("Calling ParseCommandLine", logrus.Fields{
-		"full_args": argsWithProgramName,
+	LogInfo("PreviewFile transformation completed", logrus.Fields{
+		"output_size": len(result.Output),
 	})
+	
+	return result, nil
+}
 
-	// Parse the command line to get options and transformers
-	mlrOptions, recordTransformers, err := climain.ParseCommandLine(argsWithProgramName)
+// PreviewFileBounded executes the mlr transformation on a file like PreviewFile,
+// but stops reading once any of the limits is hit and returns the partial output
+func (a *App) PreviewFileBounded(filePath string, verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string, limits PreviewLimits) (_ PreviewResult, err error) {
+	defer RecoverToError("PreviewFileBounded", &err)
+	
+	// Wait for our turn, superseding previews still running or waiting
+	ctx, cancel, err := a.beginPreview()
 	if err != nil {
-		LogError(err, "Failed to parse command", logrus.Fields{"args": argsWithProgramName})
-		return "", fmt.Errorf("error parsing command: %v", err)
+		return PreviewResult{}, err
+	}
+	defer cancel()
+	
+	filePaths, err := expandInputPaths([]string{filePath})
+	if err != nil {
+		return PreviewResult{}, err
+	}
+	compression, err := filesCompression(filePaths)
+	if err != nil {
+		return PreviewResult{}, err
+	}
+	
+	LogInfo("PreviewFileBounded transformation started", logrus.Fields{
+		"file_path": filePath,
+		"compression": compression,
+		"input_format": inputFormat,
+		"output_format": outputFormat,
+		"verbs_count": len(verbs),
+		"max_input_records": limits.MaxInputRecords,
+		"max_output_records": limits.MaxOutputRecords,
+		"max_output_bytes": limits.MaxOutputBytes,
+	})
+	
+	// Build the Miller options and transformer chain as mlr would
+	mlrOptions, recordTransformers, err := a.buildPipeline(verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat, compression)
+	if err != nil {
+		return PreviewResult{}, err
 	}
 
-	// Set up output buffer
+	// Time each verb, and count and cap records on their way into and out of
+	// the chain, and bytes on their way out
+	profiledTransformers, profilers := withProfiling(recordTransformers)
+	stop := newReaderStop()
+	limitedTransformers, inputLimiter, outputLimiter := withRecordLimits(profiledTransformers, limits, stop)
 	var outputBuffer bytes.Buffer
-	bufferedOutputStream := bufio.NewWriter(&outputBuffer)
+	limitedOutput := &byteLimitWriter{w: &outputBuffer, max: limits.MaxOutputBytes, stop: stop}
 
-	// Run the Miller transformation directly on the file
-	err = runMillerTransformation([]string{filePath}, mlrOptions, recordTransformers, bufferedOutputStream)
+	err = runMillerTransformation(ctx, filePaths, mlrOptions, limitedTransformers, limitedOutput, stop.ch)
+	err = locateVerb(err, verbs)
+	if errors.Is(err, ErrPreviewCancelled) {
+		LogInfo("PreviewFileBounded transformation cancelled", logrus.Fields{"file": filePath})
+		return PreviewResult{}, err
+	}
 	if err != nil {
 		LogError(err, "Miller transformation failed", logrus.Fields{"file": filePath})
-		return "", err
+		return PreviewResult{}, err
 	}
 
-	bufferedOutputStream.Flush()
-	result := outputBuffer.String()
+	result := PreviewResult{
+		Output:         outputBuffer.String(),
+		Truncated:      stop.truncated.Load(),
+		RecordsRead:    inputLimiter.passed.Load(),
+		RecordsWritten: outputLimiter.passed.Load(),
+		BytesWritten:   limitedOutput.written.Load(),
+		Profile:        buildProfile(verbs, profilers),
+	}
 	
-	LogInfo("PreviewFile transformation completed", logrus.Fields{
-		"output_size": len(result),
+	LogInfo("PreviewFileBounded transformation completed", logrus.Fields{
+		"output_size": len(result.Output),
+		"truncated": result.Truncated,
+		"records_read": result.RecordsRead,
+		"records_written": result.RecordsWritten,
 	})
 	
 	return result, nil
 }
 
-
 // runMillerTransformation runs the Miller transformation pipeline
 // This is based on Miller's streaming architecture from the library examples
+// Cancelling ctx tells the reader to stop via its downstream-done channel, and
+// the run then returns ErrPreviewCancelled instead of its (partial) output.
+// Closing stopReading (may be nil) stops the reader the same way but keeps
+// whatever output the pipeline produced from the records read so far.
 func runMillerTransformation(
+	ctx context.Context,
 	fileNames []string,
 	options *cli.TOptions,
 	recordTransformers []transformers.IRecordTransformer,
 	outputStream io.Writer,
-) error {
-	defer RecoverFromPanic("runMillerTransformation")
+	stopReading <-chan struct{},
+) (err error) {
+	defer RecoverToError("runMillerTransformation", &err)
 	
-	outputIsStdout := false
-
-	// Create initial context
-	initialContext := types.NewContext()
-
 	// Create the record reader
 	recordReader, err := input.Create(&options.ReaderOptions, options.ReaderOptions.RecordsPerBatch)
 	if err != nil {
 		LogError(err, "Failed to create record reader", nil)
-		return fmt.Errorf("error creating record reader: %v", err)
+		return &PreviewError{Phase: PhaseRead, VerbIndex: -1, Message: fmt.Sprintf("error creating record reader: %v", err)}
 	}
 
+	return runPipeline(ctx, recordReader, fileNames, options, recordTransformers, outputStream, stopReading)
+}
+
+// runPipeline is runMillerTransformation with the record reader given, which
+// lets a run take its records from somewhere other than Miller's readers
+func runPipeline(
+	ctx context.Context,
+	recordReader input.IRecordReader,
+	fileNames []string,
+	options *cli.TOptions,
+	recordTransformers []transformers.IRecordTransformer,
+	outputStream io.Writer,
+	stopReading <-chan struct{},
+) (err error) {
+	defer RecoverToError("runPipeline", &err)
+	
+	outputIsStdout := false
+
+	// Create initial context
+	initialContext := types.NewContext()
+
 	// Create the record writer
 	recordWriter, err := output.Create(&options.WriterOptions)
 	if err != nil {
 		LogError(err, "Failed to create record writer", nil)
-		return fmt.Errorf("error creating record writer: %v", err)
-	}
+		return &PreviewError{Phase: PhaseWrite, VerbIndex: -1, Message: fmt.Sprintf("error creating record writer: %v", err)}
+	}
+
+	// Catch where verbs and the writer fail, which Miller itself only prints to
+	// stderr. A failure stops the run the same way cancelling does.
+	// The helper goroutines are waited for last, after stopRun and closing
+	// pipelineFinished have let them go
+	var helpers sync.WaitGroup
+	defer helpers.Wait()
+	runCtx, stopRun := context.WithCancel(ctx)
+	defer stopRun()
+	failure := newRunFailure()
+	helpers.Add(1)
+	go func() {
+		defer helpers.Done()
+		select {
+		case <-failure.ch:
+			stopRun()
+		case <-runCtx.Done():
+		}
+	}()
+	recordTransformers = guardVerbs(recordTransformers, failure)
+	writer := &locatingWriter{inner: recordWriter, failure: failure}
 
 	// Set up channels for the pipeline
 	readerChannel := make(chan *list.List, 2)              // reader -> transformer
@@ -758,13 +813,43 @@ func runMillerTransformation(
 	doneWritingChannel := make(chan bool, 1)               // writer done signal
 	dataProcessingErrorChannel := make(chan bool, 1)       // data processing errors
 	readerDownstreamDoneChannel := make(chan bool, 1)      // downstream done signal
+	chainDownstreamDoneChannel := make(chan bool, 1)       // downstream done signal from the transformer chain
+	pipelineFinished := make(chan struct{})                // closed once we stop waiting on the pipeline
 
-	bufferedOutputStream := bufio.NewWriter(outputStream)
+	// Once cancelled, anything still written is thrown away. An output that
+	// caps its size says how much may wait in the buffer in front of it.
+	bufferSize := defaultOutputBufferSize
+	if sized, ok := outputStream.(outputBufferSizer); ok {
+		bufferSize = sized.bufferSize()
+	}
+	bufferedOutputStream := bufio.NewWriterSize(&cancellableWriter{ctx: runCtx, w: outputStream}, bufferSize)
+
+	stages := &pipelineStages{
+		readerChannel:     readerChannel,
+		writerChannel:     writerChannel,
+		inputErrorChannel: inputErrorChannel,
+		readerReturned:    make(chan struct{}),
+		writerReturned:    make(chan struct{}),
+		writer:            writer,
+		endOfStream:       types.NewEndOfStreamMarkerList(initialContext),
+	}
 
 	// Start the pipeline goroutines
-	go recordReader.Read(fileNames, *initialContext, readerChannel, inputErrorChannel, readerDownstreamDoneChannel)
-	go transformers.ChainTransformer(readerChannel, readerDownstreamDoneChannel, recordTransformers, writerChannel, options)
-	go output.ChannelWriter(writerChannel, recordWriter, &options.WriterOptions, doneWritingChannel, dataProcessingErrorChannel, bufferedOutputStream, outputIsStdout)
+	go func() {
+		defer close(stages.readerReturned)
+		recordReader.Read(fileNames, *initialContext, readerChannel, inputErrorChannel, readerDownstreamDoneChannel)
+	}()
+	go transformers.ChainTransformer(readerChannel, chainDownstreamDoneChannel, recordTransformers, writerChannel, options)
+	go func() {
+		defer close(stages.writerReturned)
+		output.ChannelWriter(writerChannel, writer, &options.WriterOptions, doneWritingChannel, dataProcessingErrorChannel, bufferedOutputStream, outputIsStdout)
+	}()
+	helpers.Add(1)
+	go func() {
+		defer helpers.Done()
+		relayDownstreamDone(runCtx, stopReading, chainDownstreamDoneChannel, readerDownstreamDoneChannel, pipelineFinished)
+	}()
+	defer close(pipelineFinished)
 
 	// Wait for completion or error
 	var retval error
@@ -773,30 +858,120 @@ func runMillerTransformation(
 		select {
 		case ierr := <-inputErrorChannel:
 			LogError(ierr, "Input error during Miller transformation", nil)
-			retval = ierr
+			retval = &PreviewError{Phase: PhaseRead, VerbIndex: -1, Message: strings.TrimSpace(ierr.Error())}
+			stages.readerFailed = true
 			done = true
 		case <-dataProcessingErrorChannel:
-			retval = errors.New("data processing error")
+			// The writer has recorded what went wrong before signalling
+			select {
+			case <-failure.ch:
+				retval = failure.err
+			default:
+				retval = &PreviewError{Phase: PhaseWrite, VerbIndex: -1, Message: "data processing error"}
+			}
 			LogError(retval, "Data processing error during Miller transformation", nil)
 			done = true
+		case <-failure.ch:
+			retval = failure.err
+			done = true
 		case <-doneWritingChannel:
 			done = true
 		}
 	}
 
-	bufferedOutputStream.Flush()
+	// Leave no stage blocked on a channel, however the run ended. Stopping the
+	// run tells the reader to stop and throws away output still on its way.
+	if retval != nil {
+		stopRun()
+	}
+	stages.wait()
+
+	if ctx.Err() != nil {
+		return ErrPreviewCancelled
+	}
+
+	// After a failure the output is partial anyway
 	if retval != nil {
 		LogError(retval, "Miller transformation completed with errors", nil)
+		return retval
+	}
+	bufferedOutputStream.Flush()
+	return nil
+}
+
+// defaultOutputBufferSize is the size of the buffer between Miller's writer
+// and a run's output, bufio's default
+const defaultOutputBufferSize = 4096
+
+// outputBufferSizer is an output that wants a smaller buffer in front of it
+type outputBufferSizer interface {
+	bufferSize() int
+}
+
+// relayDownstreamDone forwards the transformer chain's downstream-done signal
+// (e.g. from head) to the reader. Once ctx is cancelled or stopReading is
+// closed it keeps the reader signalled, so that every remaining input file is
+// abandoned as well. It returns when finished is closed.
+func relayDownstreamDone(ctx context.Context, stopReading <-chan struct{}, chainDone <-chan bool, readerDone chan<- bool, finished <-chan struct{}) {
+	for {
+		select {
+		case b := <-chainDone:
+			select {
+			case readerDone <- b:
+			case <-finished:
+				return
+			}
+		case <-ctx.Done():
+			keepReaderSignalled(chainDone, readerDone, finished)
+			return
+		case <-stopReading:
+			keepReaderSignalled(chainDone, readerDone, finished)
+			return
+		case <-finished:
+			return
+		}
+	}
+}
+
+// keepReaderSignalled re-arms the reader's downstream-done channel every time
+// the reader consumes it, and swallows further signals from the chain, until
+// finished is closed
+func keepReaderSignalled(chainDone <-chan bool, readerDone chan<- bool, finished <-chan struct{}) {
+	for {
+		select {
+		case readerDone <- true:
+		case <-chainDone:
+		case <-finished:
+			return
+		}
 	}
-	return retval
+}
+
+// cancellableWriter discards everything written once its context is cancelled
+type cancellableWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (c *cancellableWriter) Write(p []byte) (int, error) {
+	if c.ctx.Err() != nil {
+		return len(p), nil
+	}
+	return c.w.Write(p)
 }
 
 // ReadFileHead reads the first n lines of a file
-func (a *App) ReadFileHead(path string, n int) (string, error) {
-	defer RecoverFromPanic("ReadFileHead")
+func (a *App) ReadFileHead(path string, n int) (_ string, err error) {
+	defer RecoverToError("ReadFileHead", &err)
 	
 	LogInfo("Reading file head", logrus.Fields{"path": path, "lines": n})
 	
+	compression, err := detectFileCompression(path)
+	if err != nil {
+		LogError(err, "Failed to open file", logrus.Fields{"path": path})
+		return "", err
+	}
+	
 	file, err := os.Open(path)
 	if err != nil {
 		LogError(err, "Failed to open file", logrus.Fields{"path": path})
@@ -804,8 +979,16 @@ func (a *App) ReadFileHead(path string, n int) (string, error) {
 	}
 	defer file.Close()
 
+	// Show compressed files as the text they hold
+	content, err := decompressReader(file, compression)
+	if err != nil {
+		LogError(err, "Failed to decompress file", logrus.Fields{"path": path, "compression": compression})
+		return "", err
+	}
+	defer content.Close()
+
 	var lines []string
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(content)
 	for i := 0; i < n && scanner.Scan(); i++ {
 		lines = append(lines, scanner.Text())
 	}
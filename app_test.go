@@ -1,8 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/johnkerl/miller/v6/pkg/climain"
 )
 
 
@@ -74,3 +81,73 @@ func TestPreview(t *testing.T) {
 		t.Errorf("Expected output to contain values from input, got: %s", output)
 	}
 }
+
+func TestBeginPreviewCancelsPrevious(t *testing.T) {
+	app := NewApp()
+
+	first, endFirst, err := app.beginPreview()
+	if err != nil {
+		t.Fatalf("beginPreview failed: %v", err)
+	}
+
+	type begun struct {
+		ctx context.Context
+		end func()
+		err error
+	}
+	secondBegun := make(chan begun)
+	go func() {
+		ctx, end, err := app.beginPreview()
+		secondBegun <- begun{ctx, end, err}
+	}()
+
+	select {
+	case <-first.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the first preview to be cancelled once a second one started")
+	}
+
+	// The second one only runs once the first is over
+	select {
+	case <-secondBegun:
+		t.Fatalf("Expected the second preview to wait for the first to end")
+	case <-time.After(50 * time.Millisecond):
+	}
+	endFirst()
+
+	second := <-secondBegun
+	if second.err != nil {
+		t.Fatalf("beginPreview failed: %v", second.err)
+	}
+	defer second.end()
+	if second.ctx.Err() != nil {
+		t.Errorf("Expected the newest preview to stay active, got: %v", second.ctx.Err())
+	}
+}
+
+func TestRunMillerTransformationCancelled(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "mlr-test-*.csv")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString("a,b\n1,2\n3,4\n")
+	tmpFile.Close()
+
+	mlrOptions, recordTransformers, err := climain.ParseCommandLine([]string{"mlr", "--icsv", "--ojson", "cat"})
+	if err != nil {
+		t.Fatalf("ParseCommandLine failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var out bytes.Buffer
+	err = runMillerTransformation(ctx, []string{tmpFile.Name()}, mlrOptions, recordTransformers, &out, nil)
+	if !errors.Is(err, ErrPreviewCancelled) {
+		t.Errorf("Expected ErrPreviewCancelled, got: %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no output from a cancelled run, got: %s", out.String())
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/johnkerl/miller/v6/pkg/cli"
+	"github.com/johnkerl/miller/v6/pkg/climain"
+)
+
+// Miller exits the process on some bad arguments, such as a verb option it
+// doesn't know or a flag missing its argument, instead of returning an error.
+// Arguments are therefore parsed first in a child process: this executable
+// started again with argsCheckEnv set, where an exit costs nothing. The child
+// reports each word it starts parsing at, so that if Miller exits, the parent
+// knows which one it stopped at. It is kept for further checks until then.
+
+// argsCheckEnv makes the executable check the Miller arguments on its stdin
+// and exit, instead of starting the app
+const argsCheckEnv = "MLR_DESKTOP_ARGS_CHECK"
+
+// argsCheckPrefix starts the lines the child writes to stdout for the parent,
+// telling them from anything Miller prints
+const argsCheckPrefix = "mlr-desktop-args-check: "
+
+// argsCheckTimeout bounds how long a check may take
+const argsCheckTimeout = 10 * time.Second
+
+// maxArgsCheckLines is how much of what Miller prints before exiting is kept
+// as the error message
+const maxArgsCheckLines = 20
+
+// Steps of splitting the arguments, which an argsError comes from
+const (
+	argsStepFlag      = "flag"      // a main flag and its arguments
+	argsStepVerb      = "verb"      // a verb and its options
+	argsStepConstruct = "construct" // Miller building the verbs from the whole command line
+)
+
+// millerArgs are mlr's arguments split the way mlr splits them
+type millerArgs struct {
+	FlagGroups [][]string `json:"flagGroups"` // main flags, each with its arguments
+	Verbs      [][]string `json:"verbs"`      // the words of each verb, without "then"
+	Files      []string   `json:"files"`      // what follows the last verb
+}
+
+// argsError is a failure to split mlr's arguments: Miller rejecting them, or
+// exiting on them in the child
+type argsError struct {
+	Step     string `json:"step"`
+	ArgIndex int    `json:"argIndex"` // the word the failing step started at
+	Message  string `json:"message"`
+}
+
+func (e *argsError) Error() string {
+	return e.Message
+}
+
+// argsCheckResult is what the child reports once it is done
+type argsCheckResult struct {
+	Args  millerArgs `json:"args"`
+	Error *argsError `json:"error,omitempty"`
+}
+
+// splitMillerArgs splits tokens, mlr's arguments without the program name,
+// with Miller's own main-flag table and each verb's own argument parser: main
+// flags up to the first verb, verbs joined by "then", and files after the last
+// verb. step is called before Miller parses from each word.
+func splitMillerArgs(tokens []string, step func(kind string, argi int)) (millerArgs, error) {
+	var split millerArgs
+	options := cli.DefaultOptions()
+
+	argi := 0
+	for argi < len(tokens) && len(tokens[argi]) > 1 && tokens[argi][0] == '-' {
+		start := argi
+		step(argsStepFlag, start)
+		if !cli.FLAG_TABLE.Parse(tokens, len(tokens), &argi, options) {
+			return split, &argsError{Step: argsStepFlag, ArgIndex: start, Message: fmt.Sprintf("option %q not recognized", tokens[start])}
+		}
+		split.FlagGroups = append(split.FlagGroups, tokens[start:argi])
+	}
+	if argi >= len(tokens) {
+		return split, &argsError{Step: argsStepVerb, ArgIndex: argi, Message: "no verb supplied"}
+	}
+
+	for {
+		start := argi
+		verb := tokens[start]
+		step(argsStepVerb, start)
+		setup := lookupTransformer(verb)
+		if setup == nil {
+			return split, &argsError{Step: argsStepVerb, ArgIndex: start, Message: fmt.Sprintf("verb %q not found", verb)}
+		}
+		setup.ParseCLIFunc(&argi, len(tokens), tokens, options, false)
+		if argi <= start {
+			return split, &argsError{Step: argsStepVerb, ArgIndex: start, Message: fmt.Sprintf("mlr %s: could not parse arguments", verb)}
+		}
+		split.Verbs = append(split.Verbs, tokens[start:argi])
+
+		if argi < len(tokens) && tokens[argi] == "then" {
+			argi++
+			if argi >= len(tokens) {
+				return split, &argsError{Step: argsStepVerb, ArgIndex: argi, Message: "missing verb after then"}
+			}
+			continue
+		}
+		break
+	}
+
+	split.Files = tokens[argi:]
+	return split, nil
+}
+
+// runArgsCheckIfRequested serves args checks and exits if this process was
+// started as the args check child; otherwise it returns. The child checks
+// argument lists, JSON arrays on stdin, until stdin closes or Miller exits it.
+func runArgsCheckIfRequested() {
+	if os.Getenv(argsCheckEnv) == "" {
+		return
+	}
+
+	report := func(kind string, value any) {
+		fmt.Fprintf(os.Stdout, "%s%s %v\n", argsCheckPrefix, kind, value)
+	}
+	decoder := json.NewDecoder(os.Stdin)
+	for {
+		var tokens []string
+		if err := decoder.Decode(&tokens); err == io.EOF {
+			os.Exit(0)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read arguments: %v\n", err)
+			os.Exit(2)
+		}
+
+		var result argsCheckResult
+		split, err := splitMillerArgs(tokens, func(kind string, argi int) { report(kind, argi) })
+		result.Args = split
+		if err != nil {
+			result.Error = err.(*argsError)
+		} else {
+			// Constructing the verbs can exit too, e.g. on a put expression
+			// that doesn't parse. Errors it returns are left to the parent.
+			report(argsStepConstruct, 0)
+			climain.ParseCommandLine(append([]string{"mlr"}, tokens...))
+		}
+
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write result: %v\n", err)
+			os.Exit(2)
+		}
+		report("result", string(encoded))
+	}
+}
+
+// checkMillerArgs splits tokens the way splitMillerArgs does, in the child so
+// that Miller can't exit the app. Where Miller would exit, the error is an
+// *argsError holding what it printed.
+func checkMillerArgs(tokens []string) (millerArgs, error) {
+	key := strings.Join(tokens, "\x00")
+	if result, ok := argsChecks.get(key); ok {
+		return result.Args, result.err()
+	}
+
+	result, err := argsCheckChild.check(tokens)
+	if err != nil {
+		LogError(err, "Failed to check Miller arguments", nil)
+		return millerArgs{}, err
+	}
+	argsChecks.put(key, result)
+	return result.Args, result.err()
+}
+
+func (r argsCheckResult) err() error {
+	if r.Error != nil {
+		return r.Error
+	}
+	return nil
+}
+
+// argsChecker runs args checks in a child it keeps between checks, starting
+// another once Miller has exited one
+type argsChecker struct {
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *syncBuffer
+}
+
+var argsCheckChild = &argsChecker{}
+
+// syncBuffer is a bytes.Buffer the child's stderr can be copied into while
+// the checker reads it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// take returns what was written since the last take
+func (b *syncBuffer) take() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	text := b.buf.String()
+	b.buf.Reset()
+	return text
+}
+
+// start starts a child
+func (c *argsChecker) start() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(executable)
+	cmd.Env = append(os.Environ(), argsCheckEnv+"=1")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		stdin.Close()
+		return err
+	}
+	stderr := &syncBuffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		stdin.Close()
+		return err
+	}
+	c.cmd, c.stdin, c.stdout, c.stderr = cmd, stdin, bufio.NewReader(stdout), stderr
+	return nil
+}
+
+// stop kills the child, if there is one, and waits for it to go and for the
+// last of its stderr. Waiting closes its stdout, so reading it must be done.
+func (c *argsChecker) stop() {
+	if c.cmd == nil {
+		return
+	}
+	c.stdin.Close()
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	c.cmd = nil
+}
+
+// check has the child check tokens. The error is for failing to run the
+// check; what the child found is in the result.
+func (c *argsChecker) check(tokens []string) (argsCheckResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	input, err := json.Marshal(tokens)
+	if err != nil {
+		return argsCheckResult{}, err
+	}
+	if c.cmd == nil {
+		if err := c.start(); err != nil {
+			return argsCheckResult{}, fmt.Errorf("failed to start args check: %v", err)
+		}
+	}
+	c.stderr.take()
+
+	// A child that hangs is killed, which ends the reading below
+	var timedOut atomic.Bool
+	process := c.cmd.Process
+	timer := time.AfterFunc(argsCheckTimeout, func() {
+		timedOut.Store(true)
+		process.Kill()
+	})
+	defer timer.Stop()
+
+	// The last step reported is the one Miller exited in, if it did
+	var last *argsError
+	var printed []string
+	_, writeErr := c.stdin.Write(append(input, '\n'))
+	for writeErr == nil {
+		line, err := c.stdout.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		report, ok := strings.CutPrefix(line, argsCheckPrefix)
+		if !ok {
+			if strings.TrimSpace(line) != "" {
+				printed = append(printed, line)
+			}
+			continue
+		}
+		kind, value, _ := strings.Cut(report, " ")
+		if kind == "result" {
+			var result argsCheckResult
+			if err := json.Unmarshal([]byte(value), &result); err != nil {
+				c.stop()
+				return argsCheckResult{}, fmt.Errorf("bad args check result: %v", err)
+			}
+			return result, nil
+		}
+		last = &argsError{Step: kind}
+		fmt.Sscan(value, &last.ArgIndex)
+	}
+
+	// The child is gone
+	c.stop()
+	stderr := strings.TrimSpace(c.stderr.take())
+	if timedOut.Load() {
+		return argsCheckResult{}, fmt.Errorf("checking the arguments took longer than %v", argsCheckTimeout)
+	}
+	if last == nil {
+		// The child never got to Miller
+		return argsCheckResult{}, fmt.Errorf("args check failed: %s", stderr)
+	}
+
+	// Miller prints its complaint to stderr, and usage to stdout
+	message := stderr
+	if message == "" {
+		message = strings.TrimSpace(strings.Join(printed, "\n"))
+	}
+	if lines := strings.Split(message, "\n"); len(lines) > maxArgsCheckLines {
+		message = strings.Join(lines[:maxArgsCheckLines], "\n") + "\n..."
+	}
+	if message == "" && last.ArgIndex < len(tokens) {
+		message = fmt.Sprintf("mlr exits on %q", tokens[last.ArgIndex])
+	} else if message == "" {
+		message = "mlr exits on these arguments"
+	}
+	last.Message = message
+	return argsCheckResult{Error: last}, nil
+}
+
+// maxArgsChecks is how many checked argument lists argsChecks remembers
+const maxArgsChecks = 256
+
+// argsCheckCache remembers the outcome of recent args checks, so that a
+// preview re-run on arguments Miller exits on doesn't start another child
+type argsCheckCache struct {
+	mu      sync.Mutex
+	results map[string]argsCheckResult
+}
+
+var argsChecks = &argsCheckCache{results: map[string]argsCheckResult{}}
+
+func (c *argsCheckCache) get(key string) (argsCheckResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result, ok := c.results[key]
+	return result, ok
+}
+
+// put remembers result, forgetting everything else once the cache is full
+func (c *argsCheckCache) put(key string, result argsCheckResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.results) >= maxArgsChecks {
+		c.results = map[string]argsCheckResult{}
+	}
+	c.results[key] = result
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestMain lets the test binary serve as the args check child, as the app
+// binary does
+func TestMain(m *testing.M) {
+	runArgsCheckIfRequested()
+	os.Exit(m.Run())
+}
+
+func TestCheckMillerArgs(t *testing.T) {
+	split, err := checkMillerArgs([]string{"--icsv", "--ifs", ";", "head", "-n", "2", "then", "cat", "in.csv"})
+	if err != nil {
+		t.Fatalf("checkMillerArgs failed: %v", err)
+	}
+	want := millerArgs{
+		FlagGroups: [][]string{{"--icsv"}, {"--ifs", ";"}},
+		Verbs:      [][]string{{"head", "-n", "2"}, {"cat"}},
+		Files:      []string{"in.csv"},
+	}
+	if !reflect.DeepEqual(split, want) {
+		t.Errorf("checkMillerArgs = %+v, want %+v", split, want)
+	}
+}
+
+func TestCheckMillerArgsErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		tokens       []string
+		wantStep     string
+		wantArgIndex int
+		wantMessage  string
+	}{
+		{
+			name:         "Unknown main flag",
+			tokens:       []string{"--icsv", "--no-such-flag", "cat"},
+			wantStep:     argsStepFlag,
+			wantArgIndex: 1,
+			wantMessage:  `option "--no-such-flag" not recognized`,
+		},
+		{
+			name:         "Main flag missing its argument",
+			tokens:       []string{"--icsv", "--ifs"},
+			wantStep:     argsStepFlag,
+			wantArgIndex: 1,
+			wantMessage:  `"--ifs" missing argument`,
+		},
+		{
+			name:         "Unknown verb option",
+			tokens:       []string{"--icsv", "cat", "then", "head", "-x"},
+			wantStep:     argsStepVerb,
+			wantArgIndex: 3,
+			wantMessage:  "mlr head:",
+		},
+		{
+			name:         "Verb option missing its argument",
+			tokens:       []string{"head", "-n"},
+			wantStep:     argsStepVerb,
+			wantArgIndex: 0,
+			wantMessage:  "mlr head:",
+		},
+		{
+			name:         "Unknown verb",
+			tokens:       []string{"cat", "then", "frobnicate"},
+			wantStep:     argsStepVerb,
+			wantArgIndex: 2,
+			wantMessage:  `verb "frobnicate" not found`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := checkMillerArgs(tt.tokens)
+
+			var argsErr *argsError
+			if !errors.As(err, &argsErr) {
+				t.Fatalf("Expected an *argsError, got %T: %v", err, err)
+			}
+			if argsErr.Step != tt.wantStep || argsErr.ArgIndex != tt.wantArgIndex {
+				t.Errorf("Stopped at %s %d, want %s %d", argsErr.Step, argsErr.ArgIndex, tt.wantStep, tt.wantArgIndex)
+			}
+			if !strings.Contains(argsErr.Message, tt.wantMessage) {
+				t.Errorf("Message = %q, want it to contain %q", argsErr.Message, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultPreviewCacheBudget is how much memory cached preview output may take
+// unless SetPreviewCacheBudget says otherwise
+const defaultPreviewCacheBudget = 64 << 20
+
+// previewCache keeps the output of recent previews, so that toggling a verb
+// off and on again or flipping between output formats doesn't re-run the
+// pipeline. It holds at most budget bytes, evicting the least recently used
+// output first.
+type previewCache struct {
+	mu      sync.Mutex
+	budget  int64
+	size    int64
+	order   *list.List // of *cachedPreview, most recently used first
+	entries map[string]*list.Element
+}
+
+type cachedPreview struct {
+	key     string
+	output  string
+	profile []VerbProfile // of the run that produced the output
+}
+
+func (c *cachedPreview) size() int64 {
+	size := int64(len(c.key) + len(c.output))
+	for _, verb := range c.profile {
+		size += int64(unsafe.Sizeof(verb)) + int64(len(verb.Verb))
+	}
+	return size
+}
+
+func newPreviewCache(budget int64) *previewCache {
+	return &previewCache{budget: budget, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// get returns the output cached under key and its profile, if any
+func (c *previewCache) get(key string) (string, []VerbProfile, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return "", nil, false
+	}
+	c.order.MoveToFront(element)
+	entry := element.Value.(*cachedPreview)
+	return entry.output, entry.profile, true
+}
+
+// put caches output under key, with the profile of the run that produced it.
+// Output bigger than the whole budget isn't cached.
+func (c *previewCache) put(key, output string, profile []VerbProfile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	entry := &cachedPreview{key: key, output: output, profile: profile}
+	if entry.size() > c.budget {
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	c.size += entry.size()
+	c.evict()
+}
+
+// setBudget changes the budget, evicting output until the cache fits it
+func (c *previewCache) setBudget(budget int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.budget = budget
+	c.evict()
+}
+
+// evict drops the least recently used output until the cache fits its
+// budget. c.mu must be held.
+func (c *previewCache) evict() {
+	for c.size > c.budget {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *previewCache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*cachedPreview)
+	delete(c.entries, entry.key)
+	c.size -= entry.size()
+}
+
+// previewCacheKey hashes what a preview's output depends on: its input and
+// the mlr argument vector. Text input is identified by its content, files by
+// their path, size and modification time, so editing a file invalidates its
+// entries.
+func previewCacheKey(text *string, filePaths []string, args pipelineArgs) (string, error) {
+	hash := sha256.New()
+	if text != nil {
+		fmt.Fprintf(hash, "text %d\x00", len(*text))
+		io.WriteString(hash, *text)
+	}
+	for _, path := range filePaths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "file %s\x00%d\x00%d\x00", path, info.Size(), info.ModTime().UnixNano())
+	}
+	for _, arg := range args.flatten() {
+		fmt.Fprintf(hash, "arg %s\x00", arg)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// cachedPreviewOutput returns the cached output for a preview with the given
+// key, and the profile of the run that produced it, if there is any. A hit
+// still supersedes the previews in flight, so none of them reports output
+// after it.
+func (a *App) cachedPreviewOutput(key string, method string) (ProfiledPreview, bool) {
+	if key == "" {
+		return ProfiledPreview{}, false
+	}
+	output, profile, ok := a.cache.get(key)
+	if !ok {
+		return ProfiledPreview{}, false
+	}
+	a.executor.supersede()
+	LogInfo("Preview served from cache", logrus.Fields{"method": method, "cache_key": key[:12], "output_size": len(output)})
+	return ProfiledPreview{Output: output, Profile: profile}, true
+}
+
+// GetPreviewCacheBudget returns how many bytes of preview output are kept
+func (a *App) GetPreviewCacheBudget() (_ int64, err error) {
+	defer RecoverToError("GetPreviewCacheBudget", &err)
+
+	a.cache.mu.Lock()
+	defer a.cache.mu.Unlock()
+	return a.cache.budget, nil
+}
+
+// SetPreviewCacheBudget changes how many bytes of preview output are kept;
+// 0 turns the cache off
+func (a *App) SetPreviewCacheBudget(maxBytes int64) (err error) {
+	defer RecoverToError("SetPreviewCacheBudget", &err)
+
+	if maxBytes < 0 {
+		return errors.New("preview cache budget can't be negative")
+	}
+	a.cache.setBudget(maxBytes)
+	LogInfo("Preview cache budget changed", logrus.Fields{"max_bytes": maxBytes})
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// captureLog sends the log to the returned buffer until the test ends
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var logged bytes.Buffer
+	savedLog := Log
+	Log = logrus.New()
+	Log.SetOutput(&logged)
+	Log.SetFormatter(&logrus.JSONFormatter{})
+	t.Cleanup(func() { Log = savedLog })
+	return &logged
+}
+
+func TestPreviewCacheLRU(t *testing.T) {
+	entrySize := int64(len("k1") + len("0123456789"))
+	cache := newPreviewCache(3 * entrySize)
+
+	for _, key := range []string{"k1", "k2", "k3"} {
+		cache.put(key, "0123456789", nil)
+	}
+	// k1 is now the most recently used, so k2 goes first
+	if _, _, ok := cache.get("k1"); !ok {
+		t.Fatalf("Expected k1 to be cached")
+	}
+	cache.put("k4", "0123456789", nil)
+
+	for key, want := range map[string]bool{"k1": true, "k2": false, "k3": true, "k4": true} {
+		if _, _, ok := cache.get(key); ok != want {
+			t.Errorf("Cached(%s) = %v, want %v", key, ok, want)
+		}
+	}
+	if cache.size != 3*entrySize {
+		t.Errorf("Size = %d, want %d", cache.size, 3*entrySize)
+	}
+
+	// Replacing an entry doesn't count it twice
+	cache.put("k4", "0123456789", nil)
+	if cache.size != 3*entrySize {
+		t.Errorf("Size after replacing = %d, want %d", cache.size, 3*entrySize)
+	}
+
+	// Output bigger than the budget isn't cached, and doesn't evict anything
+	cache.put("big", strings.Repeat("x", int(4*entrySize)), nil)
+	if _, _, ok := cache.get("big"); ok || len(cache.entries) != 3 {
+		t.Errorf("Expected output over the budget to be left out")
+	}
+
+	cache.setBudget(entrySize)
+	if len(cache.entries) != 1 || cache.size != entrySize {
+		t.Errorf("After shrinking the budget: %d entries of %d bytes, want 1 of %d", len(cache.entries), cache.size, entrySize)
+	}
+}
+
+func TestPreviewCacheToggleVerb(t *testing.T) {
+	logged := captureLog(t)
+	app := NewApp()
+	input := "a,b\n1,2\n3,4\n"
+	verbs := func(enabled bool) []VerbConfig {
+		return []VerbConfig{{Value: "head -n 1", Enabled: enabled}, {Value: "put '$c = NR'", Enabled: true}}
+	}
+
+	first, err := app.Preview(input, verbs(true), "", "--icsv", false, false, ",", "--ocsv")
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	if _, err := app.Preview(input, verbs(false), "", "--icsv", false, false, ",", "--ocsv"); err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	if strings.Contains(logged.String(), "Preview served from cache") {
+		t.Fatalf("Expected no cache hits for different verbs")
+	}
+
+	again, err := app.Preview(input, verbs(true), "", "--icsv", false, false, ",", "--ocsv")
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	if again != first {
+		t.Errorf("Cached output = %q, want %q", again, first)
+	}
+	if hits := strings.Count(logged.String(), "Preview served from cache"); hits != 1 {
+		t.Errorf("Cache hits logged = %d, want 1", hits)
+	}
+
+	// Another output format is another entry
+	if _, err := app.Preview(input, verbs(true), "", "--icsv", false, false, ",", "--ojson"); err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	if hits := strings.Count(logged.String(), "Preview served from cache"); hits != 1 {
+		t.Errorf("Cache hits logged = %d after changing the output format, want 1", hits)
+	}
+}
+
+func TestPreviewFileCacheSeesFileChanges(t *testing.T) {
+	logged := captureLog(t)
+	app := NewApp()
+	path := writeTestCSV(t, 2)
+	verbs := []VerbConfig{{Value: "cat", Enabled: true}}
+
+	first, err := app.PreviewFile(path, verbs, "", "--icsv", false, false, ",", "--ocsv")
+	if err != nil {
+		t.Fatalf("PreviewFile failed: %v", err)
+	}
+	second, err := app.PreviewFile(path, verbs, "", "--icsv", false, false, ",", "--ocsv")
+	if err != nil {
+		t.Fatalf("PreviewFile failed: %v", err)
+	}
+	if second != first || !strings.Contains(logged.String(), `"method":"PreviewFile"`) {
+		t.Fatalf("Expected the second PreviewFile to be served from cache")
+	}
+
+	// A changed file is a new entry
+	os.WriteFile(path, []byte("id,name\n9,changed\n"), 0644)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	third, err := app.PreviewFile(path, verbs, "", "--icsv", false, false, ",", "--ocsv")
+	if err != nil {
+		t.Fatalf("PreviewFile failed: %v", err)
+	}
+	if third != "id,name\n9,changed\n" {
+		t.Errorf("Output after the file changed = %q", third)
+	}
+}
+
+func TestPreviewCacheHitSupersedes(t *testing.T) {
+	app := NewApp()
+	input := "a=1\n"
+	verbs := []VerbConfig{{Value: "cat", Enabled: true}}
+	if _, err := app.Preview(input, verbs, "", "--idkvp", false, false, ",", "--ojson"); err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+
+	running, end, err := app.beginPreview()
+	if err != nil {
+		t.Fatalf("beginPreview failed: %v", err)
+	}
+	defer end()
+
+	// Served at once, though another preview holds the executor
+	if _, err := app.Preview(input, verbs, "", "--idkvp", false, false, ",", "--ojson"); err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	if running.Err() == nil {
+		t.Errorf("Expected the preview in flight to be superseded by the cache hit")
+	}
+}
+
+func TestSetPreviewCacheBudget(t *testing.T) {
+	logged := captureLog(t)
+	app := NewApp()
+
+	if err := app.SetPreviewCacheBudget(-1); err == nil {
+		t.Errorf("Expected an error for a negative budget")
+	}
+	if err := app.SetPreviewCacheBudget(0); err != nil {
+		t.Fatalf("SetPreviewCacheBudget failed: %v", err)
+	}
+	if budget, _ := app.GetPreviewCacheBudget(); budget != 0 {
+		t.Errorf("Budget = %d, want 0", budget)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := app.Preview("a=1\n", []VerbConfig{{Value: "cat", Enabled: true}}, "", "--idkvp", false, false, ",", "--ojson"); err != nil {
+			t.Fatalf("Preview failed: %v", err)
+		}
+	}
+	if strings.Contains(logged.String(), "Preview served from cache") {
+		t.Errorf("Expected no cache hits with the cache turned off")
+	}
+}
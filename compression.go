@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sirupsen/logrus"
+)
+
+// Compression formats recognised by their magic bytes. The empty string
+// means uncompressed.
+const (
+	CompressionGzip  = "gzip"
+	CompressionBzip2 = "bzip2"
+	CompressionZstd  = "zstd"
+	CompressionZlib  = "zlib"
+)
+
+// compressionFlags maps each compression format to the Miller flag that
+// decompresses it
+var compressionFlags = map[string]string{
+	CompressionGzip:  "--gzin",
+	CompressionBzip2: "--bz2in",
+	CompressionZstd:  "--zstdin",
+	CompressionZlib:  "--zin",
+}
+
+// flagCompression returns the compression format a Miller decompression flag
+// selects, or "" if flag isn't one
+func flagCompression(flag string) string {
+	for compression, compressionFlag := range compressionFlags {
+		if flag == compressionFlag {
+			return compression
+		}
+	}
+	return ""
+}
+
+// compressionMagicLen is how many leading bytes detectCompression looks at
+const compressionMagicLen = 4
+
+// detectCompression returns the compression format that the leading bytes of
+// a file announce, or "" if they match none
+func detectCompression(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return CompressionGzip
+	case bytes.HasPrefix(head, []byte("BZh")):
+		return CompressionBzip2
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return CompressionZstd
+	case len(head) >= 2 && head[0] == 0x78 && (head[1] == 0x01 || head[1] == 0x9c || head[1] == 0xda):
+		// 0x78 0x5e is valid zlib too, but it is also "x^", which text can start with
+		return CompressionZlib
+	}
+	return ""
+}
+
+// detectFileCompression reads the first bytes of the file at path and returns
+// its compression format
+func detectFileCompression(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	head := make([]byte, compressionMagicLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return detectCompression(head[:n]), nil
+}
+
+// filesCompression returns the compression format shared by all the files.
+// Miller applies one decompression flag to every file, so mixing formats is
+// an error.
+func filesCompression(paths []string) (string, error) {
+	compression := ""
+	for i, path := range paths {
+		fileCompression, err := detectFileCompression(path)
+		if err != nil {
+			return "", err
+		}
+		if i > 0 && fileCompression != compression {
+			return "", fmt.Errorf("input files mix compression formats: %q is %s, %q is %s",
+				paths[0], compressionName(compression), path, compressionName(fileCompression))
+		}
+		compression = fileCompression
+	}
+	return compression, nil
+}
+
+func compressionName(compression string) string {
+	if compression == "" {
+		return "uncompressed"
+	}
+	return compression + "-compressed"
+}
+
+// inputCompression returns the compression of config's input files: the one
+// set in config, or else the one detected from the files themselves
+func inputCompression(config Config) (string, error) {
+	if config.InputMode != "file" {
+		return "", nil
+	}
+	if config.Compression != "" {
+		return config.Compression, nil
+	}
+	files, err := expandInputPaths(config.inputFiles())
+	if err != nil {
+		return "", err
+	}
+	return filesCompression(files)
+}
+
+// decompressReader wraps r in a reader that decompresses the given format
+func decompressReader(r io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case "":
+		return io.NopCloser(r), nil
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionBzip2:
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	case CompressionZlib:
+		return zlib.NewReader(r)
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("unknown compression %q", compression)
+}
+
+// DetectCompression returns the compression format of the file at path
+// (gzip, bzip2, zstd or zlib), or "" if it isn't compressed
+func (a *App) DetectCompression(path string) (_ string, err error) {
+	defer RecoverToError("DetectCompression", &err)
+
+	compression, err := detectFileCompression(path)
+	if err != nil {
+		LogError(err, "Failed to detect compression", logrus.Fields{"path": path})
+		return "", err
+	}
+
+	LogInfo("Compression detected", logrus.Fields{"path": path, "compression": compression})
+	return compression, nil
+}
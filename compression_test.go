@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const compressionTestCSV = "id,name\n1,alice\n2,bob\n"
+
+// writeCompressedFile writes content to a file in dir, compressed with the
+// given format
+func writeCompressedFile(t *testing.T, dir, name, compression, content string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	switch compression {
+	case CompressionGzip:
+		w := gzip.NewWriter(&buf)
+		w.Write([]byte(content))
+		w.Close()
+	case CompressionZlib:
+		w := zlib.NewWriter(&buf)
+		w.Write([]byte(content))
+		w.Close()
+	case CompressionZstd:
+		w, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("Failed to create zstd writer: %v", err)
+		}
+		w.Write([]byte(content))
+		w.Close()
+	default:
+		buf.WriteString(content)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestDetectCompression(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{name: "gzip", head: []byte{0x1f, 0x8b, 0x08, 0x00}, want: CompressionGzip},
+		{name: "bzip2", head: []byte("BZh9"), want: CompressionBzip2},
+		{name: "zstd", head: []byte{0x28, 0xb5, 0x2f, 0xfd}, want: CompressionZstd},
+		{name: "zlib", head: []byte{0x78, 0x9c, 0x00, 0x00}, want: CompressionZlib},
+		{name: "CSV text", head: []byte("id,n"), want: ""},
+		{name: "Text starting with x^", head: []byte("x^2,"), want: ""},
+		{name: "Short file", head: []byte{0x1f}, want: ""},
+		{name: "Empty file", head: nil, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectCompression(tt.head); got != tt.want {
+				t.Errorf("detectCompression(%v) = %q, want %q", tt.head, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFileHeadCompressed(t *testing.T) {
+	app := NewApp()
+	dir := t.TempDir()
+
+	for _, compression := range []string{"", CompressionGzip, CompressionZlib, CompressionZstd} {
+		t.Run("compression="+compression, func(t *testing.T) {
+			path := writeCompressedFile(t, dir, "data-"+compression, compression, compressionTestCSV)
+
+			head, err := app.ReadFileHead(path, 2)
+			if err != nil {
+				t.Fatalf("ReadFileHead failed: %v", err)
+			}
+			if want := "id,name\n1,alice"; head != want {
+				t.Errorf("ReadFileHead = %q, want %q", head, want)
+			}
+		})
+	}
+}
+
+func TestPreviewFileGzip(t *testing.T) {
+	app := NewApp()
+	path := writeCompressedFile(t, t.TempDir(), "data.csv.gz", CompressionGzip, compressionTestCSV)
+	verbs := []VerbConfig{{Value: "cat", Enabled: true}}
+
+	result, err := app.PreviewFile(path, verbs, "", "--icsv", false, false, ",", "--ocsv")
+	if err != nil {
+		t.Fatalf("PreviewFile failed: %v", err)
+	}
+	if result != compressionTestCSV {
+		t.Errorf("PreviewFile output = %q, want %q", result, compressionTestCSV)
+	}
+
+	cmd, err := app.GetCommand(verbs, "", "--icsv", false, false, ",", "--ocsv", "file", path)
+	if err != nil {
+		t.Fatalf("GetCommand failed: %v", err)
+	}
+	if !strings.Contains(cmd, "--gzin") {
+		t.Errorf("GetCommand = %q, want it to contain --gzin", cmd)
+	}
+}
+
+func TestPreviewFilesMixedCompression(t *testing.T) {
+	app := NewApp()
+	dir := t.TempDir()
+	plain := writeCompressedFile(t, dir, "jan.csv", "", compressionTestCSV)
+	gzipped := writeCompressedFile(t, dir, "feb.csv.gz", CompressionGzip, compressionTestCSV)
+	verbs := []VerbConfig{{Value: "cat", Enabled: true}}
+
+	_, err := app.PreviewFiles([]string{plain, gzipped}, verbs, "", "--icsv", false, false, ",", "--ocsv")
+	if err == nil || !strings.Contains(err.Error(), "mix compression") {
+		t.Errorf("Expected a mixed compression error, got %v", err)
+	}
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	app := NewApp()
+
+	config, err := app.ParseCommand("mlr --icsv --zstdin --ojson cat /data/jan.csv.zst")
+	if err != nil {
+		t.Fatalf("ParseCommand failed: %v", err)
+	}
+	if config.Compression != CompressionZstd {
+		t.Errorf("Compression = %q, want %q", config.Compression, CompressionZstd)
+	}
+	if config.Options != "" {
+		t.Errorf("Options = %q, want the flag moved out of the options", config.Options)
+	}
+
+	cmd, err := app.GetConfigCommand(config)
+	if err != nil {
+		t.Fatalf("GetConfigCommand failed: %v", err)
+	}
+	if want := "mlr --icsv --zstdin --ojson cat /data/jan.csv.zst"; cmd != want {
+		t.Errorf("GetConfigCommand = %q, want %q", cmd, want)
+	}
+}
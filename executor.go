@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Execution policies, deciding what a new preview does to the previews
+// already waiting or running
+const (
+	PolicyFIFO       = "fifo"        // it waits its turn behind them
+	PolicyLatestWins = "latest-wins" // it cancels them, so only the newest one reports output
+)
+
+// ExecutionSettings configures the executor every Miller execution goes
+// through
+type ExecutionSettings struct {
+	MaxConcurrent int    `json:"maxConcurrent"` // executions allowed to run at once
+	Policy        string `json:"policy"`        // PolicyFIFO or PolicyLatestWins
+}
+
+// defaultExecutionSettings runs one execution at a time, because Miller keeps
+// process-global state (random seed, DSL globals, os.Stdout) that concurrent
+// executions would trample
+var defaultExecutionSettings = ExecutionSettings{MaxConcurrent: 1, Policy: PolicyLatestWins}
+
+func (s ExecutionSettings) validate() error {
+	if s.MaxConcurrent < 1 {
+		return fmt.Errorf("max concurrent executions must be at least 1, got %d", s.MaxConcurrent)
+	}
+	if s.Policy != PolicyFIFO && s.Policy != PolicyLatestWins {
+		return fmt.Errorf("unknown execution policy %q", s.Policy)
+	}
+	return nil
+}
+
+// executor admits Miller executions, at most MaxConcurrent at a time and the
+// rest in order of arrival
+type executor struct {
+	mu       sync.Mutex
+	settings ExecutionSettings
+	running  map[*execution]bool
+	waiting  []*execution // in order of arrival
+}
+
+// execution is one admission to the executor
+type execution struct {
+	ctx          context.Context
+	cancel       context.CancelFunc
+	supersedable bool          // a preview, which a newer one may cancel
+	admitted     chan struct{} // closed once it may run
+}
+
+func newExecutor(settings ExecutionSettings) *executor {
+	return &executor{settings: settings, running: map[*execution]bool{}}
+}
+
+// acquire waits until a new execution may run, and returns its context and a
+// func to call once it's over. Under PolicyLatestWins a supersedable
+// execution first cancels every other supersedable one. An execution
+// cancelled while it waits returns ErrPreviewCancelled without running.
+func (e *executor) acquire(parent context.Context, supersedable bool) (context.Context, func(), error) {
+	ctx, cancel := context.WithCancel(parent)
+	x := &execution{ctx: ctx, cancel: cancel, supersedable: supersedable, admitted: make(chan struct{})}
+
+	e.mu.Lock()
+	if supersedable {
+		e.supersedeLocked()
+	}
+	e.waiting = append(e.waiting, x)
+	e.admit()
+	e.mu.Unlock()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			cancel()
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			delete(e.running, x)
+			e.admit()
+		})
+	}
+
+	select {
+	case <-x.admitted:
+		return ctx, release, nil
+	case <-ctx.Done():
+	}
+
+	e.mu.Lock()
+	for i, waiting := range e.waiting {
+		if waiting == x {
+			e.waiting = append(e.waiting[:i], e.waiting[i+1:]...)
+			break
+		}
+	}
+	e.mu.Unlock()
+	// It may have been admitted just as it was cancelled
+	release()
+	return nil, nil, ErrPreviewCancelled
+}
+
+// supersede cancels every preview running or waiting under PolicyLatestWins,
+// for a newer one that doesn't need to run at all
+func (e *executor) supersede() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.supersedeLocked()
+}
+
+// supersedeLocked is supersede with e.mu held
+func (e *executor) supersedeLocked() {
+	if e.settings.Policy != PolicyLatestWins {
+		return
+	}
+	for x := range e.running {
+		if x.supersedable {
+			x.cancel()
+		}
+	}
+	for _, x := range e.waiting {
+		if x.supersedable {
+			x.cancel()
+		}
+	}
+}
+
+// admit lets waiting executions run while there are free slots, in order of
+// arrival. Cancelled ones are passed over; they leave the queue themselves.
+// e.mu must be held.
+func (e *executor) admit() {
+	for i := 0; i < len(e.waiting) && len(e.running) < e.settings.MaxConcurrent; {
+		x := e.waiting[i]
+		if x.ctx.Err() != nil {
+			i++
+			continue
+		}
+		e.waiting = append(e.waiting[:i], e.waiting[i+1:]...)
+		e.running[x] = true
+		close(x.admitted)
+	}
+}
+
+func (e *executor) getSettings() ExecutionSettings {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.settings
+}
+
+// setSettings changes the settings for executions yet to be admitted. Those
+// already running carry on, even beyond a lowered limit.
+func (e *executor) setSettings(settings ExecutionSettings) error {
+	if err := settings.validate(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.settings = settings
+	e.admit()
+	return nil
+}
+
+// GetExecutionSettings returns how Miller executions are scheduled
+func (a *App) GetExecutionSettings() (_ ExecutionSettings, err error) {
+	defer RecoverToError("GetExecutionSettings", &err)
+
+	return a.executor.getSettings(), nil
+}
+
+// SetExecutionSettings changes how many Miller executions may run at once and
+// what a new preview does to those already waiting or running. Allowing more
+// than one risks them corrupting each other's output through Miller's global
+// state.
+func (a *App) SetExecutionSettings(settings ExecutionSettings) (err error) {
+	defer RecoverToError("SetExecutionSettings", &err)
+
+	if err := a.executor.setSettings(settings); err != nil {
+		LogError(err, "Invalid execution settings", logrus.Fields{"max_concurrent": settings.MaxConcurrent, "policy": settings.Policy})
+		return err
+	}
+
+	LogInfo("Execution settings changed", logrus.Fields{"max_concurrent": settings.MaxConcurrent, "policy": settings.Policy})
+	return nil
+}
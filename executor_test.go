@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForWaiting waits until n executions are waiting in e
+func waitForWaiting(t *testing.T, e *executor, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		e.mu.Lock()
+		waiting := len(e.waiting)
+		e.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Waiting executions = %d, want %d", waiting, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestExecutorFIFO(t *testing.T) {
+	e := newExecutor(ExecutionSettings{MaxConcurrent: 1, Policy: PolicyFIFO})
+
+	_, endFirst, err := e.acquire(context.Background(), true)
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 1; i <= 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, end, err := e.acquire(context.Background(), true)
+			if err != nil {
+				t.Errorf("acquire %d failed: %v", i, err)
+				return
+			}
+			defer end()
+			if ctx.Err() != nil {
+				t.Errorf("Execution %d was cancelled under FIFO", i)
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+		}()
+		// Make the arrival order certain
+		waitForWaiting(t, e, i)
+	}
+
+	endFirst()
+	wg.Wait()
+	if fmt.Sprint(order) != "[1 2 3]" {
+		t.Errorf("Executions ran in order %v, want [1 2 3]", order)
+	}
+}
+
+func TestExecutorConcurrencyLimit(t *testing.T) {
+	for _, limit := range []int{1, 2, 4} {
+		t.Run(fmt.Sprintf("Limit %d", limit), func(t *testing.T) {
+			e := newExecutor(ExecutionSettings{MaxConcurrent: limit, Policy: PolicyFIFO})
+
+			var running, maxRunning atomic.Int32
+			var wg sync.WaitGroup
+			for i := 0; i < 12; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, end, err := e.acquire(context.Background(), true)
+					if err != nil {
+						t.Errorf("acquire failed: %v", err)
+						return
+					}
+					defer end()
+					now := running.Add(1)
+					for {
+						peak := maxRunning.Load()
+						if now <= peak || maxRunning.CompareAndSwap(peak, now) {
+							break
+						}
+					}
+					time.Sleep(5 * time.Millisecond)
+					running.Add(-1)
+				}()
+			}
+			wg.Wait()
+
+			if got := maxRunning.Load(); got != int32(limit) {
+				t.Errorf("At most %d executions ran at once, want %d", got, limit)
+			}
+		})
+	}
+}
+
+func TestExecutorLatestWins(t *testing.T) {
+	e := newExecutor(ExecutionSettings{MaxConcurrent: 1, Policy: PolicyLatestWins})
+
+	// A run holds the slot; previews don't supersede it
+	run, endRun, err := e.acquire(context.Background(), false)
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	olderErr := make(chan error)
+	go func() {
+		_, end, err := e.acquire(context.Background(), true)
+		if err == nil {
+			end()
+		}
+		olderErr <- err
+	}()
+	waitForWaiting(t, e, 1)
+
+	newerBegun := make(chan func())
+	go func() {
+		_, end, err := e.acquire(context.Background(), true)
+		if err != nil {
+			t.Errorf("acquire of the newest preview failed: %v", err)
+		}
+		newerBegun <- end
+	}()
+
+	if err := <-olderErr; !errors.Is(err, ErrPreviewCancelled) {
+		t.Errorf("Superseded waiting preview returned %v, want ErrPreviewCancelled", err)
+	}
+	if run.Err() != nil {
+		t.Errorf("A run must not be superseded by a preview")
+	}
+
+	endRun()
+	if end := <-newerBegun; end != nil {
+		end()
+	}
+}
+
+func TestExecutorCancelWhileWaiting(t *testing.T) {
+	e := newExecutor(ExecutionSettings{MaxConcurrent: 1, Policy: PolicyFIFO})
+	_, endFirst, _ := e.acquire(context.Background(), false)
+
+	parent, cancel := context.WithCancel(context.Background())
+	waitErr := make(chan error)
+	go func() {
+		_, _, err := e.acquire(parent, false)
+		waitErr <- err
+	}()
+	waitForWaiting(t, e, 1)
+	cancel()
+
+	if err := <-waitErr; !errors.Is(err, ErrPreviewCancelled) {
+		t.Errorf("Cancelled waiting execution returned %v, want ErrPreviewCancelled", err)
+	}
+	waitForWaiting(t, e, 0)
+
+	// The slot is still the first execution's, and free again once it ends
+	endFirst()
+	_, end, err := e.acquire(context.Background(), false)
+	if err != nil {
+		t.Fatalf("acquire after the queue emptied failed: %v", err)
+	}
+	end()
+}
+
+func TestSetExecutionSettings(t *testing.T) {
+	app := NewApp()
+
+	tests := []struct {
+		name     string
+		settings ExecutionSettings
+		wantErr  bool
+	}{
+		{name: "FIFO", settings: ExecutionSettings{MaxConcurrent: 2, Policy: PolicyFIFO}},
+		{name: "Latest wins", settings: ExecutionSettings{MaxConcurrent: 1, Policy: PolicyLatestWins}},
+		{name: "No slots", settings: ExecutionSettings{MaxConcurrent: 0, Policy: PolicyFIFO}, wantErr: true},
+		{name: "Unknown policy", settings: ExecutionSettings{MaxConcurrent: 1, Policy: "random"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := app.GetExecutionSettings()
+			err := app.SetExecutionSettings(tt.settings)
+			after, _ := app.GetExecutionSettings()
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error for %+v", tt.settings)
+				}
+				if after != before {
+					t.Errorf("Settings = %+v after a rejected change, want %+v", after, before)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetExecutionSettings failed: %v", err)
+			}
+			if after != tt.settings {
+				t.Errorf("Settings = %+v, want %+v", after, tt.settings)
+			}
+		})
+	}
+}
+
+func TestRaisingLimitAdmitsWaiting(t *testing.T) {
+	e := newExecutor(ExecutionSettings{MaxConcurrent: 1, Policy: PolicyFIFO})
+	_, endFirst, _ := e.acquire(context.Background(), false)
+	defer endFirst()
+
+	admitted := make(chan func())
+	go func() {
+		_, end, _ := e.acquire(context.Background(), false)
+		admitted <- end
+	}()
+	waitForWaiting(t, e, 1)
+
+	if err := e.setSettings(ExecutionSettings{MaxConcurrent: 2, Policy: PolicyFIFO}); err != nil {
+		t.Fatalf("setSettings failed: %v", err)
+	}
+	select {
+	case end := <-admitted:
+		end()
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the waiting execution to run once the limit was raised")
+	}
+}
+
+func TestConcurrentPreviewsKeepTheirOutput(t *testing.T) {
+	app := NewApp()
+	if err := app.SetExecutionSettings(ExecutionSettings{MaxConcurrent: 1, Policy: PolicyFIFO}); err != nil {
+		t.Fatalf("SetExecutionSettings failed: %v", err)
+	}
+	verbs := []VerbConfig{{Value: "put '$n = NR'", Enabled: true}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			input := fmt.Sprintf("a,b\n%d,x\n%d,y\n", i, i)
+			want := fmt.Sprintf("a,b,n\n%d,x,1\n%d,y,2\n", i, i)
+
+			result, err := app.Preview(input, verbs, "", "--icsv", false, false, ",", "--ocsv")
+			if err != nil {
+				t.Errorf("Preview %d failed: %v", i, err)
+				return
+			}
+			if result != want {
+				t.Errorf("Preview %d output = %q, want %q", i, result, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
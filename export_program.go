@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+)
+
+// millerModule is the module the exported programs build against
+const millerModule = "github.com/johnkerl/miller/v6"
+
+// defaultMillerVersion is the Miller version exported programs require when
+// this build doesn't record the one it was built with
+const defaultMillerVersion = "v6.15.0"
+
+// ExportGoProgram writes a standalone Go program running config's pipeline,
+// main.go and go.mod, into dir. The program reads the files named on its
+// command line, or standard input, and writes to standard output or the file
+// given with -o.
+func (a *App) ExportGoProgram(config Config, dir string) (err error) {
+	defer RecoverToError("ExportGoProgram", &err)
+
+	mainSource, goMod, err := a.goProgram(config, filepath.Base(dir))
+	if err != nil {
+		LogError(err, "Failed to generate Go program", logrus.Fields{"dir": dir})
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		LogError(err, "Failed to create program directory", logrus.Fields{"dir": dir})
+		return err
+	}
+	for name, content := range map[string][]byte{"main.go": mainSource, "go.mod": goMod} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			LogError(err, "Failed to write program file", logrus.Fields{"path": path})
+			return err
+		}
+	}
+
+	LogInfo("Go program exported", logrus.Fields{"dir": dir})
+	return nil
+}
+
+// goProgram generates the main.go and go.mod of the program ExportGoProgram
+// writes, with the module named after name
+func (a *App) goProgram(config Config, name string) (mainSource, goMod []byte, err error) {
+	// Only a compression chosen in the config is kept. One detected from the
+	// files is left to Miller, since the program reads other files.
+	compression := ""
+	if config.InputMode == "file" {
+		compression = config.Compression
+	}
+
+	// Make sure the pipeline parses before writing a program that can't run it
+	if _, _, err := a.buildPipeline(config.Verbs, config.Options, config.InputFormat, config.Ragged, config.Headerless, config.FieldSeparator, config.OutputFormat, compression); err != nil {
+		return nil, nil, err
+	}
+	args, err := a.constructArgs(config.Verbs, config.Options, config.InputFormat, config.Ragged, config.Headerless, config.FieldSeparator, config.OutputFormat, compression)
+	if err != nil {
+		return nil, nil, err
+	}
+	command, err := a.commandString(ShellPOSIX, config.Verbs, config.Options, config.InputFormat, config.Ragged, config.Headerless, config.FieldSeparator, config.OutputFormat, compression, "text", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var source bytes.Buffer
+	err = goProgramTemplate.Execute(&source, struct {
+		Command string
+		Args    []string
+	}{Command: command, Args: args.flatten()})
+	if err != nil {
+		return nil, nil, err
+	}
+	mainSource, err = format.Source(source.Bytes())
+	if err != nil {
+		return nil, nil, fmt.Errorf("generated program does not parse: %w", err)
+	}
+
+	goMod = []byte(fmt.Sprintf("module %s\n\ngo 1.24\n\nrequire %s %s\n", programModuleName(name), millerModule, millerVersion()))
+	return mainSource, goMod, nil
+}
+
+// programModuleName turns a directory name into a module name, falling back
+// to "pipeline"
+func programModuleName(name string) string {
+	name = strings.Trim(invalidModuleChars.ReplaceAllString(strings.ToLower(name), "-"), "-._")
+	if name == "" {
+		return "pipeline"
+	}
+	return name
+}
+
+// invalidModuleChars are the runs of characters module names can't hold
+var invalidModuleChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// millerVersion returns the version of Miller this build uses, so that the
+// exported programs behave the way the previews do
+func millerVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == millerModule && strings.HasPrefix(dep.Version, "v6.") {
+				return dep.Version
+			}
+		}
+	}
+	return defaultMillerVersion
+}
+
+// goProgramTemplate is main.go of the exported programs. It runs the pipeline
+// the way mlr does: the same reader, chain and writer as
+// runMillerTransformation, without the preview's cancelling and limits.
+var goProgramTemplate = template.Must(template.New("main.go").Funcs(template.FuncMap{
+	"comment": func(text string) string {
+		return strings.ReplaceAll(text, "\n", "\n//\t")
+	},
+}).Parse(`// This program was exported from mlr-desktop. It runs the pipeline
+//
+//	{{comment .Command}}
+//
+// on the files named on its command line, or on standard input if there are
+// none, and writes to standard output or to the file given with -o.
+//
+// Build it with
+//
+//	go mod tidy
+//	go build
+package main
+
+import (
+	"bufio"
+	"container/list"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/johnkerl/miller/v6/pkg/cli"
+	"github.com/johnkerl/miller/v6/pkg/climain"
+	"github.com/johnkerl/miller/v6/pkg/input"
+	"github.com/johnkerl/miller/v6/pkg/output"
+	"github.com/johnkerl/miller/v6/pkg/transformers"
+	"github.com/johnkerl/miller/v6/pkg/types"
+)
+
+// mlrArgs are the arguments mlr runs the pipeline with
+var mlrArgs = []string{
+{{- range .Args}}
+	{{printf "%q" .}},
+{{- end}}
+}
+
+func main() {
+	outputPath := flag.String("o", "", "write to this file instead of standard output")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-o output] [file ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(flag.Args(), *outputPath); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		os.Exit(1)
+	}
+}
+
+// run reads fileNames through the pipeline into outputPath, or standard
+// output if it is empty
+func run(fileNames []string, outputPath string) (err error) {
+	options, recordTransformers, err := climain.ParseCommandLine(append([]string{"mlr"}, mlrArgs...))
+	if err != nil {
+		return err
+	}
+
+	// Like mlr: files given with --from unless there are others, standard
+	// input if there are none, and no input at all with -n
+	if len(fileNames) == 0 {
+		fileNames = append([]string{}, options.FileNames...)
+	}
+	if options.NoInput {
+		fileNames = nil
+	}
+
+	var outputStream io.Writer = os.Stdout
+	outputIsStdout := true
+	if outputPath != "" {
+		file, err := os.Create(outputPath)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		outputStream = file
+		outputIsStdout = false
+	}
+
+	return runPipeline(fileNames, options, recordTransformers, outputStream, outputIsStdout)
+}
+
+// runPipeline streams records from the reader through the transformer chain
+// to the writer
+func runPipeline(
+	fileNames []string,
+	options *cli.TOptions,
+	recordTransformers []transformers.IRecordTransformer,
+	outputStream io.Writer,
+	outputIsStdout bool,
+) error {
+	recordReader, err := input.Create(&options.ReaderOptions, options.ReaderOptions.RecordsPerBatch)
+	if err != nil {
+		return fmt.Errorf("error creating record reader: %w", err)
+	}
+	recordWriter, err := output.Create(&options.WriterOptions)
+	if err != nil {
+		return fmt.Errorf("error creating record writer: %w", err)
+	}
+
+	initialContext := types.NewContext()
+	readerChannel := make(chan *list.List, 2)         // reader -> transformer
+	writerChannel := make(chan *list.List, 1)         // transformer -> writer
+	inputErrorChannel := make(chan error, 1)          // reader errors
+	doneWritingChannel := make(chan bool, 1)          // writer done signal
+	dataProcessingErrorChannel := make(chan bool, 1)  // data processing errors
+	downstreamDoneChannel := make(chan bool, 1)       // tells the reader to stop early, e.g. after head
+	bufferedOutputStream := bufio.NewWriter(outputStream)
+
+	go recordReader.Read(fileNames, *initialContext, readerChannel, inputErrorChannel, downstreamDoneChannel)
+	go transformers.ChainTransformer(readerChannel, downstreamDoneChannel, recordTransformers, writerChannel, options)
+	go output.ChannelWriter(writerChannel, recordWriter, &options.WriterOptions, doneWritingChannel, dataProcessingErrorChannel, bufferedOutputStream, outputIsStdout)
+
+	select {
+	case err := <-inputErrorChannel:
+		return err
+	case <-dataProcessingErrorChannel:
+		// The writer has printed what went wrong
+		bufferedOutputStream.Flush()
+		return errors.New("exiting due to data error")
+	case <-doneWritingChannel:
+	}
+	return bufferedOutputStream.Flush()
+}
+`))
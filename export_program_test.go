@@ -0,0 +1,218 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// exportedArgs returns the mlrArgs an exported main.go holds
+func exportedArgs(t *testing.T, path string) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Exported main.go does not parse: %v", err)
+	}
+	var args []string
+	found := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok || spec.Names[0].Name != "mlrArgs" {
+			return true
+		}
+		found = true
+		for _, elt := range spec.Values[0].(*ast.CompositeLit).Elts {
+			arg, err := strconv.Unquote(elt.(*ast.BasicLit).Value)
+			if err != nil {
+				t.Fatalf("Bad argument literal: %v", err)
+			}
+			args = append(args, arg)
+		}
+		return false
+	})
+	if !found {
+		t.Fatalf("Exported main.go has no mlrArgs")
+	}
+	return args
+}
+
+func TestExportGoProgram(t *testing.T) {
+	app := NewApp()
+
+	tests := []struct {
+		name       string
+		config     Config
+		dir        string
+		wantArgs   []string
+		wantModule string
+	}{
+		{
+			name: "Formats and verbs",
+			config: Config{
+				InputFormat:  "--icsv",
+				OutputFormat: "--ojson",
+				Verbs: []VerbConfig{
+					{Value: "sort -nr price", Enabled: true},
+					{Value: "cat", Enabled: false},
+					{Value: "head -n 2", Enabled: true},
+				},
+			},
+			dir:        "My Pipeline",
+			wantArgs:   []string{"--icsv", "--ojson", "sort", "-nr", "price", "then", "head", "-n", "2"},
+			wantModule: "my-pipeline",
+		},
+		{
+			name: "Expression over several lines",
+			config: Config{
+				InputFormat: "--icsv",
+				Verbs:       []VerbConfig{{Value: "put '$y = 1;\n$z = \"*/\"'", Enabled: true}},
+			},
+			dir:        "put",
+			wantArgs:   []string{"--icsv", "put", "$y = 1;\n$z = \"*/\""},
+			wantModule: "put",
+		},
+		{
+			name: "Chosen compression kept, detected one left out",
+			config: Config{
+				InputMode:   "file",
+				InputPaths:  []string{"in.csv.gz"},
+				Compression: CompressionGzip,
+				Verbs:       []VerbConfig{{Value: "cat", Enabled: true}},
+			},
+			dir:        "___",
+			wantArgs:   []string{"--gzin", "cat"},
+			wantModule: "pipeline",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), tt.dir)
+			if err := app.ExportGoProgram(tt.config, dir); err != nil {
+				t.Fatalf("ExportGoProgram failed: %v", err)
+			}
+
+			if args := exportedArgs(t, filepath.Join(dir, "main.go")); !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("mlrArgs = %q, want %q", args, tt.wantArgs)
+			}
+			goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+			if err != nil {
+				t.Fatalf("Failed to read go.mod: %v", err)
+			}
+			if !strings.HasPrefix(string(goMod), "module "+tt.wantModule+"\n") {
+				t.Errorf("go.mod names the wrong module:\n%s", goMod)
+			}
+			if !regexp.MustCompile(`(?m)^require github\.com/johnkerl/miller/v6 v6\.`).Match(goMod) {
+				t.Errorf("go.mod does not require Miller 6:\n%s", goMod)
+			}
+		})
+	}
+}
+
+func TestExportGoProgramRejectsBadPipelines(t *testing.T) {
+	app := NewApp()
+
+	for _, verb := range []string{"frobnicate", "put '$x = "} {
+		dir := filepath.Join(t.TempDir(), "pipeline")
+		config := Config{Verbs: []VerbConfig{{Value: verb, Enabled: true}}}
+		if err := app.ExportGoProgram(config, dir); err == nil {
+			t.Errorf("Expected exporting %q to fail", verb)
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("Expected nothing written for %q", verb)
+		}
+	}
+}
+
+// TestExportedGoProgramRuns builds an exported program against this module's
+// own dependencies and checks that it writes what the preview shows
+func TestExportedGoProgramRuns(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a program")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go tool")
+	}
+
+	app := NewApp()
+	input := "sku,price\na,3\nb,10\nc,7\n"
+	config := Config{
+		InputFormat:  "--icsv",
+		OutputFormat: "--ojson",
+		Verbs:        []VerbConfig{{Value: "sort -nr price", Enabled: true}, {Value: "head -n 2", Enabled: true}},
+	}
+	want, err := app.Preview(input, config.Verbs, config.Options, config.InputFormat, config.Ragged, config.Headerless, config.FieldSeparator, config.OutputFormat)
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "pipeline")
+	if err := app.ExportGoProgram(config, dir); err != nil {
+		t.Fatalf("ExportGoProgram failed: %v", err)
+	}
+
+	// Build with this module's requirements, which go.sum already covers
+	goMod, err := os.ReadFile("go.mod")
+	if err != nil {
+		t.Fatalf("Failed to read go.mod: %v", err)
+	}
+	goMod = regexp.MustCompile(`(?m)^module .*$`).ReplaceAll(goMod, []byte("module pipeline"))
+	goSum, err := os.ReadFile("go.sum")
+	if err != nil {
+		t.Fatalf("Failed to read go.sum: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), goMod, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.sum"), goSum, 0644); err != nil {
+		t.Fatal(err)
+	}
+	build := exec.Command(goTool, "build", "-o", "pipeline.exe", ".")
+	build.Dir = dir
+	build.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("Exported program does not build: %v\n%s", err, out)
+	}
+	program := filepath.Join(dir, "pipeline.exe")
+
+	// From a file into a file
+	inputPath := filepath.Join(dir, "in.csv")
+	outputPath := filepath.Join(dir, "out.json")
+	if err := os.WriteFile(inputPath, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(program, "-o", outputPath, inputPath).CombinedOutput(); err != nil {
+		t.Fatalf("Exported program failed: %v\n%s", err, out)
+	}
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("Exported program wrote %q, the preview shows %q", got, want)
+	}
+
+	// From standard input to standard output
+	run := exec.Command(program)
+	run.Stdin = strings.NewReader(input)
+	got, err = run.Output()
+	if err != nil {
+		t.Fatalf("Exported program failed on standard input: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("Exported program printed %q, the preview shows %q", got, want)
+	}
+
+	// Missing input fails
+	if err := exec.Command(program, filepath.Join(dir, "missing.csv")).Run(); err == nil {
+		t.Errorf("Expected a missing input file to fail")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/johnkerl/miller/v6/pkg/cli"
+)
+
+// formatNames maps the file format names Miller's -i, -o and --io flags take
+// to the name Config's format flags are spelled with
+var formatNames = map[string]string{
+	"csv":      "csv",
+	"csvlite":  "csvlite",
+	"tsv":      "tsv",
+	"tsvlite":  "tsvlite",
+	"json":     "json",
+	"jsonl":    "jsonl",
+	"dkvp":     "dkvp",
+	"nidx":     "nidx",
+	"xtab":     "xtab",
+	"pprint":   "pprint",
+	"markdown": "md",
+	"md":       "md",
+}
+
+// shorthandFormats maps the letters of Miller's --x2y flags to format names.
+// b is barred pprint, which only goes on the output side.
+var shorthandFormats = map[byte]string{
+	'c': "csv",
+	't': "tsv",
+	'j': "json",
+	'l': "jsonl",
+	'd': "dkvp",
+	'n': "nidx",
+	'x': "xtab",
+	'p': "pprint",
+	'm': "md",
+	'b': "pprint",
+}
+
+// bothFormatFlags are the flags that set the input and output format alike,
+// like --csv, -j or --csvlite, each with the format it sets
+var bothFormatFlags = millerBothFormatFlags()
+
+// millerBothFormatFlags asks Miller's flag table which of the flags named
+// after a format, or after its letter in the --x2y flags, do just what that
+// format's input and output flags do together
+func millerBothFormatFlags() map[string]string {
+	flags := map[string]string{}
+	for millerName, name := range formatNames {
+		want, ok := parseMainFlags(inputFormatFlag(name), outputFormatFlag(name))
+		if !ok {
+			continue
+		}
+		candidates := []string{"--" + millerName}
+		for letter, letterName := range shorthandFormats {
+			if letterName == name {
+				candidates = append(candidates, "-"+string(letter))
+			}
+		}
+		for _, flag := range candidates {
+			if got, ok := parseMainFlags(flag); ok && reflect.DeepEqual(got, want) {
+				flags[flag] = name
+			}
+		}
+	}
+	return flags
+}
+
+// parseMainFlags parses flags, each a main flag without arguments, into
+// Miller's default options. ok is false if Miller doesn't take one of them
+// alone. Flags that take arguments mustn't be passed: Miller exits on them.
+func parseMainFlags(flags ...string) (_ *cli.TOptions, ok bool) {
+	options := cli.DefaultOptions()
+	for argi := 0; argi < len(flags); {
+		start := argi
+		if !cli.FLAG_TABLE.Parse(flags, start+1, &argi, options) || argi != start+1 {
+			return nil, false
+		}
+	}
+	return options, true
+}
+
+// inputFormatFlag and outputFormatFlag spell a format the way Config holds it
+func inputFormatFlag(name string) string  { return "--i" + name }
+func outputFormatFlag(name string) string { return "--o" + name }
+
+// formatFlagGroup reads a main flag, with its arguments, as a choice of input
+// and output formats. Either format is "" if the flag leaves it alone, and
+// extra holds flags the choice implies besides, like --barred for --c2b. ok is
+// false if the flag doesn't choose a format.
+func formatFlagGroup(group []string) (inputFormat, outputFormat string, extra []string, ok bool) {
+	flag := group[0]
+	if len(group) == 2 {
+		name, known := formatNames[group[1]]
+		if !known {
+			return "", "", nil, false
+		}
+		switch flag {
+		case "--io":
+			return inputFormatFlag(name), outputFormatFlag(name), nil, true
+		case "-i":
+			return inputFormatFlag(name), "", nil, true
+		case "-o":
+			return "", outputFormatFlag(name), nil, true
+		}
+		return "", "", nil, false
+	}
+	if len(group) != 1 {
+		return "", "", nil, false
+	}
+
+	if name, known := bothFormatFlags[flag]; known {
+		return inputFormatFlag(name), outputFormatFlag(name), nil, true
+	}
+	if name, known := formatNames[strings.TrimPrefix(flag, "--i")]; known && strings.HasPrefix(flag, "--i") {
+		return inputFormatFlag(name), "", nil, true
+	}
+	if name, known := formatNames[strings.TrimPrefix(flag, "--o")]; known && strings.HasPrefix(flag, "--o") {
+		return "", outputFormatFlag(name), nil, true
+	}
+
+	// --c2p and the like
+	if len(flag) == 5 && strings.HasPrefix(flag, "--") && flag[3] == '2' {
+		in, inKnown := shorthandFormats[flag[2]]
+		out, outKnown := shorthandFormats[flag[4]]
+		if !inKnown || !outKnown || flag[2] == 'm' || flag[2] == 'b' {
+			return "", "", nil, false
+		}
+		if flag[4] == 'b' {
+			extra = []string{"--barred"}
+		}
+		return inputFormatFlag(in), outputFormatFlag(out), extra, true
+	}
+	return "", "", nil, false
+}
+
+// canonicalFormatFlag returns the way Config spells an input format flag, or
+// an output format flag if output is set. Flags that don't choose just that
+// one format, like --csv, are returned as they are.
+func canonicalFormatFlag(flag string, output bool) string {
+	if flag == "" {
+		return ""
+	}
+	inputFormat, outputFormat, extra, ok := formatFlagGroup([]string{flag})
+	switch {
+	case !ok || len(extra) > 0:
+		return flag
+	case output && inputFormat == "":
+		return outputFormat
+	case !output && outputFormat == "":
+		return inputFormat
+	}
+	return flag
+}
@@ -5,7 +5,7 @@ import VerbBuilder from './components/VerbBuilder';
 import OutputPreview from './components/OutputPreview';
 import ErrorBoundary from './components/ErrorBoundary';
 import logger from './utils/logger';
-import { Preview, PreviewFile, SaveConfig, LoadConfig, ExportGoProgram, ReadFileHead, SaveLastState, LoadLastState, GetCommand, SaveOutput, ParseCommand } from '../wailsjs/go/main/App';
+import { PreviewWithProfile, PreviewFilesWithProfile, SaveConfig, LoadConfig, ExportGoProgram, ReadFileHead, SaveLastState, LoadLastState, GetCommand, SaveOutput, ParseShellSnippet, ResolveInputFiles } from '../wailsjs/go/main/App';
 
 const DEFAULT_INPUT_CONTENT = `SKU,Product Name,Price,Barcode
 FRO-010,Organic Free-Range Eggs (Dozen),5.99,5012345678901
@@ -30,9 +30,13 @@ function App() {
     const [outputFormat, setOutputFormat] = useState('');
     const [verbs, setVerbs] = useState([]);
     const [output, setOutput] = useState('');
+    const [profile, setProfile] = useState([]);
     const [error, setError] = useState('');
+    const [errorVerbIndex, setErrorVerbIndex] = useState(-1);
     const [command, setCommand] = useState('');
     const [filePreview, setFilePreview] = useState('');
+    const [resolvedFiles, setResolvedFiles] = useState([]);
+    const [resolveError, setResolveError] = useState('');
 
     // Load last state on startup
     useEffect(() => {
@@ -76,10 +80,39 @@ function App() {
         }
     }, [inputMode, inputValue]);
 
+    // Show which files a glob pattern or directory stands for
+    useEffect(() => {
+        if (inputMode !== 'file' || !inputValue || !inputValue.trim()) {
+            setResolvedFiles([]);
+            setResolveError('');
+            return;
+        }
+        let current = true;
+        const timer = setTimeout(async () => {
+            try {
+                const files = await ResolveInputFiles([inputValue]);
+                if (!current) return;
+                setResolvedFiles(files || []);
+                setResolveError('');
+            } catch (err) {
+                if (!current) return;
+                logger.error("Error resolving input files", { path: inputValue, error: err });
+                setResolvedFiles([]);
+                setResolveError(String(err));
+            }
+        }, 300); // Debounce while the path is typed
+        return () => {
+            current = false;
+            clearTimeout(timer);
+        };
+    }, [inputMode, inputValue]);
+
     const updatePreview = useCallback(async () => {
         setError('');
+        setErrorVerbIndex(-1);
         if (!inputValue) {
             setOutput('');
+            setProfile([]);
             setCommand('');
             return;
         }
@@ -88,6 +121,7 @@ function App() {
         const hasEnabledVerbs = verbs.some(verb => verb.enabled);
         if (!hasEnabledVerbs) {
             setOutput('');
+            setProfile([]);
             setCommand('');
             return;
         }
@@ -97,20 +131,30 @@ function App() {
             if (inputMode === 'file') {
                 // Process the file directly without reading into memory
                 if (!inputValue.trim()) return;
-                result = await PreviewFile(inputValue, verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat);
+                result = await PreviewFilesWithProfile([inputValue], verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat);
             } else {
                 // Process text content
-                result = await Preview(inputValue, verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat);
+                result = await PreviewWithProfile(inputValue, verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat);
             }
 
-            setOutput(result);
+            setOutput(result.output);
+            setProfile(result.profile || []);
             const cmd = await GetCommand(verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat, inputMode, inputValue);
             setCommand(cmd);
             // Auto-save state on success
             SaveLastState({ inputPath: inputValue, inputMode, inputFormat, ragged, headerless, fieldSeparator, outputFormat, verbs, options });
         } catch (err) {
-            logger.logError(err, { context: inputMode === 'file' ? 'PreviewFile' : 'Preview', verbs, inputFormat, outputFormat });
-            setError(String(err));
+            // A newer preview superseded this one; its result will arrive instead
+            if (String(err).includes('preview cancelled')) return;
+            setProfile([]);
+            logger.logError(err, { context: inputMode === 'file' ? 'PreviewFilesWithProfile' : 'PreviewWithProfile', verbs, inputFormat, outputFormat });
+            // Pipeline errors arrive as objects naming the phase and the verb at fault
+            if (err && typeof err === 'object') {
+                setError(err.error || err.message);
+                setErrorVerbIndex(err.verbIndex ?? -1);
+            } else {
+                setError(String(err));
+            }
         }
     }, [inputValue, inputMode, verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat]);
 
@@ -132,11 +176,12 @@ function App() {
     };
 
     const handleImportCommand = async () => {
-        const commandStr = prompt("Paste your mlr command:");
+        const commandStr = prompt("Paste your mlr command or shell pipeline:");
         if (!commandStr) return;
 
         try {
-            const config = await ParseCommand(commandStr);
+            const imported = await ParseShellSnippet(commandStr);
+            const config = imported.config;
 
             // Update all state from the parsed config
             setInputFormat(config.inputFormat || '');
@@ -156,8 +201,18 @@ function App() {
             logger.info("Command imported successfully", {
                 verbs_count: config.verbs?.length,
                 input_mode: config.inputMode,
-                has_input_path: !!config.inputPath
+                has_input_path: !!config.inputPath,
+                warnings_count: imported.warnings?.length || 0
             });
+
+            // Tell the user what the import left behind
+            const notes = [...(imported.warnings || [])];
+            if (imported.outputPath) {
+                notes.push(`The command ${imported.append ? 'appends' : 'writes'} its output to ${imported.outputPath}`);
+            }
+            if (notes.length > 0) {
+                alert("Command imported.\n\n" + notes.join("\n"));
+            }
         } catch (err) {
             logger.logError(err, { context: 'ImportCommand', command: commandStr });
             alert("Error parsing command: " + err);
@@ -175,7 +230,9 @@ function App() {
         setOutputFormat('');
         setVerbs([]);
         setOutput('');
+        setProfile([]);
         setError('');
+        setErrorVerbIndex(-1);
         setCommand('');
         logger.info("Application state cleared");
     };
@@ -225,6 +282,8 @@ function App() {
                         mode={inputMode}
                         inputValue={inputValue}
                         filePreview={filePreview}
+                        resolvedFiles={resolvedFiles}
+                        resolveError={resolveError}
                         options={options}
                         inputFormat={inputFormat}
                         ragged={ragged}
@@ -241,9 +300,10 @@ function App() {
                         }}
                         onModeChange={setInputMode}
                     />
-                    <VerbBuilder verbs={verbs} setVerbs={setVerbs} />
+                    <VerbBuilder verbs={verbs} setVerbs={setVerbs} errorVerbIndex={errorVerbIndex} />
                     <OutputPreview
                         output={output}
+                        profile={profile}
                         error={error}
                         outputFormat={outputFormat}
                         onOutputFormatChange={setOutputFormat}
@@ -3,7 +3,7 @@ import React, { useState } from 'react';
 import { SelectInputFile } from '../../wailsjs/go/main/App';
 
 
-export default function InputSection({ onInputChange, onModeChange, mode, inputValue, filePreview, options, inputFormat, ragged, headerless, fieldSeparator }) {
+export default function InputSection({ onInputChange, onModeChange, mode, inputValue, filePreview, resolvedFiles, resolveError, options, inputFormat, ragged, headerless, fieldSeparator }) {
     // We use props for state now, but we can keep local state for immediate feedback if needed.
     // However, for controlled components, we should rely on props.
 
@@ -91,6 +91,13 @@ export default function InputSection({ onInputChange, onModeChange, mode, inputV
                             <option value="--itsv">TSV (--itsv)</option>
                             <option value="--ijson">JSON (--ijson)</option>
                             <option value="--ijsonl">NDJSON (--ijsonl)</option>
+                            <option value="--icsvlite">CSV lite (--icsvlite)</option>
+                            <option value="--itsvlite">TSV lite (--itsvlite)</option>
+                            <option value="--idkvp">DKVP (--idkvp)</option>
+                            <option value="--inidx">NIDX (--inidx)</option>
+                            <option value="--ixtab">XTAB (--ixtab)</option>
+                            <option value="--ipprint">Pretty Print (--ipprint)</option>
+                            <option value="--imd">Markdown (--imd)</option>
                         </select>
                     </div>
                 </div>
@@ -119,6 +126,33 @@ export default function InputSection({ onInputChange, onModeChange, mode, inputV
                             Browse...
                         </button>
                     </div>
+                    {(resolveError || resolvedFiles.length > 1 || (resolvedFiles.length === 1 && resolvedFiles[0] !== inputValue)) && (
+                        <div style={{ marginTop: '0.5rem' }}>
+                            <label style={{ display: 'block', fontSize: '0.8rem', marginBottom: '0.25rem', color: '#ccc' }}>
+                                {resolveError ? 'Input files:' : `Input files (${resolvedFiles.length}):`}
+                            </label>
+                            {resolveError ? (
+                                <div style={{ color: '#f44336', fontSize: '0.9rem' }}>{resolveError}</div>
+                            ) : (
+                                <ul
+                                    style={{
+                                        maxHeight: '120px',
+                                        overflowY: 'auto',
+                                        margin: 0,
+                                        padding: '0.25rem 0.5rem 0.25rem 1.5rem',
+                                        fontFamily: 'monospace',
+                                        fontSize: '0.85rem',
+                                        textAlign: 'left',
+                                        backgroundColor: '#f5f5f5',
+                                        color: '#333',
+                                        border: '1px solid #ddd'
+                                    }}
+                                >
+                                    {resolvedFiles.map(file => <li key={file}>{file}</li>)}
+                                </ul>
+                            )}
+                        </div>
+                    )}
                     {filePreview && (
                         <div style={{ marginTop: '0.5rem' }}>
                             <label style={{ display: 'block', fontSize: '0.8rem', marginBottom: '0.25rem', color: '#ccc' }}>
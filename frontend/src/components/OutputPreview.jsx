@@ -1,6 +1,6 @@
 import React from 'react';
 
-export default function OutputPreview({ output, error, outputFormat, onOutputFormatChange, command, onSave }) {
+export default function OutputPreview({ output, profile, error, outputFormat, onOutputFormatChange, command, onSave }) {
     return (
         <div className="output-preview" style={{ padding: '1rem', border: '1px solid #ccc' }}>
             <div style={{ display: 'flex', justifyContent: 'space-between', alignItems: 'center', marginBottom: '0.5rem' }}>
@@ -19,6 +19,12 @@ export default function OutputPreview({ output, error, outputFormat, onOutputFor
                             <option value="--otsv">TSV (--otsv)</option>
                             <option value="--ojson">JSON (--ojson)</option>
                             <option value="--ojsonl">NDJSON (--ojsonl)</option>
+                            <option value="--ocsvlite">CSV lite (--ocsvlite)</option>
+                            <option value="--otsvlite">TSV lite (--otsvlite)</option>
+                            <option value="--odkvp">DKVP (--odkvp)</option>
+                            <option value="--onidx">NIDX (--onidx)</option>
+                            <option value="--oxtab">XTAB (--oxtab)</option>
+                            <option value="--omd">Markdown (--omd)</option>
                         </select>
                     </div>
                     <button onClick={onSave} disabled={!output} style={{ padding: '0.25rem 0.5rem', cursor: 'pointer' }}>
@@ -47,6 +53,28 @@ export default function OutputPreview({ output, error, outputFormat, onOutputFor
                     }}
                 />
             )}
+            {!error && profile && profile.length > 0 && (
+                <table style={{ marginTop: '0.5rem', fontSize: '0.8rem', fontFamily: 'monospace', borderCollapse: 'collapse' }}>
+                    <thead>
+                        <tr>
+                            <th style={{ textAlign: 'left', paddingRight: '1rem' }}>Verb</th>
+                            <th style={{ textAlign: 'right', paddingRight: '1rem' }}>Time (ms)</th>
+                            <th style={{ textAlign: 'right', paddingRight: '1rem' }}>Records in</th>
+                            <th style={{ textAlign: 'right' }}>Records out</th>
+                        </tr>
+                    </thead>
+                    <tbody>
+                        {profile.map((verb, i) => (
+                            <tr key={i}>
+                                <td style={{ paddingRight: '1rem' }}>{verb.verb}</td>
+                                <td style={{ textAlign: 'right', paddingRight: '1rem' }}>{verb.wallTimeMs.toFixed(1)}</td>
+                                <td style={{ textAlign: 'right', paddingRight: '1rem' }}>{verb.recordsIn}</td>
+                                <td style={{ textAlign: 'right' }}>{verb.recordsOut}</td>
+                            </tr>
+                        ))}
+                    </tbody>
+                </table>
+            )}
             {command && (
                 <div style={{ marginTop: '1rem' }}>
                     <h4 style={{ margin: '0 0 0.5rem 0', textAlign: 'left' }}>Generated Command</h4>
@@ -1,6 +1,6 @@
 import React, { useState } from 'react';
 
-export default function VerbBuilder({ verbs, setVerbs }) {
+export default function VerbBuilder({ verbs, setVerbs, errorVerbIndex = -1 }) {
     const [newVerb, setNewVerb] = useState('');
     const [editingIndex, setEditingIndex] = useState(-1);
     const [editingValue, setEditingValue] = useState('');
@@ -70,12 +70,14 @@ export default function VerbBuilder({ verbs, setVerbs }) {
                         style={{
                             display: 'flex',
                             alignItems: 'center',
-                            background: '#f0f0f0',
+                            background: index === errorVerbIndex ? '#fde2e2' : '#f0f0f0',
+                            border: index === errorVerbIndex ? '1px solid #d33' : '1px solid transparent',
                             padding: '0.5rem',
                             marginBottom: '0.5rem',
                             borderRadius: '4px',
                             opacity: verb.enabled ? 1 : 0.6
                         }}
+                        title={index === errorVerbIndex ? 'This verb caused the error' : undefined}
                     >
                         <div style={{ display: 'flex', flexDirection: 'column', marginRight: '0.5rem' }}>
                             <button
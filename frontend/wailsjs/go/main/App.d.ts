@@ -2,20 +2,64 @@
 // This file is automatically generated. DO NOT EDIT
 import {main} from '../models';
 
+export function CancelJob(arg1:string):Promise<void>;
+
+export function CancelRun():Promise<void>;
+
+export function DetectCompression(arg1:string):Promise<string>;
+
+export function ExportGoProgram(arg1:main.Config,arg2:string):Promise<void>;
+
 export function GetCommand(arg1:Array<main.VerbConfig>,arg2:string,arg3:string,arg4:boolean,arg5:boolean,arg6:string,arg7:string,arg8:string,arg9:string):Promise<string>;
 
+export function GetConfigCommand(arg1:main.Config):Promise<string>;
+
+export function GetExecutionSettings():Promise<main.ExecutionSettings>;
+
+export function GetJob(arg1:string):Promise<main.Job>;
+
+export function GetPreviewCacheBudget():Promise<number>;
+
+export function GetShellCommand(arg1:main.Config,arg2:string):Promise<string>;
+
+export function ListJobs():Promise<Array<main.Job>>;
+
 export function LoadConfig(arg1:string):Promise<main.Config>;
 
 export function LoadLastState():Promise<main.Config>;
 
 export function ParseCommand(arg1:string):Promise<main.Config>;
 
+export function ParseShellSnippet(arg1:string):Promise<main.ShellImport>;
+
 export function Preview(arg1:string,arg2:Array<main.VerbConfig>,arg3:string,arg4:string,arg5:boolean,arg6:boolean,arg7:string,arg8:string):Promise<string>;
 
 export function PreviewFile(arg1:string,arg2:Array<main.VerbConfig>,arg3:string,arg4:string,arg5:boolean,arg6:boolean,arg7:string,arg8:string):Promise<string>;
 
+export function PreviewFileBounded(arg1:string,arg2:Array<main.VerbConfig>,arg3:string,arg4:string,arg5:boolean,arg6:boolean,arg7:string,arg8:string,arg9:main.PreviewLimits):Promise<main.PreviewResult>;
+
+export function PreviewFileStream(arg1:string,arg2:string,arg3:Array<main.VerbConfig>,arg4:string,arg5:string,arg6:boolean,arg7:boolean,arg8:string,arg9:string):Promise<void>;
+
+export function PreviewFiles(arg1:Array<string>,arg2:Array<main.VerbConfig>,arg3:string,arg4:string,arg5:boolean,arg6:boolean,arg7:string,arg8:string):Promise<string>;
+
+export function PreviewFilesWithProfile(arg1:Array<string>,arg2:Array<main.VerbConfig>,arg3:string,arg4:string,arg5:boolean,arg6:boolean,arg7:string,arg8:string):Promise<main.ProfiledPreview>;
+
+export function PreviewRecords(arg1:main.Config,arg2:number):Promise<main.RecordsResult>;
+
+export function PreviewSample(arg1:main.Config):Promise<main.PreviewResult>;
+
+export function PreviewSteps(arg1:main.Config,arg2:number):Promise<Array<main.StepResult>>;
+
+export function PreviewStream(arg1:string,arg2:string,arg3:Array<main.VerbConfig>,arg4:string,arg5:string,arg6:boolean,arg7:boolean,arg8:string,arg9:string):Promise<void>;
+
+export function PreviewWithProfile(arg1:string,arg2:Array<main.VerbConfig>,arg3:string,arg4:string,arg5:boolean,arg6:boolean,arg7:string,arg8:string):Promise<main.ProfiledPreview>;
+
 export function ReadFileHead(arg1:string,arg2:number):Promise<string>;
 
+export function ResolveInputFiles(arg1:Array<string>):Promise<Array<string>>;
+
+export function RunToFile(arg1:main.Config,arg2:string):Promise<main.RunProgress>;
+
 export function SaveConfig(arg1:main.Config,arg2:string):Promise<void>;
 
 export function SaveLastState(arg1:main.Config):Promise<void>;
@@ -23,3 +67,9 @@ export function SaveLastState(arg1:main.Config):Promise<void>;
 export function SaveOutput(arg1:string):Promise<void>;
 
 export function SelectInputFile():Promise<string>;
+
+export function SetExecutionSettings(arg1:main.ExecutionSettings):Promise<void>;
+
+export function SetPreviewCacheBudget(arg1:number):Promise<void>;
+
+export function StartJob(arg1:main.Config,arg2:string):Promise<string>;
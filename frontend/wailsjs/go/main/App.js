@@ -2,10 +2,50 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function CancelJob(arg1) {
+  return window['go']['main']['App']['CancelJob'](arg1);
+}
+
+export function CancelRun() {
+  return window['go']['main']['App']['CancelRun']();
+}
+
+export function DetectCompression(arg1) {
+  return window['go']['main']['App']['DetectCompression'](arg1);
+}
+
+export function ExportGoProgram(arg1, arg2) {
+  return window['go']['main']['App']['ExportGoProgram'](arg1, arg2);
+}
+
 export function GetCommand(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9) {
   return window['go']['main']['App']['GetCommand'](arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9);
 }
 
+export function GetConfigCommand(arg1) {
+  return window['go']['main']['App']['GetConfigCommand'](arg1);
+}
+
+export function GetExecutionSettings() {
+  return window['go']['main']['App']['GetExecutionSettings']();
+}
+
+export function GetJob(arg1) {
+  return window['go']['main']['App']['GetJob'](arg1);
+}
+
+export function GetPreviewCacheBudget() {
+  return window['go']['main']['App']['GetPreviewCacheBudget']();
+}
+
+export function GetShellCommand(arg1, arg2) {
+  return window['go']['main']['App']['GetShellCommand'](arg1, arg2);
+}
+
+export function ListJobs() {
+  return window['go']['main']['App']['ListJobs']();
+}
+
 export function LoadConfig(arg1) {
   return window['go']['main']['App']['LoadConfig'](arg1);
 }
@@ -18,6 +58,10 @@ export function ParseCommand(arg1) {
   return window['go']['main']['App']['ParseCommand'](arg1);
 }
 
+export function ParseShellSnippet(arg1) {
+  return window['go']['main']['App']['ParseShellSnippet'](arg1);
+}
+
 export function Preview(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8) {
   return window['go']['main']['App']['Preview'](arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8);
 }
@@ -26,10 +70,54 @@ export function PreviewFile(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8) {
   return window['go']['main']['App']['PreviewFile'](arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8);
 }
 
+export function PreviewFileBounded(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9) {
+  return window['go']['main']['App']['PreviewFileBounded'](arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9);
+}
+
+export function PreviewFileStream(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9) {
+  return window['go']['main']['App']['PreviewFileStream'](arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9);
+}
+
+export function PreviewFiles(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8) {
+  return window['go']['main']['App']['PreviewFiles'](arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8);
+}
+
+export function PreviewFilesWithProfile(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8) {
+  return window['go']['main']['App']['PreviewFilesWithProfile'](arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8);
+}
+
+export function PreviewRecords(arg1, arg2) {
+  return window['go']['main']['App']['PreviewRecords'](arg1, arg2);
+}
+
+export function PreviewSample(arg1) {
+  return window['go']['main']['App']['PreviewSample'](arg1);
+}
+
+export function PreviewSteps(arg1, arg2) {
+  return window['go']['main']['App']['PreviewSteps'](arg1, arg2);
+}
+
+export function PreviewStream(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9) {
+  return window['go']['main']['App']['PreviewStream'](arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9);
+}
+
+export function PreviewWithProfile(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8) {
+  return window['go']['main']['App']['PreviewWithProfile'](arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8);
+}
+
 export function ReadFileHead(arg1, arg2) {
   return window['go']['main']['App']['ReadFileHead'](arg1, arg2);
 }
 
+export function ResolveInputFiles(arg1) {
+  return window['go']['main']['App']['ResolveInputFiles'](arg1);
+}
+
+export function RunToFile(arg1, arg2) {
+  return window['go']['main']['App']['RunToFile'](arg1, arg2);
+}
+
 export function SaveConfig(arg1, arg2) {
   return window['go']['main']['App']['SaveConfig'](arg1, arg2);
 }
@@ -45,3 +133,15 @@ export function SaveOutput(arg1) {
 export function SelectInputFile() {
   return window['go']['main']['App']['SelectInputFile']();
 }
+
+export function SetExecutionSettings(arg1) {
+  return window['go']['main']['App']['SetExecutionSettings'](arg1);
+}
+
+export function SetPreviewCacheBudget(arg1) {
+  return window['go']['main']['App']['SetPreviewCacheBudget'](arg1);
+}
+
+export function StartJob(arg1, arg2) {
+  return window['go']['main']['App']['StartJob'](arg1, arg2);
+}
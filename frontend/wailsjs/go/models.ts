@@ -14,9 +14,31 @@ export namespace main {
 	        this.enabled = source["enabled"];
 	    }
 	}
+	export class SamplingConfig {
+	    strategy: string;
+	    records: number;
+	    every?: number;
+	    seed?: number;
+	    jumps?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SamplingConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.strategy = source["strategy"];
+	        this.records = source["records"];
+	        this.every = source["every"];
+	        this.seed = source["seed"];
+	        this.jumps = source["jumps"];
+	    }
+	}
 	export class Config {
 	    inputPath: string;
+	    inputPaths?: string[];
 	    inputMode: string;
+	    compression?: string;
 	    inputFormat: string;
 	    ragged: boolean;
 	    headerless: boolean;
@@ -24,6 +46,7 @@ export namespace main {
 	    outputFormat: string;
 	    verbs: VerbConfig[];
 	    options: string;
+	    sampling?: SamplingConfig;
 	
 	    static createFrom(source: any = {}) {
 	        return new Config(source);
@@ -32,7 +55,9 @@ export namespace main {
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.inputPath = source["inputPath"];
+	        this.inputPaths = source["inputPaths"];
 	        this.inputMode = source["inputMode"];
+	        this.compression = source["compression"];
 	        this.inputFormat = source["inputFormat"];
 	        this.ragged = source["ragged"];
 	        this.headerless = source["headerless"];
@@ -40,6 +65,305 @@ export namespace main {
 	        this.outputFormat = source["outputFormat"];
 	        this.verbs = this.convertValues(source["verbs"], VerbConfig);
 	        this.options = source["options"];
+	        this.sampling = this.convertValues(source["sampling"], SamplingConfig);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PreviewLimits {
+	    maxInputRecords: number;
+	    maxOutputRecords: number;
+	    maxOutputBytes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new PreviewLimits(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.maxInputRecords = source["maxInputRecords"];
+	        this.maxOutputRecords = source["maxOutputRecords"];
+	        this.maxOutputBytes = source["maxOutputBytes"];
+	    }
+	}
+	export class PreviewResult {
+	    output: string;
+	    truncated: boolean;
+	    recordsRead: number;
+	    recordsWritten: number;
+	    bytesWritten: number;
+	    profile: VerbProfile[];
+	
+	    static createFrom(source: any = {}) {
+	        return new PreviewResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.output = source["output"];
+	        this.truncated = source["truncated"];
+	        this.recordsRead = source["recordsRead"];
+	        this.recordsWritten = source["recordsWritten"];
+	        this.bytesWritten = source["bytesWritten"];
+	        this.profile = this.convertValues(source["profile"], VerbProfile);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ProfiledPreview {
+	    output: string;
+	    profile: VerbProfile[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ProfiledPreview(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.output = source["output"];
+	        this.profile = this.convertValues(source["profile"], VerbProfile);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class StepResult {
+	    verbIndex: number;
+	    verb: string;
+	    recordsIn: number;
+	    recordsOut: number;
+	    output: string;
+	    truncated: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new StepResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.verbIndex = source["verbIndex"];
+	        this.verb = source["verb"];
+	        this.recordsIn = source["recordsIn"];
+	        this.recordsOut = source["recordsOut"];
+	        this.output = source["output"];
+	        this.truncated = source["truncated"];
+	    }
+	}
+	export class RecordValue {
+	    type: string;
+	    value: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new RecordValue(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.value = source["value"];
+	    }
+	}
+	export class RecordsResult {
+	    fields: string[];
+	    rows: RecordValue[][];
+	    truncated: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new RecordsResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.fields = source["fields"];
+	        this.rows = this.convertValues(source["rows"], RecordValue);
+	        this.truncated = source["truncated"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class VerbProfile {
+	    verbIndex: number;
+	    verb: string;
+	    wallTimeMs: number;
+	    recordsIn: number;
+	    recordsOut: number;
+	    peakBatchSize: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new VerbProfile(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.verbIndex = source["verbIndex"];
+	        this.verb = source["verb"];
+	        this.wallTimeMs = source["wallTimeMs"];
+	        this.recordsIn = source["recordsIn"];
+	        this.recordsOut = source["recordsOut"];
+	        this.peakBatchSize = source["peakBatchSize"];
+	    }
+	}
+	export class RunProgress {
+	    outputPath: string;
+	    inputFiles: string[];
+	    bytesRead: number;
+	    totalBytes: number;
+	    recordsRead: number;
+	    recordsWritten: number;
+	    bytesWritten: number;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RunProgress(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.outputPath = source["outputPath"];
+	        this.inputFiles = source["inputFiles"];
+	        this.bytesRead = source["bytesRead"];
+	        this.totalBytes = source["totalBytes"];
+	        this.recordsRead = source["recordsRead"];
+	        this.recordsWritten = source["recordsWritten"];
+	        this.bytesWritten = source["bytesWritten"];
+	        this.error = source["error"];
+	    }
+	}
+	export class ExecutionSettings {
+	    maxConcurrent: number;
+	    policy: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ExecutionSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.maxConcurrent = source["maxConcurrent"];
+	        this.policy = source["policy"];
+	    }
+	}
+	export class Job {
+	    id: string;
+	    status: string;
+	    progress: RunProgress;
+	    outputPath: string;
+	    startedAt: any;
+	    endedAt?: any;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Job(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.status = source["status"];
+	        this.progress = this.convertValues(source["progress"], RunProgress);
+	        this.outputPath = source["outputPath"];
+	        this.startedAt = source["startedAt"];
+	        this.endedAt = source["endedAt"];
+	        this.error = source["error"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ShellImport {
+	    config: Config;
+	    outputPath?: string;
+	    append?: boolean;
+	    warnings?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ShellImport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.config = this.convertValues(source["config"], Config);
+	        this.outputPath = source["outputPath"];
+	        this.append = source["append"];
+	        this.warnings = source["warnings"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -62,4 +386,3 @@ export namespace main {
 	}
 
 }
-
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// globMeta are the characters that make an input path a glob pattern
+const globMeta = "*?["
+
+// isGlobPattern reports whether path is a glob pattern rather than a plain
+// path. A path that exists is plain whatever it holds, so that a file such as
+// report[2026].csv is read as itself.
+func isGlobPattern(path string) bool {
+	if !strings.ContainsAny(path, globMeta) {
+		return false
+	}
+	_, err := os.Lstat(path)
+	return err != nil
+}
+
+// expandInputPaths resolves input paths into the files Miller should read, in
+// order. Glob patterns expand to the files they match and directories to the
+// files directly inside them, each sorted by name so runs are repeatable.
+// Hidden files are skipped, unless a pattern asks for them with a leading dot
+// the way it would in a shell. Plain paths are kept as they are, so a missing
+// file is reported when it is opened.
+func expandInputPaths(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		switch {
+		case isGlobPattern(path):
+			matches, err := filepath.Glob(path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid glob pattern %q: %v", path, err)
+			}
+			var matchedFiles []string
+			for _, match := range matches {
+				if hiddenMatch(path, match) {
+					continue
+				}
+				if info, err := os.Stat(match); err == nil && !info.IsDir() {
+					matchedFiles = append(matchedFiles, match)
+				}
+			}
+			if len(matchedFiles) == 0 {
+				return nil, fmt.Errorf("no files match %q", path)
+			}
+			sort.Strings(matchedFiles)
+			files = append(files, matchedFiles...)
+
+		case isDirectory(path):
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, fmt.Errorf("error reading directory %q: %v", path, err)
+			}
+			var dirFiles []string
+			for _, entry := range entries {
+				if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+					continue
+				}
+				dirFiles = append(dirFiles, filepath.Join(path, entry.Name()))
+			}
+			if len(dirFiles) == 0 {
+				return nil, fmt.Errorf("no files in directory %q", path)
+			}
+			// os.ReadDir already sorts by name
+			files = append(files, dirFiles...)
+
+		default:
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// hiddenMatch reports whether match, which pattern matched, passes through a
+// name starting with a dot that the pattern doesn't spell with one. Shells
+// leave such matches out, and filepath.Glob doesn't.
+func hiddenMatch(pattern, match string) bool {
+	// Glob cleans the directory part, so the parts are lined up from the end
+	patternParts := strings.Split(filepath.ToSlash(pattern), "/")
+	matchParts := strings.Split(filepath.ToSlash(match), "/")
+	for i, j := len(patternParts)-1, len(matchParts)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if strings.HasPrefix(matchParts[j], ".") && !strings.HasPrefix(patternParts[i], ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func isDirectory(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// ResolveInputFiles expands glob patterns and directories in paths into the
+// list of files a preview or run will read, so the UI can show it
+func (a *App) ResolveInputFiles(paths []string) (_ []string, err error) {
+	defer RecoverToError("ResolveInputFiles", &err)
+
+	files, err := expandInputPaths(paths)
+	if err != nil {
+		LogError(err, "Failed to resolve input files", logrus.Fields{"paths": paths})
+		return nil, err
+	}
+
+	LogInfo("Input files resolved", logrus.Fields{"paths": paths, "files_count": len(files)})
+	return files, nil
+}
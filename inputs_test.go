@@ -0,0 +1,224 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeInputTree creates files under a temp dir and returns the dir
+func writeInputTree(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("id\n"+name+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestExpandInputPaths(t *testing.T) {
+	dir := writeInputTree(t,
+		"data/2026-03.csv", "data/2026-01.csv", "data/2025-12.csv", "data/.hidden.csv",
+		"data/notes.txt", "data/sub/2026-02.csv",
+		"reports/report[2026].csv", "reports/report2.csv",
+	)
+	data := filepath.Join(dir, "data")
+	reports := filepath.Join(dir, "reports")
+
+	tests := []struct {
+		name    string
+		paths   []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "Glob expands sorted",
+			paths: []string{filepath.Join(data, "2026-*.csv")},
+			want:  []string{filepath.Join(data, "2026-01.csv"), filepath.Join(data, "2026-03.csv")},
+		},
+		{
+			name:  "Glob skips hidden files",
+			paths: []string{filepath.Join(data, "*.csv")},
+			want: []string{
+				filepath.Join(data, "2025-12.csv"), filepath.Join(data, "2026-01.csv"), filepath.Join(data, "2026-03.csv"),
+			},
+		},
+		{
+			name:  "Glob with a leading dot matches hidden files",
+			paths: []string{filepath.Join(data, ".*.csv")},
+			want:  []string{filepath.Join(data, ".hidden.csv")},
+		},
+		{
+			name:  "Directory lists its files, skipping hidden files and subdirectories",
+			paths: []string{data},
+			want: []string{
+				filepath.Join(data, "2025-12.csv"), filepath.Join(data, "2026-01.csv"),
+				filepath.Join(data, "2026-03.csv"), filepath.Join(data, "notes.txt"),
+			},
+		},
+		{
+			name:  "Plain paths keep their order",
+			paths: []string{filepath.Join(data, "notes.txt"), filepath.Join(data, "2025-12.csv")},
+			want:  []string{filepath.Join(data, "notes.txt"), filepath.Join(data, "2025-12.csv")},
+		},
+		{
+			name:  "Mixed glob and plain path",
+			paths: []string{filepath.Join(data, "sub", "2026-02.csv"), filepath.Join(data, "*.txt")},
+			want:  []string{filepath.Join(data, "sub", "2026-02.csv"), filepath.Join(data, "notes.txt")},
+		},
+		{
+			name:  "Existing file named like a glob",
+			paths: []string{filepath.Join(reports, "report[2026].csv")},
+			want:  []string{filepath.Join(reports, "report[2026].csv")},
+		},
+		{
+			name:  "Missing file named like a glob is a glob",
+			paths: []string{filepath.Join(reports, "report[0-9].csv")},
+			want:  []string{filepath.Join(reports, "report2.csv")},
+		},
+		{
+			name:    "Glob without matches",
+			paths:   []string{filepath.Join(data, "*.json")},
+			wantErr: true,
+		},
+		{
+			name:    "Empty directory",
+			paths:   []string{t.TempDir()},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandInputPaths(tt.paths)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expandInputPaths failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandInputPaths = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviewFileGlob(t *testing.T) {
+	app := NewApp()
+	dir := writeInputTree(t, "b.csv", "a.csv")
+	verbs := []VerbConfig{{Value: "cat", Enabled: true}}
+
+	result, err := app.PreviewFile(filepath.Join(dir, "*.csv"), verbs, "", "--icsv", false, false, ",", "--ocsv")
+	if err != nil {
+		t.Fatalf("PreviewFile failed: %v", err)
+	}
+	if want := "id\na.csv\nb.csv\n"; result != want {
+		t.Errorf("PreviewFile output = %q, want %q", result, want)
+	}
+}
+
+func TestRunToFileDirectory(t *testing.T) {
+	app := NewApp()
+	dir := writeInputTree(t, "2.csv", "1.csv")
+	outputPath := filepath.Join(t.TempDir(), "out.csv")
+	config := Config{
+		InputMode:    "file",
+		InputPath:    dir,
+		InputFormat:  "--icsv",
+		OutputFormat: "--ocsv",
+		Verbs:        []VerbConfig{{Value: "cat", Enabled: true}},
+	}
+
+	progress, err := app.RunToFile(config, outputPath)
+	if err != nil {
+		t.Fatalf("RunToFile failed: %v", err)
+	}
+	wantFiles := []string{filepath.Join(dir, "1.csv"), filepath.Join(dir, "2.csv")}
+	if !reflect.DeepEqual(progress.InputFiles, wantFiles) {
+		t.Errorf("InputFiles = %v, want %v", progress.InputFiles, wantFiles)
+	}
+	if progress.RecordsRead != 2 {
+		t.Errorf("RecordsRead = %d, want 2", progress.RecordsRead)
+	}
+}
+
+func TestGetCommandKeepsGlob(t *testing.T) {
+	app := NewApp()
+	verbs := []VerbConfig{{Value: "cat", Enabled: true}}
+
+	tests := []struct {
+		name      string
+		inputPath string
+		wantTail  string
+	}{
+		{name: "Plain glob", inputPath: "data/2026-*.csv", wantTail: " data/2026-*.csv"},
+		{name: "Glob with spaces", inputPath: "my data/2026-*.csv", wantTail: ` 'my data/2026-'*.csv`},
+		{name: "Path with spaces", inputPath: "my data/jan.csv", wantTail: ` 'my data/jan.csv'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := app.GetCommand(verbs, "", "--icsv", false, false, ",", "--ojson", "file", tt.inputPath)
+			if err != nil {
+				t.Fatalf("GetCommand failed: %v", err)
+			}
+			if !strings.HasSuffix(cmd, tt.wantTail) {
+				t.Errorf("GetCommand = %q, want it to end with %q", cmd, tt.wantTail)
+			}
+		})
+	}
+}
+
+func TestGetCommandListsDirectory(t *testing.T) {
+	app := NewApp()
+	verbs := []VerbConfig{{Value: "cat", Enabled: true}}
+	dir := filepath.Join(writeInputTree(t, "my data/b.csv", "my data/a.csv", "my data/.hidden.csv", "my data/sub/c.csv"), "my data")
+
+	cmd, err := app.GetCommand(verbs, "", "--icsv", false, false, ",", "--ojson", "file", dir)
+	if err != nil {
+		t.Fatalf("GetCommand failed: %v", err)
+	}
+	// mlr can't read a directory, so the command names the files the preview
+	// reads, in the same order
+	files, err := expandInputPaths([]string{dir})
+	if err != nil {
+		t.Fatalf("expandInputPaths failed: %v", err)
+	}
+	var want string
+	for _, file := range files {
+		want += " " + quoteIfNeeded(file)
+	}
+	if !strings.HasSuffix(cmd, want) {
+		t.Errorf("GetCommand = %q, want it to end with %q", cmd, want)
+	}
+	if strings.HasSuffix(cmd, quoteIfNeeded(dir)) {
+		t.Errorf("GetCommand = %q, names the directory itself", cmd)
+	}
+}
+
+func TestGetCommandQuotesBracketedFile(t *testing.T) {
+	app := NewApp()
+	verbs := []VerbConfig{{Value: "cat", Enabled: true}}
+	path := filepath.Join(writeInputTree(t, "report[2026].csv"), "report[2026].csv")
+
+	cmd, err := app.GetCommand(verbs, "", "--icsv", false, false, ",", "--ojson", "file", path)
+	if err != nil {
+		t.Fatalf("GetCommand failed: %v", err)
+	}
+	// The shell must not take the brackets for a pattern
+	if want := " '" + path + "'"; !strings.HasSuffix(cmd, want) {
+		t.Errorf("GetCommand = %q, want it to end with %q", cmd, want)
+	}
+}
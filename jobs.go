@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// EventJobFinished is emitted with the Job when a job is done, has failed or
+// was cancelled
+const EventJobFinished = "job:finished"
+
+// Job statuses
+const (
+	JobQueued    = "queued" // waiting for the executor to admit it
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job is a run of a pipeline over its whole input into an output file, in the
+// background. It runs with its own copy of the config, so editing the
+// pipeline in the UI doesn't touch it, and no preview supersedes it.
+type Job struct {
+	ID         string      `json:"id"`
+	Status     string      `json:"status"`
+	Progress   RunProgress `json:"progress"`
+	OutputPath string      `json:"outputPath"`
+	StartedAt  time.Time   `json:"startedAt"`         // when StartJob was called
+	EndedAt    *time.Time  `json:"endedAt,omitempty"` // once finished
+	Error      string      `json:"error,omitempty"`
+}
+
+// finished reports whether the job has come to an end
+func (j Job) finished() bool {
+	return j.Status == JobDone || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+// jobState is a Job with what it takes to control it
+type jobState struct {
+	job    Job
+	cancel context.CancelFunc
+	seq    int // order of StartJob calls
+}
+
+// maxFinishedJobs is how many finished jobs a jobTable keeps; the oldest
+// beyond that are forgotten
+const maxFinishedJobs = 100
+
+// jobTable holds the jobs started in this session: all that haven't finished,
+// and the latest maxFinishedJobs that have
+type jobTable struct {
+	mu   sync.Mutex
+	jobs map[string]*jobState
+	next int
+}
+
+// update changes the job with the given ID under the table's lock, and
+// returns a copy of it. A change that finishes the job prunes the table in
+// the same breath, so the table is never seen holding too many.
+func (t *jobTable) update(id string, change func(job *Job)) Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state := t.jobs[id]
+	change(&state.job)
+	if state.job.finished() {
+		t.prune()
+	}
+	return state.job
+}
+
+// prune forgets the oldest finished jobs beyond maxFinishedJobs. The table's
+// lock must be held.
+func (t *jobTable) prune() {
+	var finished []*jobState
+	for _, state := range t.jobs {
+		if state.job.finished() {
+			finished = append(finished, state)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].seq < finished[j].seq })
+	for _, state := range finished[:len(finished)-maxFinishedJobs] {
+		delete(t.jobs, state.job.ID)
+	}
+}
+
+// StartJob starts running config over its whole input into outputPath in the
+// background, and returns the new job's ID. Jobs go through the executor like
+// every other Miller execution, waiting their turn behind those already
+// running; newer previews don't supersede them. A job:finished event is
+// emitted when the job ends.
+func (a *App) StartJob(config Config, outputPath string) (_ string, err error) {
+	defer RecoverToError("StartJob", &err)
+
+	if outputPath == "" {
+		return "", errors.New("no output file")
+	}
+
+	ctx, cancel := context.WithCancel(a.parentContext())
+
+	a.jobs.mu.Lock()
+	if a.jobs.jobs == nil {
+		a.jobs.jobs = map[string]*jobState{}
+	}
+	a.jobs.next++
+	id := fmt.Sprintf("job-%d", a.jobs.next)
+	a.jobs.jobs[id] = &jobState{
+		job: Job{
+			ID:         id,
+			Status:     JobQueued,
+			Progress:   RunProgress{OutputPath: outputPath},
+			OutputPath: outputPath,
+			StartedAt:  time.Now(),
+		},
+		cancel: cancel,
+		seq:    a.jobs.next,
+	}
+	a.jobs.mu.Unlock()
+
+	LogInfo("Job started", logrus.Fields{"job_id": id, "output_path": outputPath, "verbs_count": len(config.Verbs)})
+	go a.runJob(ctx, cancel, id, config, outputPath)
+	return id, nil
+}
+
+// runJob runs the job with the given ID to its end
+func (a *App) runJob(ctx context.Context, cancel context.CancelFunc, id string, config Config, outputPath string) {
+	defer RecoverFromPanic("runJob")
+	defer cancel()
+
+	progress, err := a.jobToFile(ctx, id, config, outputPath)
+
+	job := a.jobs.update(id, func(job *Job) {
+		ended := time.Now()
+		job.EndedAt = &ended
+		job.Progress = progress
+		switch {
+		case errors.Is(err, ErrRunCancelled):
+			job.Status = JobCancelled
+			job.Error = err.Error()
+		case err != nil:
+			job.Status = JobFailed
+			job.Error = err.Error()
+		default:
+			job.Status = JobDone
+		}
+	})
+
+	LogInfo("Job finished", logrus.Fields{"job_id": id, "status": job.Status, "records_written": job.Progress.RecordsWritten})
+	a.emit(EventJobFinished, job)
+}
+
+// jobToFile waits for the executor to admit the job, then runs it
+func (a *App) jobToFile(ctx context.Context, id string, config Config, outputPath string) (_ RunProgress, err error) {
+	defer RecoverToError("jobToFile", &err)
+
+	runCtx, release, err := a.executor.acquire(ctx, false)
+	if err != nil {
+		return RunProgress{OutputPath: outputPath}, ErrRunCancelled
+	}
+	defer release()
+
+	a.jobs.update(id, func(job *Job) { job.Status = JobRunning })
+	return a.runToFile(runCtx, config, outputPath, func(progress RunProgress) {
+		a.jobs.update(id, func(job *Job) { job.Progress = progress })
+	})
+}
+
+// GetJob returns the job with the given ID
+func (a *App) GetJob(id string) (_ Job, err error) {
+	defer RecoverToError("GetJob", &err)
+
+	a.jobs.mu.Lock()
+	defer a.jobs.mu.Unlock()
+
+	state, ok := a.jobs.jobs[id]
+	if !ok {
+		return Job{}, fmt.Errorf("no job %q", id)
+	}
+	return state.job, nil
+}
+
+// ListJobs returns the jobs of this session, in the order they were started.
+// Only the latest finished ones are kept.
+func (a *App) ListJobs() (_ []Job, err error) {
+	defer RecoverToError("ListJobs", &err)
+
+	a.jobs.mu.Lock()
+	defer a.jobs.mu.Unlock()
+
+	states := make([]*jobState, 0, len(a.jobs.jobs))
+	for _, state := range a.jobs.jobs {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].seq < states[j].seq })
+
+	jobs := make([]Job, len(states))
+	for i, state := range states {
+		jobs[i] = state.job
+	}
+	return jobs, nil
+}
+
+// CancelJob cancels the job with the given ID, whether it is running or still
+// queued. Cancelling a job that has finished does nothing.
+func (a *App) CancelJob(id string) (err error) {
+	defer RecoverToError("CancelJob", &err)
+
+	a.jobs.mu.Lock()
+	defer a.jobs.mu.Unlock()
+
+	state, ok := a.jobs.jobs[id]
+	if !ok {
+		return fmt.Errorf("no job %q", id)
+	}
+	if !state.job.finished() {
+		LogInfo("Cancelling job", logrus.Fields{"job_id": id})
+		state.cancel()
+	}
+	return nil
+}
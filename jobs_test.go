@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForJob waits for the job to finish and returns it
+func waitForJob(t *testing.T, app *App, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := app.GetJob(id)
+		if err != nil {
+			t.Fatalf("GetJob failed: %v", err)
+		}
+		if job.finished() {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Job %s still %s", id, job.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// jobConfig is a config that reads inputPath as CSV
+func jobConfig(inputPath string, verb string) Config {
+	return Config{
+		InputMode:    "file",
+		InputPath:    inputPath,
+		InputFormat:  "--icsv",
+		OutputFormat: "--ocsv",
+		Verbs:        []VerbConfig{{Value: verb, Enabled: true}},
+	}
+}
+
+func TestStartJob(t *testing.T) {
+	app := NewApp()
+	outputPath := filepath.Join(t.TempDir(), "out.csv")
+
+	id, err := app.StartJob(jobConfig(writeTestCSV(t, 20), "head -n 5"), outputPath)
+	if err != nil {
+		t.Fatalf("StartJob failed: %v", err)
+	}
+
+	job := waitForJob(t, app, id)
+	if job.Status != JobDone {
+		t.Fatalf("Status = %q, want %q (error %q)", job.Status, JobDone, job.Error)
+	}
+	if job.OutputPath != outputPath {
+		t.Errorf("OutputPath = %q, want %q", job.OutputPath, outputPath)
+	}
+	if job.Progress.RecordsWritten != 5 {
+		t.Errorf("RecordsWritten = %d, want 5", job.Progress.RecordsWritten)
+	}
+	if job.EndedAt == nil || job.EndedAt.Before(job.StartedAt) {
+		t.Errorf("EndedAt = %v, want a time after StartedAt %v", job.EndedAt, job.StartedAt)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 6 {
+		t.Errorf("Output has %d lines, want a header and 5 records: %q", lines, data)
+	}
+}
+
+func TestStartJobFailure(t *testing.T) {
+	app := NewApp()
+	dir := t.TempDir()
+
+	tests := []struct {
+		name       string
+		config     Config
+		outputPath string
+		wantStart  bool
+	}{
+		{
+			name:       "Missing input",
+			config:     jobConfig(filepath.Join(dir, "missing.csv"), "cat"),
+			outputPath: filepath.Join(dir, "out.csv"),
+			wantStart:  true,
+		},
+		{
+			name:       "Unknown verb",
+			config:     jobConfig(writeTestCSV(t, 2), "nosuchverb"),
+			outputPath: filepath.Join(dir, "out.csv"),
+			wantStart:  true,
+		},
+		{
+			name:   "No output file",
+			config: jobConfig(writeTestCSV(t, 2), "cat"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := app.StartJob(tt.config, tt.outputPath)
+			if !tt.wantStart {
+				if err == nil {
+					t.Errorf("Expected StartJob to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StartJob failed: %v", err)
+			}
+
+			job := waitForJob(t, app, id)
+			if job.Status != JobFailed || job.Error == "" {
+				t.Errorf("Status = %q, Error = %q, want %q with an error", job.Status, job.Error, JobFailed)
+			}
+		})
+	}
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	app := NewApp()
+	outputPath := filepath.Join(t.TempDir(), "out.csv")
+
+	// Hold the executor so the job stays queued
+	_, release, err := app.executor.acquire(context.Background(), false)
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	defer release()
+
+	id, err := app.StartJob(jobConfig(writeTestCSV(t, 5), "cat"), outputPath)
+	if err != nil {
+		t.Fatalf("StartJob failed: %v", err)
+	}
+	if job, _ := app.GetJob(id); job.Status != JobQueued {
+		t.Errorf("Status = %q while the executor is busy, want %q", job.Status, JobQueued)
+	}
+
+	if err := app.CancelJob(id); err != nil {
+		t.Fatalf("CancelJob failed: %v", err)
+	}
+	job := waitForJob(t, app, id)
+	if job.Status != JobCancelled {
+		t.Errorf("Status = %q, want %q", job.Status, JobCancelled)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Errorf("Expected no output file from a cancelled job")
+	}
+
+	// Cancelling again is harmless
+	if err := app.CancelJob(id); err != nil {
+		t.Errorf("CancelJob of a finished job failed: %v", err)
+	}
+}
+
+func TestJobNotSupersededByPreview(t *testing.T) {
+	app := NewApp()
+	outputPath := filepath.Join(t.TempDir(), "out.csv")
+
+	_, release, err := app.executor.acquire(context.Background(), false)
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	id, err := app.StartJob(jobConfig(writeTestCSV(t, 5), "cat"), outputPath)
+	if err != nil {
+		t.Fatalf("StartJob failed: %v", err)
+	}
+	waitForWaiting(t, app.executor, 1)
+
+	// The user goes on editing a pipeline while the job waits
+	previewDone := make(chan error)
+	go func() {
+		_, err := app.Preview("a,b\n1,2\n", []VerbConfig{{Value: "cat", Enabled: true}}, "", "--icsv", false, false, ",", "--ocsv")
+		previewDone <- err
+	}()
+	waitForWaiting(t, app.executor, 2)
+	release()
+
+	if err := <-previewDone; err != nil {
+		t.Errorf("Preview failed: %v", err)
+	}
+
+	if job := waitForJob(t, app, id); job.Status != JobDone {
+		t.Errorf("Status = %q, want %q (error %q)", job.Status, JobDone, job.Error)
+	}
+}
+
+func TestFinishedJobsPruned(t *testing.T) {
+	app := NewApp()
+	dir := t.TempDir()
+	inputPath := writeTestCSV(t, 1)
+
+	var ids []string
+	for i := 0; i < maxFinishedJobs+3; i++ {
+		id, err := app.StartJob(jobConfig(inputPath, "cat"), filepath.Join(dir, fmt.Sprintf("out%d.csv", i)))
+		if err != nil {
+			t.Fatalf("StartJob failed: %v", err)
+		}
+		waitForJob(t, app, id)
+		ids = append(ids, id)
+	}
+
+	jobs, err := app.ListJobs()
+	if err != nil {
+		t.Fatalf("ListJobs failed: %v", err)
+	}
+	if len(jobs) != maxFinishedJobs {
+		t.Fatalf("ListJobs returned %d jobs, want %d", len(jobs), maxFinishedJobs)
+	}
+	// The oldest are gone
+	if jobs[0].ID != ids[3] {
+		t.Errorf("Oldest job kept = %s, want %s", jobs[0].ID, ids[3])
+	}
+	if _, err := app.GetJob(ids[0]); err == nil {
+		t.Errorf("Expected the oldest job to be forgotten")
+	}
+}
+
+func TestListJobs(t *testing.T) {
+	app := NewApp()
+	dir := t.TempDir()
+	inputPath := writeTestCSV(t, 3)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, err := app.StartJob(jobConfig(inputPath, "cat"), filepath.Join(dir, "out"+string(rune('a'+i))+".csv"))
+		if err != nil {
+			t.Fatalf("StartJob failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		waitForJob(t, app, id)
+	}
+
+	jobs, err := app.ListJobs()
+	if err != nil {
+		t.Fatalf("ListJobs failed: %v", err)
+	}
+	if len(jobs) != len(ids) {
+		t.Fatalf("ListJobs returned %d jobs, want %d", len(jobs), len(ids))
+	}
+	for i, job := range jobs {
+		if job.ID != ids[i] {
+			t.Errorf("Job %d = %s, want %s", i, job.ID, ids[i])
+		}
+	}
+
+	if _, err := app.GetJob("job-0"); err == nil {
+		t.Errorf("Expected an error from GetJob for an unknown job")
+	}
+	if err := app.CancelJob("job-0"); err == nil {
+		t.Errorf("Expected an error from CancelJob for an unknown job")
+	}
+}
@@ -0,0 +1,99 @@
+//go:build !windows
+
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestPreviewDuringJob runs a preview while a job is running, which under the
+// executor's limit waits for the job and beyond it runs beside it
+func TestPreviewDuringJob(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxConcurrent int
+		wantWait      bool
+	}{
+		{name: "One at a time", maxConcurrent: 1, wantWait: true},
+		{name: "Two at a time", maxConcurrent: 2, wantWait: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := NewApp()
+			if err := app.SetExecutionSettings(ExecutionSettings{MaxConcurrent: tt.maxConcurrent, Policy: PolicyLatestWins}); err != nil {
+				t.Fatalf("SetExecutionSettings failed: %v", err)
+			}
+			outputPath := filepath.Join(t.TempDir(), "out.csv")
+
+			// A job that stays running until the test lets its input end.
+			// Its compression is given so that the pipe is opened only to be
+			// read.
+			inputPath := filepath.Join(t.TempDir(), "in.csv.gz")
+			if err := syscall.Mkfifo(inputPath, 0600); err != nil {
+				t.Skipf("Can't make a named pipe: %v", err)
+			}
+			config := jobConfig(inputPath, "cat")
+			config.Compression = CompressionGzip
+			id, err := app.StartJob(config, outputPath)
+			if err != nil {
+				t.Fatalf("StartJob failed: %v", err)
+			}
+			input, err := os.OpenFile(inputPath, os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatalf("Failed to open the job's input: %v", err)
+			}
+			compressed := gzip.NewWriter(input)
+			compressed.Write([]byte("a,b\n1,2\n"))
+			compressed.Flush()
+			if job, _ := app.GetJob(id); job.Status != JobRunning {
+				t.Errorf("Status = %q, want %q", job.Status, JobRunning)
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := app.Preview("a,b\n1,2\n", []VerbConfig{{Value: "cat", Enabled: true}}, "", "--icsv", false, false, ",", "--ocsv")
+				done <- err
+			}()
+			if tt.wantWait {
+				waitForWaiting(t, app.executor, 1)
+				select {
+				case err := <-done:
+					t.Fatalf("Preview ran beside the job (error %v)", err)
+				case <-time.After(100 * time.Millisecond):
+				}
+			} else {
+				select {
+				case err := <-done:
+					if err != nil {
+						t.Errorf("Preview failed: %v", err)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("Preview waited behind the running job")
+				}
+			}
+
+			// The preview doesn't supersede the job
+			compressed.Close()
+			input.Close()
+			if job := waitForJob(t, app, id); job.Status != JobDone {
+				t.Errorf("Status = %q, want %q (error %q)", job.Status, JobDone, job.Error)
+			}
+			if tt.wantWait {
+				select {
+				case err := <-done:
+					if err != nil {
+						t.Errorf("Preview failed: %v", err)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("Preview still waiting after the job finished")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"container/list"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/johnkerl/miller/v6/pkg/transformers"
+	"github.com/johnkerl/miller/v6/pkg/types"
+)
+
+// PreviewLimits bounds how much work a preview does. Zero means unlimited.
+type PreviewLimits struct {
+	MaxInputRecords  int64 `json:"maxInputRecords"`
+	MaxOutputRecords int64 `json:"maxOutputRecords"`
+	MaxOutputBytes   int64 `json:"maxOutputBytes"`
+}
+
+// PreviewResult holds the output of a bounded preview, which may be partial
+type PreviewResult struct {
+	Output         string        `json:"output"`
+	Truncated      bool          `json:"truncated"`
+	RecordsRead    int64         `json:"recordsRead"`
+	RecordsWritten int64         `json:"recordsWritten"`
+	BytesWritten   int64         `json:"bytesWritten"`
+	Profile        []VerbProfile `json:"profile"`
+}
+
+// readerStop is closed by whichever limit is hit first, telling
+// runMillerTransformation to stop reading while keeping the output so far
+type readerStop struct {
+	once      sync.Once
+	ch        chan struct{}
+	truncated atomic.Bool
+}
+
+func newReaderStop() *readerStop {
+	return &readerStop{ch: make(chan struct{})}
+}
+
+// Stop marks the run as truncated and asks the reader to stop
+func (s *readerStop) Stop() {
+	s.truncated.Store(true)
+	s.once.Do(func() { close(s.ch) })
+}
+
+// recordLimiter is a pass-through transformer that counts the records going
+// through it. Once max records have passed (0 means unlimited) it drops the
+// rest and stops the reader.
+type recordLimiter struct {
+	max    int64
+	passed atomic.Int64
+	stop   *readerStop
+}
+
+func (l *recordLimiter) Transform(
+	inrecAndContext *types.RecordAndContext,
+	outputRecordsAndContexts *list.List,
+	inputDownstreamDoneChannel <-chan bool,
+	outputDownstreamDoneChannel chan<- bool,
+) {
+	transformers.HandleDefaultDownstreamDone(inputDownstreamDoneChannel, outputDownstreamDoneChannel)
+
+	if !inrecAndContext.EndOfStream {
+		if l.max > 0 && l.passed.Load() >= l.max {
+			l.stop.Stop()
+			return
+		}
+		l.passed.Add(1)
+	}
+	outputRecordsAndContexts.PushBack(inrecAndContext)
+}
+
+// byteLimitWriter passes through at most max bytes (0 means unlimited), then
+// discards the rest and stops the reader
+type byteLimitWriter struct {
+	w       io.Writer
+	max     int64
+	written atomic.Int64
+	stop    *readerStop
+}
+
+func (b *byteLimitWriter) Write(p []byte) (int, error) {
+	n := int64(len(p))
+	if b.max > 0 {
+		room := b.max - b.written.Load()
+		if room <= 0 {
+			b.stop.Stop()
+			return len(p), nil
+		}
+		if n > room {
+			n = room
+			b.stop.Stop()
+		}
+	}
+	written, err := b.w.Write(p[:n])
+	b.written.Add(int64(written))
+	if err != nil {
+		return written, err
+	}
+	return len(p), nil
+}
+
+// bufferSize is the size of the buffer runPipeline writes through to b: no
+// more than the limit, so that b sees the limit reached while the reader is
+// still going rather than only at the final flush
+func (b *byteLimitWriter) bufferSize() int {
+	if b.max > 0 && b.max < defaultOutputBufferSize {
+		return int(b.max)
+	}
+	return defaultOutputBufferSize
+}
+
+// withRecordLimits wraps the transformer chain between an input and an output
+// recordLimiter
+func withRecordLimits(recordTransformers []transformers.IRecordTransformer, limits PreviewLimits, stop *readerStop) ([]transformers.IRecordTransformer, *recordLimiter, *recordLimiter) {
+	inputLimiter := &recordLimiter{max: limits.MaxInputRecords, stop: stop}
+	outputLimiter := &recordLimiter{max: limits.MaxOutputRecords, stop: stop}
+
+	chain := make([]transformers.IRecordTransformer, 0, len(recordTransformers)+2)
+	chain = append(chain, inputLimiter)
+	chain = append(chain, recordTransformers...)
+	chain = append(chain, outputLimiter)
+	return chain, inputLimiter, outputLimiter
+}
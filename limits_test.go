@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// writeTestCSV writes a CSV file with the given number of data rows and returns its path
+func writeTestCSV(t *testing.T, rows int) string {
+	t.Helper()
+	tmpFile, err := os.CreateTemp("", "mlr-test-*.csv")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	t.Cleanup(func() { os.Remove(tmpFile.Name()) })
+
+	var sb strings.Builder
+	sb.WriteString("id,name\n")
+	for i := 1; i <= rows; i++ {
+		fmt.Fprintf(&sb, "%d,name%d\n", i, i)
+	}
+	tmpFile.WriteString(sb.String())
+	tmpFile.Close()
+	return tmpFile.Name()
+}
+
+func TestPreviewFileBounded(t *testing.T) {
+	app := NewApp()
+	path := writeTestCSV(t, 100)
+	verbs := []VerbConfig{{Value: "cat", Enabled: true}}
+
+	tests := []struct {
+		name               string
+		limits             PreviewLimits
+		wantTruncated      bool
+		wantRecordsRead    int64
+		wantRecordsWritten int64
+	}{
+		{
+			name:               "No limits",
+			limits:             PreviewLimits{},
+			wantTruncated:      false,
+			wantRecordsRead:    100,
+			wantRecordsWritten: 100,
+		},
+		{
+			name:               "Input record limit",
+			limits:             PreviewLimits{MaxInputRecords: 10},
+			wantTruncated:      true,
+			wantRecordsRead:    10,
+			wantRecordsWritten: 10,
+		},
+		{
+			name:               "Output record limit",
+			limits:             PreviewLimits{MaxOutputRecords: 5},
+			wantTruncated:      true,
+			wantRecordsRead:    -1, // depends on how far the reader got before stopping
+			wantRecordsWritten: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := app.PreviewFileBounded(path, verbs, "", "--icsv", false, false, ",", "--ocsv", tt.limits)
+			if err != nil {
+				t.Fatalf("PreviewFileBounded failed: %v", err)
+			}
+			if result.Truncated != tt.wantTruncated {
+				t.Errorf("Truncated = %v, want %v", result.Truncated, tt.wantTruncated)
+			}
+			if tt.wantRecordsRead >= 0 && result.RecordsRead != tt.wantRecordsRead {
+				t.Errorf("RecordsRead = %v, want %v", result.RecordsRead, tt.wantRecordsRead)
+			}
+			if result.RecordsWritten != tt.wantRecordsWritten {
+				t.Errorf("RecordsWritten = %v, want %v", result.RecordsWritten, tt.wantRecordsWritten)
+			}
+			if int64(len(result.Output)) != result.BytesWritten {
+				t.Errorf("BytesWritten = %v, but output is %d bytes", result.BytesWritten, len(result.Output))
+			}
+		})
+	}
+}
+
+func TestPreviewFileBoundedByteLimit(t *testing.T) {
+	app := NewApp()
+	path := writeTestCSV(t, 100)
+	verbs := []VerbConfig{{Value: "cat", Enabled: true}}
+
+	result, err := app.PreviewFileBounded(path, verbs, "", "--icsv", false, false, ",", "--ocsv", PreviewLimits{MaxOutputBytes: 64})
+	if err != nil {
+		t.Fatalf("PreviewFileBounded failed: %v", err)
+	}
+	if !result.Truncated {
+		t.Errorf("Expected output to be truncated at 64 bytes")
+	}
+	if len(result.Output) != 64 || result.BytesWritten != 64 {
+		t.Errorf("Expected exactly 64 bytes of output, got %d (BytesWritten %d)", len(result.Output), result.BytesWritten)
+	}
+	if !strings.HasPrefix(result.Output, "id,name\n1,name1\n") {
+		t.Errorf("Expected output to start with the first records, got: %s", result.Output)
+	}
+}
+
+// TestPreviewFileBoundedSmallByteLimit checks that a byte limit below the
+// size of the output buffer stops the reader soon after it is reached, not
+// once the buffer has filled
+func TestPreviewFileBoundedSmallByteLimit(t *testing.T) {
+	app := NewApp()
+	path := writeTestCSV(t, 5000)
+	verbs := []VerbConfig{{Value: "cut -f id", Enabled: true}}
+
+	// Small batches, so that the reader's progress shows when it was stopped
+	result, err := app.PreviewFileBounded(path, verbs, "--records-per-batch 1", "--icsv", false, false, ",", "--ocsv", PreviewLimits{MaxOutputBytes: 100})
+	if err != nil {
+		t.Fatalf("PreviewFileBounded failed: %v", err)
+	}
+	if !result.Truncated || result.BytesWritten != 100 {
+		t.Errorf("Expected output truncated at 100 bytes, got %d (truncated %v)", result.BytesWritten, result.Truncated)
+	}
+	if result.RecordsRead >= 200 {
+		t.Errorf("RecordsRead = %d, expected the reader to stop soon after the limit", result.RecordsRead)
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime/debug"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -68,22 +71,60 @@ func getLogDirectory() (string, error) {
 // RecoverFromPanic recovers from a panic and logs it with stack trace
 func RecoverFromPanic(functionName string) {
 	if r := recover(); r != nil {
-		stackTrace := string(debug.Stack())
-		
-		// Only log if logger is initialized
-		if Log != nil {
-			Log.WithFields(logrus.Fields{
-				"function":    functionName,
-				"panic_value": r,
-				"stack_trace": stackTrace,
-			}).Error("Panic recovered")
-		}
-		
-		// Also print to stderr for immediate visibility
-		fmt.Fprintf(os.Stderr, "PANIC in %s: %v\n%s\n", functionName, r, stackTrace)
+		logPanic(functionName, r)
 	}
 }
 
+// PanicError is returned by a bound method that panicked. CorrelationID is
+// also in the panic's log entry, so a report from the UI can be matched to
+// its stack trace.
+type PanicError struct {
+	Function      string `json:"function"`
+	CorrelationID string `json:"correlationId"`
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("internal error in %s (correlation ID %s, see the log for details)", e.Function, e.CorrelationID)
+}
+
+// RecoverToError recovers from a panic like RecoverFromPanic, and sets *errp
+// to a PanicError so the method returns an error instead of zero values. It
+// must be deferred directly, by a method with a named error result.
+func RecoverToError(functionName string, errp *error) {
+	if r := recover(); r != nil {
+		*errp = &PanicError{Function: functionName, CorrelationID: logPanic(functionName, r)}
+	}
+}
+
+// logPanic logs a recovered panic with its stack trace and returns the
+// correlation ID it was logged under
+func logPanic(functionName string, r any) string {
+	stackTrace := string(debug.Stack())
+	correlationID := newCorrelationID()
+	
+	// Only log if logger is initialized
+	if Log != nil {
+		Log.WithFields(logrus.Fields{
+			"function":       functionName,
+			"correlation_id": correlationID,
+			"panic_value":    r,
+			"stack_trace":    stackTrace,
+		}).Error("Panic recovered")
+	}
+	
+	// Also print to stderr for immediate visibility
+	fmt.Fprintf(os.Stderr, "PANIC in %s [%s]: %v\n%s\n", functionName, correlationID, r, stackTrace)
+	return correlationID
+}
+
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("t%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
 // LogError is a helper to log errors with context
 func LogError(err error, context string, fields logrus.Fields) {
 	if err == nil || Log == nil {
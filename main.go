@@ -15,6 +15,9 @@ import (
 var assets embed.FS
 
 func main() {
+	// Started to check Miller arguments, this process does only that
+	runArgsCheckIfRequested()
+
 	// Initialize logger first thing
 	if err := InitLogger(); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
@@ -46,6 +49,7 @@ func main() {
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.startup,
+		ErrorFormatter:   formatError,
 		Bind: []interface{}{
 			app,
 		},
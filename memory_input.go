@@ -0,0 +1,18 @@
+package main
+
+import (
+	"strings"
+)
+
+// memoryInput hands text input to Miller without it touching disk, through
+// an in-process pipe. The returned cleanup func must be called once Miller is
+// done reading.
+func memoryInput(input string) (string, func(), error) {
+	return pipeInput(strings.NewReader(input))
+}
+
+// isPipeInput reports whether a file name is one of pipeInput's pipes, which
+// mean nothing to the user
+func isPipeInput(name string) bool {
+	return strings.HasPrefix(name, pipeInputPrefix)
+}
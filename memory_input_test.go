@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryInput(t *testing.T) {
+	input := "a,b\n1,2\n3,4\n"
+
+	name, cleanup, err := memoryInput(input)
+	if err != nil {
+		t.Fatalf("memoryInput failed: %v", err)
+	}
+	defer cleanup()
+
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", name, err)
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", name, err)
+	}
+	if string(data) != input {
+		t.Errorf("Read %q, want %q", string(data), input)
+	}
+}
+
+func TestMemoryInputCleanupWithoutReading(t *testing.T) {
+	// More than a pipe buffer's worth, so the feeder is blocked when we give up
+	input := strings.Repeat("x", 1<<20)
+
+	_, cleanup, err := memoryInput(input)
+	if err != nil {
+		t.Fatalf("memoryInput failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		cleanup()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("cleanup hung with unread input")
+	}
+}
+
+func TestPreviewLeavesNoTempFiles(t *testing.T) {
+	before, _ := filepath.Glob(filepath.Join(os.TempDir(), "mlr-input-*"))
+
+	app := NewApp()
+	_, err := app.Preview("a,b\n1,2\n", []VerbConfig{{Value: "cat", Enabled: true}}, "", "--icsv", false, false, ",", "--ojson")
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+
+	after, _ := filepath.Glob(filepath.Join(os.TempDir(), "mlr-input-*"))
+	if len(after) > len(before) {
+		t.Errorf("Preview wrote input to a temp file: %v", after)
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// pipeInputPrefix starts the name of every pipe pipeInput serves
+const pipeInputPrefix = "/dev/fd/"
+
+// pipeInput feeds r to Miller through an in-process pipe, which Miller opens
+// by its /dev/fd name. The returned cleanup func must be called once Miller
+// is done reading.
+func pipeInput(r io.Reader) (string, func(), error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		LogError(err, "Failed to create input pipe", nil)
+		return "", nil, fmt.Errorf("error creating input pipe: %v", err)
+	}
+
+	// Closing pw is what gives Miller its end of file. If Miller stops reading
+	// early, closing pr in cleanup makes this copy fail instead of block.
+	go func() {
+		io.Copy(pw, r)
+		pw.Close()
+	}()
+
+	return fmt.Sprintf("%s%d", pipeInputPrefix, pr.Fd()), func() { pr.Close() }, nil
+}
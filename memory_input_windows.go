@@ -0,0 +1,68 @@
+//go:build windows
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"golang.org/x/sys/windows"
+)
+
+// pipeInputPrefix starts the name of every pipe pipeInput serves
+const pipeInputPrefix = `\\.\pipe\mlr-desktop-input-`
+
+// pipeInput feeds r to Miller through a single-use named pipe, which Miller
+// opens by its name. The returned cleanup func must be called once Miller is
+// done reading.
+func pipeInput(r io.Reader) (string, func(), error) {
+	suffix := make([]byte, 16)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", nil, fmt.Errorf("error naming input pipe: %v", err)
+	}
+	name := pipeInputPrefix + hex.EncodeToString(suffix)
+
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return "", nil, fmt.Errorf("error naming input pipe: %v", err)
+	}
+	handle, err := windows.CreateNamedPipe(
+		namePtr,
+		windows.PIPE_ACCESS_OUTBOUND|windows.FILE_FLAG_FIRST_PIPE_INSTANCE,
+		windows.PIPE_TYPE_BYTE|windows.PIPE_WAIT|windows.PIPE_REJECT_REMOTE_CLIENTS,
+		1, 64*1024, 0, 0, nil)
+	if err != nil {
+		LogError(err, "Failed to create input pipe", nil)
+		return "", nil, fmt.Errorf("error creating input pipe: %v", err)
+	}
+
+	var connected atomic.Bool
+	pipe := os.NewFile(uintptr(handle), name)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer pipe.Close()
+		// ERROR_PIPE_CONNECTED means the client got there before we waited
+		if err := windows.ConnectNamedPipe(handle, nil); err != nil && err != windows.ERROR_PIPE_CONNECTED {
+			return
+		}
+		connected.Store(true)
+		io.Copy(pipe, r)
+	}()
+
+	cleanup := func() {
+		// If Miller never opened the pipe, connect to it ourselves so the
+		// server goroutine stops waiting, then hang up so its write fails
+		if !connected.Load() {
+			if client, err := os.Open(name); err == nil {
+				client.Close()
+			}
+		}
+		<-done
+	}
+	return name, cleanup, nil
+}
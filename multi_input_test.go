@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPreviewFilesFilenameAndFNR(t *testing.T) {
+	app := NewApp()
+	jan := writeTestCSV(t, 2)
+	feb := writeTestCSV(t, 1)
+	verbs := []VerbConfig{{Value: "put '$file = FILENAME; $fnr = FNR'", Enabled: true}}
+
+	result, err := app.PreviewFiles([]string{jan, feb}, verbs, "", "--icsv", false, false, ",", "--ocsv")
+	if err != nil {
+		t.Fatalf("PreviewFiles failed: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"id,name,file,fnr",
+		fmt.Sprintf("1,name1,%s,1", jan),
+		fmt.Sprintf("2,name2,%s,2", jan),
+		fmt.Sprintf("1,name1,%s,1", feb),
+	}, "\n") + "\n"
+	if result != want {
+		t.Errorf("PreviewFiles output = %q, want %q", result, want)
+	}
+}
+
+func TestPreviewFilesNoInput(t *testing.T) {
+	app := NewApp()
+	verbs := []VerbConfig{{Value: "cat", Enabled: true}}
+
+	if _, err := app.PreviewFiles(nil, verbs, "", "--icsv", false, false, ",", "--ocsv"); err == nil {
+		t.Errorf("Expected an error with no input files")
+	}
+}
+
+func TestRunToFileMultipleInputs(t *testing.T) {
+	app := NewApp()
+	jan := writeTestCSV(t, 3)
+	feb := writeTestCSV(t, 2)
+	outputPath := filepath.Join(t.TempDir(), "out.csv")
+
+	config := Config{
+		InputMode:    "file",
+		InputPaths:   []string{jan, feb},
+		InputFormat:  "--icsv",
+		OutputFormat: "--ocsv",
+		Verbs:        []VerbConfig{{Value: "put '$file = FILENAME'", Enabled: true}},
+	}
+
+	progress, err := app.RunToFile(config, outputPath)
+	if err != nil {
+		t.Fatalf("RunToFile failed: %v", err)
+	}
+	if progress.RecordsRead != 5 {
+		t.Errorf("RecordsRead = %d, want 5", progress.RecordsRead)
+	}
+
+	janInfo, _ := os.Stat(jan)
+	febInfo, _ := os.Stat(feb)
+	if want := janInfo.Size() + febInfo.Size(); progress.TotalBytes != want {
+		t.Errorf("TotalBytes = %d, want %d", progress.TotalBytes, want)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	// FILENAME must name the real files, not the pipes they were read through
+	if got := strings.Count(string(data), ","+jan+"\n"); got != 3 {
+		t.Errorf("Expected 3 records from %s, got %d in %q", jan, got, data)
+	}
+	if got := strings.Count(string(data), ","+feb+"\n"); got != 2 {
+		t.Errorf("Expected 2 records from %s, got %d in %q", feb, got, data)
+	}
+}
+
+func TestParseCommandMultipleFiles(t *testing.T) {
+	app := NewApp()
+
+	tests := []struct {
+		name      string
+		command   string
+		wantFiles []string
+		wantVerbs int
+	}{
+		{
+			name:      "Bare file names",
+			command:   "mlr --icsv --opprint cat jan.csv feb.csv mar.csv",
+			wantFiles: []string{"jan.csv", "feb.csv", "mar.csv"},
+			wantVerbs: 1,
+		},
+		{
+			name:      "Paths after several verbs",
+			command:   "mlr --icsv --ojson head -n 2 then put '$x = 1' /data/jan.csv ./feb.tsv",
+			wantFiles: []string{"/data/jan.csv", "./feb.tsv"},
+			wantVerbs: 2,
+		},
+		{
+			name:      "No files",
+			command:   "mlr --icsv --ojson cut -f a,b",
+			wantFiles: nil,
+			wantVerbs: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := app.ParseCommand(tt.command)
+			if err != nil {
+				t.Fatalf("ParseCommand failed: %v", err)
+			}
+			if !reflect.DeepEqual(config.inputFiles(), tt.wantFiles) {
+				t.Errorf("Input files = %v, want %v", config.inputFiles(), tt.wantFiles)
+			}
+			if len(config.Verbs) != tt.wantVerbs {
+				t.Errorf("Verbs count = %d, want %d", len(config.Verbs), tt.wantVerbs)
+			}
+			if len(tt.wantFiles) > 0 && config.InputMode != "file" {
+				t.Errorf("InputMode = %q, want file", config.InputMode)
+			}
+
+			// The command built back from the config reads the same files
+			cmd, err := app.GetConfigCommand(config)
+			if err != nil {
+				t.Fatalf("GetConfigCommand failed: %v", err)
+			}
+			roundTripped, err := app.ParseCommand(cmd)
+			if err != nil {
+				t.Fatalf("ParseCommand of %q failed: %v", cmd, err)
+			}
+			if !reflect.DeepEqual(roundTripped.inputFiles(), config.inputFiles()) {
+				t.Errorf("Round-tripped files = %v, want %v (command %q)", roundTripped.inputFiles(), config.inputFiles(), cmd)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// panicsWith is a method body that panics, recovering the way the bound
+// methods do
+func panicsWith(functionName string) (err error) {
+	defer RecoverToError(functionName, &err)
+	panic("panic in " + functionName)
+}
+
+// TestRecoverToError checks that a panic comes back as an error whose
+// correlation ID matches the panic's log entry
+func TestRecoverToError(t *testing.T) {
+	logged := captureLog(t)
+
+	err := panicsWith("SomeMethod")
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Got %v, want a PanicError", err)
+	}
+	if panicErr.Function != "SomeMethod" {
+		t.Errorf("Function = %q, want %q", panicErr.Function, "SomeMethod")
+	}
+	if panicErr.CorrelationID == "" {
+		t.Fatalf("PanicError has no correlation ID")
+	}
+	if !strings.Contains(err.Error(), panicErr.CorrelationID) {
+		t.Errorf("Error %q does not mention correlation ID %s", err, panicErr.CorrelationID)
+	}
+	if !strings.Contains(logged.String(), `"correlation_id":"`+panicErr.CorrelationID+`"`) {
+		t.Errorf("Log has no entry for correlation ID %s: %s", panicErr.CorrelationID, logged.String())
+	}
+}
+
+// TestBoundMethodsRecoverPanics checks that every method Wails binds returns
+// an error last and starts by deferring RecoverToError under its own name
+// into that error, so that a panic anywhere in it reaches the UI
+func TestBoundMethodsRecoverPanics(t *testing.T) {
+	fset := token.NewFileSet()
+	sources, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decls := map[string]*ast.FuncDecl{}
+	for _, path := range sources {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", path, err)
+		}
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil && fn.Name.IsExported() {
+				decls[fn.Name.Name] = fn
+			}
+		}
+	}
+
+	app := reflect.TypeOf(NewApp())
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	for i := 0; i < app.NumMethod(); i++ {
+		method := app.Method(i)
+		t.Run(method.Name, func(t *testing.T) {
+			if method.Type.NumOut() == 0 || method.Type.Out(method.Type.NumOut()-1) != errorType {
+				t.Fatalf("%s must return an error last, so a panic can reach the UI", method.Name)
+			}
+			fn := decls[method.Name]
+			if fn == nil {
+				t.Fatalf("No source for %s", method.Name)
+			}
+
+			results := fn.Type.Results.List
+			last := results[len(results)-1]
+			if len(last.Names) == 0 {
+				t.Fatalf("%s must name its error result for RecoverToError to set", method.Name)
+			}
+			errName := last.Names[len(last.Names)-1].Name
+
+			if len(fn.Body.List) == 0 || !defersRecoverToError(fn.Body.List[0], method.Name, errName) {
+				t.Errorf("%s must start with defer RecoverToError(%q, &%s)", method.Name, method.Name, errName)
+			}
+		})
+	}
+}
+
+// defersRecoverToError reports whether stmt is
+// defer RecoverToError(functionName, &errName)
+func defersRecoverToError(stmt ast.Stmt, functionName, errName string) bool {
+	deferStmt, ok := stmt.(*ast.DeferStmt)
+	if !ok || len(deferStmt.Call.Args) != 2 {
+		return false
+	}
+	if fun, ok := deferStmt.Call.Fun.(*ast.Ident); !ok || fun.Name != "RecoverToError" {
+		return false
+	}
+	name, ok := deferStmt.Call.Args[0].(*ast.BasicLit)
+	if !ok || name.Kind != token.STRING {
+		return false
+	}
+	if unquoted, err := strconv.Unquote(name.Value); err != nil || unquoted != functionName {
+		return false
+	}
+	addr, ok := deferStmt.Call.Args[1].(*ast.UnaryExpr)
+	if !ok || addr.Op != token.AND {
+		return false
+	}
+	ident, ok := addr.X.(*ast.Ident)
+	return ok && ident.Name == errName
+}
+
+func TestCorrelationIDsDiffer(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id := newCorrelationID()
+		if seen[id] {
+			t.Fatalf("Correlation ID %s repeated", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/johnkerl/miller/v6/pkg/transformers"
+	"github.com/mattn/go-shellwords"
+	"github.com/sirupsen/logrus"
+)
+
+// ParseCommand parses an mlr command line into a Config. It splits the words
+// the way mlr does, with Miller's own main-flag table and each verb's own
+// argument parser, so any command mlr accepts is read the same way: main
+// flags up to the first verb, verbs joined by "then", and input files after
+// the last verb. See checkMillerArgs for commands mlr rejects by exiting.
+func (a *App) ParseCommand(command string) (_ Config, err error) {
+	defer RecoverToError("ParseCommand", &err)
+
+	LogInfo("Parsing command", logrus.Fields{"command": command})
+
+	var config Config
+	config.InputMode = "text"
+	config.FieldSeparator = ","
+
+	// Parse the command string into tokens
+	tokens, err := shellwords.Parse(command)
+	if err != nil {
+		LogError(err, "Failed to parse command string", logrus.Fields{"command": command})
+		return config, fmt.Errorf("error parsing command: %v", err)
+	}
+
+	if len(tokens) == 0 {
+		return config, fmt.Errorf("empty command")
+	}
+
+	// Skip "mlr" if it's the first token
+	if tokens[0] == "mlr" {
+		tokens = tokens[1:]
+	}
+
+	if len(tokens) == 0 {
+		return config, fmt.Errorf("empty command after removing 'mlr'")
+	}
+
+	// Main flags, each with the arguments Miller's flag table takes for it,
+	// then verbs, each taking the words its own parser consumes
+	split, err := checkMillerArgs(tokens)
+	if err != nil {
+		LogError(err, "Failed to parse command", logrus.Fields{"command": command})
+		return config, err
+	}
+	for _, verb := range split.Verbs {
+		config.Verbs = append(config.Verbs, VerbConfig{
+			Value:   joinVerbTokens(verb),
+			Enabled: true,
+		})
+	}
+
+	// Only the few flags that map to UI fields are taken out; everything else
+	// goes to the additional options
+	var inputPaths []string
+	var otherFlags []string
+	for _, group := range split.FlagGroups {
+		// Format flags in any spelling, the last one for each side winning as
+		// it does with mlr
+		if inputFormat, outputFormat, extra, ok := formatFlagGroup(group); ok {
+			if inputFormat != "" {
+				config.InputFormat = inputFormat
+			}
+			if outputFormat != "" {
+				config.OutputFormat = outputFormat
+			}
+			otherFlags = append(otherFlags, extra...)
+			continue
+		}
+
+		flag := group[0]
+		switch {
+		case len(group) > 1:
+			// Of the flags taking arguments, only --from maps to a field: an
+			// input file named before the verbs
+			if flag == "--from" {
+				inputPaths = append(inputPaths, group[1])
+				continue
+			}
+		case flag == "--ragged" || flag == "--allow-ragged-csv-input":
+			config.Ragged = true
+			continue
+		case flag == "--headerless-csv-input":
+			config.Headerless = true
+			continue
+		case flagCompression(flag) != "":
+			config.Compression = flagCompression(flag)
+			continue
+		}
+		otherFlags = append(otherFlags, joinVerbTokens(group))
+	}
+	config.Options = strings.Join(otherFlags, " ")
+
+	// Whatever follows the last verb is input files, as with mlr
+	inputPaths = append(inputPaths, split.Files...)
+	if len(inputPaths) > 0 {
+		config.InputMode = "file"
+		config.InputPath = inputPaths[0]
+		config.InputPaths = inputPaths
+	}
+
+	LogInfo("Command parsed successfully", logrus.Fields{
+		"input_format":  config.InputFormat,
+		"output_format": config.OutputFormat,
+		"verbs_count":   len(config.Verbs),
+		"options":       config.Options,
+		"input_paths":   config.inputFiles(),
+		"input_mode":    config.InputMode,
+	})
+
+	return config, nil
+}
+
+// lookupTransformer returns Miller's setup for the named verb, or nil if there
+// is no such verb
+func lookupTransformer(name string) *transformers.TransformerSetup {
+	for i := range transformers.TRANSFORMER_LOOKUP_TABLE {
+		if transformers.TRANSFORMER_LOOKUP_TABLE[i].Verb == name {
+			return &transformers.TRANSFORMER_LOOKUP_TABLE[i]
+		}
+	}
+	return nil
+}
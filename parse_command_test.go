@@ -1,6 +1,14 @@
 package main
 
-import "testing"
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/johnkerl/miller/v6/pkg/cli"
+	"github.com/mattn/go-shellwords"
+)
 
 func TestParseCommand(t *testing.T) {
 	app := NewApp()
@@ -62,12 +70,52 @@ func TestParseCommand(t *testing.T) {
 		{
 			name:           "Command with flag taking argument",
 			command:        `mlr --opprint --inidx --ifs tab --repifs filter '$1 == "PRS" && $2 == 1'`,
-			wantFormat:     "",
+			wantFormat:     "--inidx",
 			wantOutputFmt:  "--opprint",
 			wantVerbsCount: 1,
-			wantOptions:    "--inidx --ifs tab --repifs",
+			wantOptions:    "--ifs tab --repifs",
 			wantError:      false,
 		},
+		{
+			name:      "Unknown verb",
+			command:   "mlr --icsv frobnicate -n 5",
+			wantError: true,
+		},
+		{
+			name:      "Unknown main flag",
+			command:   "mlr --icsv --no-such-flag cat",
+			wantError: true,
+		},
+		{
+			name:      "Unknown verb option",
+			command:   "mlr head -x",
+			wantError: true,
+		},
+		{
+			name:      "Verb option missing its argument",
+			command:   "mlr head -n",
+			wantError: true,
+		},
+		{
+			name:      "Unknown verb option with an argument",
+			command:   "mlr sort -z f",
+			wantError: true,
+		},
+		{
+			name:      "Main flag missing its argument",
+			command:   "mlr --ifs",
+			wantError: true,
+		},
+		{
+			name:      "No verb",
+			command:   "mlr --icsv --ojson",
+			wantError: true,
+		},
+		{
+			name:      "Nothing after then",
+			command:   "mlr --icsv cat then",
+			wantError: true,
+		},
 	}
 	
 	for _, tt := range tests {
@@ -98,3 +146,315 @@ func TestParseCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCommandFormats(t *testing.T) {
+	app := NewApp()
+
+	tests := []struct {
+		command     string
+		wantInput   string
+		wantOutput  string
+		wantOptions string
+	}{
+		{command: "mlr --c2j cat", wantInput: "--icsv", wantOutput: "--ojson"},
+		{command: "mlr --icsv --opprint cat", wantInput: "--icsv", wantOutput: "--opprint"},
+		{command: "mlr --t2m cat", wantInput: "--itsv", wantOutput: "--omd"},
+		{command: "mlr --x2j cat", wantInput: "--ixtab", wantOutput: "--ojson"},
+		{command: "mlr --c2p --barred cat", wantInput: "--icsv", wantOutput: "--opprint", wantOptions: "--barred"},
+		{command: "mlr --io json cat", wantInput: "--ijson", wantOutput: "--ojson"},
+		{command: "mlr -i csv -o pprint cat", wantInput: "--icsv", wantOutput: "--opprint"},
+		{command: "mlr --csv cat", wantInput: "--icsv", wantOutput: "--ocsv"},
+		{command: "mlr -t cat", wantInput: "--itsv", wantOutput: "--otsv"},
+		{command: "mlr --csvlite cat", wantInput: "--icsvlite", wantOutput: "--ocsvlite"},
+		{command: "mlr --tsvlite --ojson cat", wantInput: "--itsvlite", wantOutput: "--ojson"},
+		{command: "mlr --c2p --ojson cat", wantInput: "--icsv", wantOutput: "--ojson"},
+		{command: "mlr --idkvp --oxtab cat", wantInput: "--idkvp", wantOutput: "--oxtab"},
+		{command: "mlr -S -n --icsv cat", wantInput: "--icsv", wantOptions: "-S -n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			config, err := app.ParseCommand(tt.command)
+			if err != nil {
+				t.Fatalf("ParseCommand failed: %v", err)
+			}
+			if config.InputFormat != tt.wantInput || config.OutputFormat != tt.wantOutput {
+				t.Errorf("Formats = %q, %q, want %q, %q", config.InputFormat, config.OutputFormat, tt.wantInput, tt.wantOutput)
+			}
+			if config.Options != tt.wantOptions {
+				t.Errorf("Options = %q, want %q", config.Options, tt.wantOptions)
+			}
+
+			// The command written back spells the formats the one way
+			command, err := app.GetConfigCommand(config)
+			if err != nil {
+				t.Fatalf("GetConfigCommand failed: %v", err)
+			}
+			for _, flag := range []string{tt.wantInput, tt.wantOutput} {
+				if flag != "" && !strings.Contains(command, flag) {
+					t.Errorf("Command %q has no %s", command, flag)
+				}
+			}
+		})
+	}
+}
+
+func TestCanonicalFormatFlag(t *testing.T) {
+	tests := []struct {
+		flag   string
+		output bool
+		want   string
+	}{
+		{flag: "--icsv", want: "--icsv"},
+		{flag: "--imarkdown", want: "--imd"},
+		{flag: "--omarkdown", output: true, want: "--omd"},
+		{flag: "--csv", want: "--csv"},
+		{flag: "--csv", output: true, want: "--csv"},
+		{flag: "--csvlite", want: "--csvlite"},
+		{flag: "--c2p", want: "--c2p"},
+		{flag: "--ojson", want: "--ojson"},
+		{flag: "--iusv", want: "--iusv"},
+	}
+
+	for _, tt := range tests {
+		if got := canonicalFormatFlag(tt.flag, tt.output); got != tt.want {
+			t.Errorf("canonicalFormatFlag(%q, %v) = %q, want %q", tt.flag, tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestBothFormatFlags(t *testing.T) {
+	want := map[string]string{
+		"-c": "csv", "--csv": "csv", "--csvlite": "csvlite",
+		"-t": "tsv", "--tsv": "tsv", "--tsvlite": "tsvlite",
+		"-j": "json", "--json": "json",
+	}
+	for flag, name := range want {
+		if got := bothFormatFlags[flag]; got != name {
+			t.Errorf("bothFormatFlags[%q] = %q, want %q", flag, got, name)
+		}
+	}
+	// -n is named after nidx's letter but means no input
+	if name, ok := bothFormatFlags["-n"]; ok {
+		t.Errorf("bothFormatFlags[\"-n\"] = %q, want none", name)
+	}
+}
+
+func TestParseCommandVerbExtents(t *testing.T) {
+	app := NewApp()
+
+	tests := []struct {
+		name        string
+		command     string
+		wantVerbs   []string
+		wantOptions string
+		wantFiles   []string
+	}{
+		{
+			name:        "Main flag without a dash-dash",
+			command:     `mlr -n put 'end { print 1 }'`,
+			wantVerbs:   []string{`put 'end { print 1 }'`},
+			wantOptions: "-n",
+		},
+		{
+			name:      "Format shorthand and a file without a separator",
+			command:   "mlr --c2p sort -nr x data.csv",
+			wantVerbs: []string{"sort -nr x"},
+			wantFiles: []string{"data.csv"},
+		},
+		{
+			name:      "Verb option that is a path",
+			command:   "mlr --icsv --ojson join -j id -f /tmp/left.csv then head -n 2 ./right.csv",
+			wantVerbs: []string{"join -j id -f /tmp/left.csv", "head -n 2"},
+			wantFiles: []string{"./right.csv"},
+		},
+		{
+			name:      "Verb argument that looks like a file",
+			command:   "mlr --icsv label data.csv",
+			wantVerbs: []string{"label data.csv"},
+		},
+		{
+			name:      "Input files before and after the verbs",
+			command:   "mlr --icsv --from a.csv cat b.csv",
+			wantVerbs: []string{"cat"},
+			wantFiles: []string{"a.csv", "b.csv"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := app.ParseCommand(tt.command)
+			if err != nil {
+				t.Fatalf("ParseCommand failed: %v", err)
+			}
+
+			var verbs []string
+			for _, verb := range config.Verbs {
+				verbs = append(verbs, verb.Value)
+			}
+			if !reflect.DeepEqual(verbs, tt.wantVerbs) {
+				t.Errorf("Verbs = %q, want %q", verbs, tt.wantVerbs)
+			}
+			if config.Options != tt.wantOptions {
+				t.Errorf("Options = %q, want %q", config.Options, tt.wantOptions)
+			}
+			if !reflect.DeepEqual(config.inputFiles(), tt.wantFiles) {
+				t.Errorf("Input files = %q, want %q", config.inputFiles(), tt.wantFiles)
+			}
+			wantMode := "text"
+			if tt.wantFiles != nil {
+				wantMode = "file"
+			}
+			if config.InputMode != wantMode {
+				t.Errorf("InputMode = %q, want %q", config.InputMode, wantMode)
+			}
+		})
+	}
+}
+
+// randomConfig returns a config picked at random from values that are awkward
+// to quote
+func randomConfig(rng *rand.Rand) Config {
+	pick := func(values ...string) string {
+		return values[rng.Intn(len(values))]
+	}
+	words := []string{"x", "my field", "it's", `say "hi"`, "$5", "a\\b", "tab\there", "back`tick", "semi;colon", "a|b", "(paren)", "*", "~home", "#hash", "", "ünï"}
+	word := func() string {
+		return words[rng.Intn(len(words))]
+	}
+
+	config := Config{
+		InputMode:      "text",
+		InputPath:      "a=1",
+		InputFormat:    pick("", "--icsv", "--itsv", "--ijson", "--ijsonl"),
+		Ragged:         rng.Intn(2) == 0,
+		Headerless:     rng.Intn(2) == 0,
+		FieldSeparator: pick(",", ";", "tab", "|", " ", "::"),
+		OutputFormat:   pick("", "--ocsv", "--otsv", "--ojson", "--ojsonl", "--opprint", "--omd", "--oxtab"),
+	}
+	if rng.Intn(2) == 0 {
+		config.InputMode = "file"
+		config.InputPath = ""
+		for n := 1 + rng.Intn(3); n > 0; n-- {
+			config.InputPaths = append(config.InputPaths, pick("data.csv", "/tmp/in.tsv", "./dir/x.json", "/tmp/my data.csv", "C:/data/y.csv"))
+		}
+		config.Compression = pick("", CompressionGzip, CompressionZstd)
+	}
+
+	var options [][]string
+	for n := rng.Intn(3); n > 0; n-- {
+		options = append(options, [][]string{
+			{"--skip-comments"},
+			{"--records-per-batch", "100"},
+			{"--ofs", word()},
+			{"--ofmt", "%.4f"},
+			{"--nr-progress-mod", "10"},
+			{"--fflush"},
+			{"-S"},
+			{"--c2p"},
+			{"--j2c"},
+			{"-c"},
+			{"--io", "json"},
+			{"-i", "tsv"},
+			{"-o", "markdown"},
+		}[rng.Intn(13)])
+	}
+	var optionWords []string
+	for _, option := range options {
+		optionWords = append(optionWords, joinVerbTokens(option))
+	}
+	config.Options = strings.Join(optionWords, " ")
+
+	for n := 1 + rng.Intn(4); n > 0; n-- {
+		var tokens []string
+		switch rng.Intn(8) {
+		case 0:
+			tokens = []string{"cat"}
+		case 1:
+			tokens = []string{"head", "-n", pick("1", "10")}
+		case 2:
+			tokens = []string{"sort", pick("-f", "-nr"), word()}
+		case 3:
+			tokens = []string{"cut", "-f", word() + "," + word()}
+		case 4:
+			tokens = []string{"put", "$" + word() + " = " + word()}
+		case 5:
+			tokens = []string{"filter", "-x", word()}
+		case 6:
+			tokens = []string{"label", word()}
+		case 7:
+			tokens = []string{"join", "-j", word(), "-f", pick("/tmp/left.csv", "/tmp/my left.csv")}
+		}
+		config.Verbs = append(config.Verbs, VerbConfig{Value: joinVerbTokens(tokens), Enabled: rng.Intn(4) > 0})
+	}
+	// GetCommand leaves out disabled verbs, but needs one to write
+	config.Verbs[0].Enabled = true
+	return config
+}
+
+// mainOptions returns what Miller makes of the main flags for config
+func mainOptions(t *testing.T, app *App, config Config) *cli.TOptions {
+	t.Helper()
+	args, err := app.constructArgs(config.Verbs, config.Options, config.InputFormat, config.Ragged, config.Headerless, config.FieldSeparator, config.OutputFormat, config.Compression)
+	if err != nil {
+		t.Fatalf("constructArgs failed: %v", err)
+	}
+	options := cli.DefaultOptions()
+	for argi := 0; argi < len(args.mainFlags); {
+		if !cli.FLAG_TABLE.Parse(args.mainFlags, len(args.mainFlags), &argi, options) {
+			t.Fatalf("Main flags %q do not parse", args.mainFlags)
+		}
+	}
+	return options
+}
+
+// enabledVerbTokens returns the words of each enabled verb
+func enabledVerbTokens(t *testing.T, verbs []VerbConfig) [][]string {
+	t.Helper()
+	var tokens [][]string
+	for _, verb := range verbs {
+		if !verb.Enabled {
+			continue
+		}
+		words, err := shellwords.Parse(verb.Value)
+		if err != nil {
+			t.Fatalf("Verb %q does not split: %v", verb.Value, err)
+		}
+		tokens = append(tokens, words)
+	}
+	return tokens
+}
+
+// TestParseCommandRoundTrip checks that parsing the command for a config gives
+// back an equivalent config: one that runs the same main flags and verbs over
+// the same input
+func TestParseCommandRoundTrip(t *testing.T) {
+	app := NewApp()
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		config := randomConfig(rng)
+		command, err := app.GetConfigCommand(config)
+		if err != nil {
+			t.Fatalf("GetConfigCommand(%+v) failed: %v", config, err)
+		}
+
+		parsed, err := app.ParseCommand(command)
+		if err != nil {
+			t.Fatalf("ParseCommand(%s) failed: %v", command, err)
+		}
+
+		if parsed.InputMode != config.InputMode {
+			t.Fatalf("%s: InputMode = %q, want %q", command, parsed.InputMode, config.InputMode)
+		}
+		if config.InputMode == "file" && !reflect.DeepEqual(parsed.inputFiles(), config.inputFiles()) {
+			t.Fatalf("%s: input files = %q, want %q", command, parsed.inputFiles(), config.inputFiles())
+		}
+		if got, want := enabledVerbTokens(t, parsed.Verbs), enabledVerbTokens(t, config.Verbs); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: verbs = %q, want %q", command, got, want)
+		}
+		if got, want := mainOptions(t, app, parsed), mainOptions(t, app, config); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: main options = %+v, want %+v", command, got, want)
+		}
+	}
+}
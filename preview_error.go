@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Phases of building and running a pipeline that a PreviewError can come from
+const (
+	PhaseOptionsTokenize = "options-tokenize" // splitting the free-form options into words
+	PhaseVerbTokenize    = "verb-tokenize"    // splitting a verb into words
+	PhaseMainFlagParse   = "main-flag-parse"  // Miller's main flags, before the verbs
+	PhaseVerbParse       = "verb-parse"       // a verb's name and options
+	PhaseRead            = "read"
+	PhaseTransform       = "transform"
+	PhaseWrite           = "write"
+)
+
+// PreviewError is an error building or running a pipeline: what went wrong,
+// in which phase, and where in the verbs and the data, as far as that is known.
+// It reaches the frontend as an object; see formatError.
+type PreviewError struct {
+	Phase     string `json:"phase"`
+	VerbIndex int    `json:"verbIndex"` // index into the verb list of the offending verb, -1 if none
+	Verb      string `json:"verb,omitempty"`
+	Token     string `json:"token,omitempty"` // the offending word, if one is to blame
+	Message   string `json:"message"`         // Miller's own diagnostic where there is one
+	FileName  string `json:"fileName,omitempty"`
+	NR        int64  `json:"nr,omitempty"`  // record number over all input, 0 if unknown
+	FNR       int64  `json:"fnr,omitempty"` // record number within FileName, 0 if unknown
+
+	// chainPosition counts the Miller verbs in the chain before the failing one
+	chainPosition int
+}
+
+func (e *PreviewError) Error() string {
+	var where []string
+	if e.VerbIndex >= 0 {
+		where = append(where, fmt.Sprintf("verb %d %q", e.VerbIndex+1, e.Verb))
+	}
+	if e.Token != "" {
+		where = append(where, fmt.Sprintf("at %q", e.Token))
+	}
+	if e.FileName != "" {
+		where = append(where, "file "+e.FileName)
+	}
+	if e.NR > 0 {
+		where = append(where, fmt.Sprintf("record %d", e.NR))
+	}
+	if e.FNR > 0 && e.FNR != e.NR {
+		where = append(where, fmt.Sprintf("record %d of the file", e.FNR))
+	}
+	if len(where) == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (%s)", e.Message, strings.Join(where, ", "))
+}
+
+// verbError returns a PreviewError blaming verbs[verbIndex]
+func verbError(phase string, verbs []VerbConfig, verbIndex int, token string, message string) *PreviewError {
+	return &PreviewError{Phase: phase, VerbIndex: verbIndex, Verb: verbs[verbIndex].Value, Token: token, Message: message}
+}
+
+// formatError is the Wails error formatter. A PreviewError reaches the
+// frontend as an object with its full text in "error"; other errors stay
+// plain strings.
+func formatError(err error) any {
+	var previewErr *PreviewError
+	if errors.As(err, &previewErr) {
+		return struct {
+			*PreviewError
+			Error string `json:"error"`
+		}{previewErr, err.Error()}
+	}
+	return err.Error()
+}
+
+// checkArgs catches bad arguments before Miller parses them in this process:
+// unknown or missing verbs, and, through checkMillerArgs, everything Miller
+// would exit the process on instead of returning an error, such as unknown
+// main flags or verb options and flags missing their arguments. Words among
+// the main flags that aren't flags or their arguments are caught too, as are
+// verbs taking more or fewer words than they were given.
+func checkArgs(args pipelineArgs, verbs []VerbConfig) error {
+	if len(args.verbs) == 0 {
+		return &PreviewError{Phase: PhaseVerbParse, VerbIndex: -1, Message: "no verbs enabled"}
+	}
+	for _, verb := range args.verbs {
+		if len(verb.tokens) == 0 {
+			return verbError(PhaseVerbParse, verbs, verb.verbIndex, "", "empty verb")
+		}
+		if !isTransformerVerb(verb.tokens[0]) {
+			return verbError(PhaseVerbParse, verbs, verb.verbIndex, verb.tokens[0], fmt.Sprintf("verb %q not found", verb.tokens[0]))
+		}
+	}
+
+	flat := args.flatten()
+	split, err := checkMillerArgs(flat)
+	var argsErr *argsError
+	if errors.As(err, &argsErr) && argsErr.Step == argsStepFlag {
+		return &PreviewError{Phase: PhaseMainFlagParse, VerbIndex: -1, Token: flat[argsErr.ArgIndex], Message: argsErr.Message}
+	}
+
+	// The main flags must end where the verbs start
+	flagWords := 0
+	for _, group := range split.FlagGroups {
+		flagWords += len(group)
+	}
+	if flagWords > len(args.mainFlags) {
+		last := split.FlagGroups[len(split.FlagGroups)-1]
+		return &PreviewError{Phase: PhaseMainFlagParse, VerbIndex: -1, Token: last[0], Message: fmt.Sprintf("option %q is missing its argument", last[0])}
+	}
+
+	if argsErr != nil {
+		switch {
+		case argsErr.ArgIndex < len(args.mainFlags):
+			// Miller took a word among the main flags for the first verb
+			return &PreviewError{Phase: PhaseMainFlagParse, VerbIndex: -1, Token: flat[argsErr.ArgIndex], Message: "expected a flag; verbs belong in the verb list"}
+		case argsErr.Step == argsStepVerb:
+			if verb, ok := args.verbAt(argsErr.ArgIndex); ok {
+				return verbError(PhaseVerbParse, verbs, verb.verbIndex, "", argsErr.Message)
+			}
+		}
+		return blameVerb(args, verbs, argsErr.Message)
+	}
+	if err != nil {
+		return &PreviewError{Phase: PhaseVerbParse, VerbIndex: -1, Message: err.Error()}
+	}
+	if flagWords < len(args.mainFlags) {
+		return &PreviewError{Phase: PhaseMainFlagParse, VerbIndex: -1, Token: flat[flagWords], Message: "expected a flag; verbs belong in the verb list"}
+	}
+
+	// Each verb must take its own words, no more and no fewer
+	for i, verb := range args.verbs {
+		var took []string
+		if i < len(split.Verbs) {
+			took = split.Verbs[i]
+		}
+		if len(took) > len(verb.tokens) {
+			return verbError(PhaseVerbParse, verbs, verb.verbIndex, took[len(verb.tokens)], fmt.Sprintf("mlr %s: an option is missing its argument", verb.tokens[0]))
+		}
+		if len(took) < len(verb.tokens) {
+			token := verb.tokens[len(took)]
+			return verbError(PhaseVerbParse, verbs, verb.verbIndex, token, fmt.Sprintf("mlr %s: unexpected argument %q", verb.tokens[0], token))
+		}
+	}
+	return nil
+}
+
+// isTransformerVerb reports whether Miller has a verb by this name
+func isTransformerVerb(name string) bool {
+	return lookupTransformer(name) != nil
+}
+
+// blameVerb turns a failure to parse the command line into a verb-parse
+// PreviewError. The main flags and verb names have been checked already, so a
+// verb is at fault; Miller's messages name the verb as "mlr verb:".
+func blameVerb(args pipelineArgs, verbs []VerbConfig, message string) error {
+	for _, verb := range args.verbs {
+		if strings.Contains(message, "mlr "+verb.tokens[0]+":") {
+			return verbError(PhaseVerbParse, verbs, verb.verbIndex, "", message)
+		}
+	}
+	return &PreviewError{Phase: PhaseVerbParse, VerbIndex: -1, Message: message}
+}
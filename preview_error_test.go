@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPreviewErrorMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  PreviewError
+		want string
+	}{
+		{
+			name: "Main flag",
+			err:  PreviewError{Phase: PhaseMainFlagParse, Message: `option "--bogus" not recognized`, VerbIndex: -1, Token: "--bogus"},
+			want: `option "--bogus" not recognized (at "--bogus")`,
+		},
+		{
+			name: "Verb and token",
+			err:  PreviewError{Phase: PhaseVerbParse, Message: `verb "frob" not found`, VerbIndex: 1, Verb: "frob -x", Token: "frob"},
+			want: `verb "frob" not found (verb 2 "frob -x", at "frob")`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPipelineErrors(t *testing.T) {
+	app := NewApp()
+	cat := VerbConfig{Value: "cat", Enabled: true}
+
+	tests := []struct {
+		name          string
+		verbs         []VerbConfig
+		options       string
+		compression   string
+		wantPhase     string
+		wantVerbIndex int
+		wantToken     string
+	}{
+		{
+			name:          "Unterminated quote in options",
+			verbs:         []VerbConfig{cat},
+			options:       "--ifs 'unterminated",
+			wantPhase:     PhaseOptionsTokenize,
+			wantVerbIndex: -1,
+			wantToken:     "--ifs 'unterminated",
+		},
+		{
+			name:          "Unterminated quote in a verb",
+			verbs:         []VerbConfig{cat, {Value: "put '$x = 1", Enabled: true}},
+			wantPhase:     PhaseVerbTokenize,
+			wantVerbIndex: 1,
+		},
+		{
+			name:          "Unknown main flag",
+			verbs:         []VerbConfig{cat},
+			options:       "--no-such-flag",
+			wantPhase:     PhaseMainFlagParse,
+			wantVerbIndex: -1,
+			wantToken:     "--no-such-flag",
+		},
+		{
+			name:          "Verb among the main flags",
+			verbs:         []VerbConfig{cat},
+			options:       "--icsv head",
+			wantPhase:     PhaseMainFlagParse,
+			wantVerbIndex: -1,
+			wantToken:     "head",
+		},
+		{
+			name:          "Main flag missing its argument",
+			verbs:         []VerbConfig{cat},
+			options:       "--ifs",
+			wantPhase:     PhaseMainFlagParse,
+			wantVerbIndex: -1,
+			wantToken:     "--ifs",
+		},
+		{
+			name:          "Unknown verb option",
+			verbs:         []VerbConfig{cat, {Value: "head -x", Enabled: true}},
+			wantPhase:     PhaseVerbParse,
+			wantVerbIndex: 1,
+		},
+		{
+			name:          "Verb option missing its argument",
+			verbs:         []VerbConfig{{Value: "head -n", Enabled: true}, cat},
+			wantPhase:     PhaseVerbParse,
+			wantVerbIndex: 0,
+			wantToken:     "then",
+		},
+		{
+			name:          "Verb option missing its argument at the end",
+			verbs:         []VerbConfig{cat, {Value: "head -n", Enabled: true}},
+			wantPhase:     PhaseVerbParse,
+			wantVerbIndex: 1,
+		},
+		{
+			name:          "Extra word in a verb",
+			verbs:         []VerbConfig{{Value: "head 5", Enabled: true}, cat},
+			wantPhase:     PhaseVerbParse,
+			wantVerbIndex: 0,
+			wantToken:     "5",
+		},
+		{
+			name:          "Unknown verb option after a disabled verb",
+			verbs:         []VerbConfig{{Value: "head -x", Enabled: false}, cat, {Value: "sort -z f", Enabled: true}},
+			wantPhase:     PhaseVerbParse,
+			wantVerbIndex: 2,
+		},
+		{
+			name:          "Unknown compression",
+			verbs:         []VerbConfig{cat},
+			compression:   "lzma",
+			wantPhase:     PhaseMainFlagParse,
+			wantVerbIndex: -1,
+			wantToken:     "lzma",
+		},
+		{
+			name:          "Unknown verb after a disabled one",
+			verbs:         []VerbConfig{{Value: "nosuchverb", Enabled: false}, cat, {Value: "nosuchverb -x", Enabled: true}},
+			wantPhase:     PhaseVerbParse,
+			wantVerbIndex: 2,
+			wantToken:     "nosuchverb",
+		},
+		{
+			name:          "Empty verb",
+			verbs:         []VerbConfig{cat, {Value: "  ", Enabled: true}},
+			wantPhase:     PhaseVerbParse,
+			wantVerbIndex: 1,
+		},
+		{
+			name:          "No verbs enabled",
+			verbs:         []VerbConfig{{Value: "cat", Enabled: false}},
+			wantPhase:     PhaseVerbParse,
+			wantVerbIndex: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := app.buildPipeline(tt.verbs, tt.options, "--icsv", false, false, ",", "--ojson", tt.compression)
+
+			var previewErr *PreviewError
+			if !errors.As(err, &previewErr) {
+				t.Fatalf("Expected a *PreviewError, got %T: %v", err, err)
+			}
+			if previewErr.Phase != tt.wantPhase {
+				t.Errorf("Phase = %q, want %q", previewErr.Phase, tt.wantPhase)
+			}
+			if previewErr.VerbIndex != tt.wantVerbIndex {
+				t.Errorf("VerbIndex = %d, want %d", previewErr.VerbIndex, tt.wantVerbIndex)
+			}
+			if tt.wantVerbIndex >= 0 && previewErr.Verb != tt.verbs[tt.wantVerbIndex].Value {
+				t.Errorf("Verb = %q, want %q", previewErr.Verb, tt.verbs[tt.wantVerbIndex].Value)
+			}
+			if previewErr.Token != tt.wantToken {
+				t.Errorf("Token = %q, want %q", previewErr.Token, tt.wantToken)
+			}
+			if previewErr.Message == "" {
+				t.Errorf("Expected a message")
+			}
+		})
+	}
+}
+
+func TestPreviewSurvivesBadVerbOptions(t *testing.T) {
+	app := NewApp()
+
+	for _, verb := range []string{"head -x", "head -n", "sort -z f"} {
+		verbs := []VerbConfig{{Value: "cat", Enabled: true}, {Value: verb, Enabled: true}}
+		_, err := app.Preview("a,b\n1,2\n", verbs, "", "--icsv", false, false, ",", "--ojson")
+
+		var previewErr *PreviewError
+		if !errors.As(err, &previewErr) {
+			t.Fatalf("Expected a *PreviewError for %q, got %T: %v", verb, err, err)
+		}
+		if previewErr.Phase != PhaseVerbParse || previewErr.VerbIndex != 1 {
+			t.Errorf("%q: Phase = %q, VerbIndex = %d, want %q and 1", verb, previewErr.Phase, previewErr.VerbIndex, PhaseVerbParse)
+		}
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	previewErr := &PreviewError{Phase: PhaseVerbParse, VerbIndex: 0, Verb: "nosuchverb", Token: "nosuchverb", Message: `verb "nosuchverb" not found`}
+
+	data, err := json.Marshal(formatError(previewErr))
+	if err != nil {
+		t.Fatalf("Failed to marshal formatted error: %v", err)
+	}
+	var got map[string]interface{}
+	json.Unmarshal(data, &got)
+
+	if got["phase"] != PhaseVerbParse || got["verbIndex"] != float64(0) || got["token"] != "nosuchverb" {
+		t.Errorf("Formatted error = %s, want phase, verb index and token", data)
+	}
+	if got["error"] != previewErr.Error() {
+		t.Errorf("error = %v, want %q", got["error"], previewErr.Error())
+	}
+
+	if got := formatError(errors.New("preview cancelled")); got != "preview cancelled" {
+		t.Errorf("formatError of a plain error = %v, want the plain string", got)
+	}
+}
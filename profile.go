@@ -0,0 +1,134 @@
+package main
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/johnkerl/miller/v6/pkg/transformers"
+	"github.com/johnkerl/miller/v6/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// VerbProfile holds timing and record counts for one verb in the pipeline
+type VerbProfile struct {
+	VerbIndex     int     `json:"verbIndex"` // index into the verb list, -1 if unknown
+	Verb          string  `json:"verb"`
+	WallTimeMs    float64 `json:"wallTimeMs"`
+	RecordsIn     int64   `json:"recordsIn"`
+	RecordsOut    int64   `json:"recordsOut"`
+	PeakBatchSize int64   `json:"peakBatchSize"`
+}
+
+// ProfiledPreview holds a preview's output and the profile of each verb that
+// ran for it. Output served from the cache comes with the profile of the run
+// that produced it.
+type ProfiledPreview struct {
+	Output  string        `json:"output"`
+	Profile []VerbProfile `json:"profile"`
+}
+
+// profilingTransformer wraps a transformer and measures the time spent in it,
+// the records going in and out, and the largest batch it was handed.
+// It is only touched from its own transformer goroutine; read it after the run.
+type profilingTransformer struct {
+	inner         transformers.IRecordTransformer
+	wallTime      time.Duration
+	recordsIn     int64
+	recordsOut    int64
+	peakBatchSize int64
+
+	// Miller hands every batch a fresh output list, which marks batch boundaries
+	currentBatch     *list.List
+	currentBatchSize int64
+}
+
+func (p *profilingTransformer) Transform(
+	inrecAndContext *types.RecordAndContext,
+	outputRecordsAndContexts *list.List,
+	inputDownstreamDoneChannel <-chan bool,
+	outputDownstreamDoneChannel chan<- bool,
+) {
+	if outputRecordsAndContexts != p.currentBatch {
+		p.currentBatch = outputRecordsAndContexts
+		p.currentBatchSize = 0
+	}
+	if !inrecAndContext.EndOfStream {
+		p.recordsIn++
+		p.currentBatchSize++
+		if p.currentBatchSize > p.peakBatchSize {
+			p.peakBatchSize = p.currentBatchSize
+		}
+	}
+
+	before := outputRecordsAndContexts.Back()
+	start := time.Now()
+	p.inner.Transform(inrecAndContext, outputRecordsAndContexts, inputDownstreamDoneChannel, outputDownstreamDoneChannel)
+	p.wallTime += time.Since(start)
+
+	p.recordsOut += int64(len(appendedRecords(outputRecordsAndContexts, before)))
+}
+
+// appendedRecords returns the records added to the list after before, which
+// is the list's last element before a Transform call (nil if it was empty)
+func appendedRecords(recordsAndContexts *list.List, before *list.Element) []*types.RecordAndContext {
+	e := recordsAndContexts.Front()
+	if before != nil {
+		e = before.Next()
+	}
+
+	var records []*types.RecordAndContext
+	for ; e != nil; e = e.Next() {
+		recordAndContext := e.Value.(*types.RecordAndContext)
+		if recordAndContext.Record != nil {
+			records = append(records, recordAndContext)
+		}
+	}
+	return records
+}
+
+// withProfiling wraps every transformer in the chain in a profilingTransformer
+func withProfiling(recordTransformers []transformers.IRecordTransformer) ([]transformers.IRecordTransformer, []*profilingTransformer) {
+	profiled := make([]transformers.IRecordTransformer, len(recordTransformers))
+	profilers := make([]*profilingTransformer, len(recordTransformers))
+	for i, recordTransformer := range recordTransformers {
+		profilers[i] = &profilingTransformer{inner: recordTransformer}
+		profiled[i] = profilers[i]
+	}
+	return profiled, profilers
+}
+
+// enabledVerbIndexes returns the indexes of the enabled verbs. Miller's
+// transformer chain lines up with these, in order.
+func enabledVerbIndexes(verbs []VerbConfig) []int {
+	var indexes []int
+	for i, verb := range verbs {
+		if verb.Enabled {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// buildProfile collects the measurements of a finished run, matched up with
+// the verbs they belong to, and writes them to the log
+func buildProfile(verbs []VerbConfig, profilers []*profilingTransformer) []VerbProfile {
+	enabledVerbs := enabledVerbIndexes(verbs)
+
+	profile := make([]VerbProfile, len(profilers))
+	for i, p := range profilers {
+		profile[i] = VerbProfile{
+			VerbIndex:     -1,
+			WallTimeMs:    float64(p.wallTime.Microseconds()) / 1000,
+			RecordsIn:     p.recordsIn,
+			RecordsOut:    p.recordsOut,
+			PeakBatchSize: p.peakBatchSize,
+		}
+		if i < len(enabledVerbs) {
+			profile[i].VerbIndex = enabledVerbs[i]
+			profile[i].Verb = verbs[enabledVerbs[i]].Value
+		}
+	}
+
+	LogInfo("Pipeline profile", logrus.Fields{"profile": profile})
+	return profile
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPreviewFileBoundedProfile(t *testing.T) {
+	app := NewApp()
+	path := writeTestCSV(t, 20)
+	verbs := []VerbConfig{
+		{Value: "head -n 5", Enabled: true},
+		{Value: "cat", Enabled: false},
+		{Value: "tac", Enabled: true},
+	}
+
+	result, err := app.PreviewFileBounded(path, verbs, "", "--icsv", false, false, ",", "--ocsv", PreviewLimits{})
+	if err != nil {
+		t.Fatalf("PreviewFileBounded failed: %v", err)
+	}
+	if len(result.Profile) != 2 {
+		t.Fatalf("Expected a profile entry per enabled verb, got %d", len(result.Profile))
+	}
+
+	head, tac := result.Profile[0], result.Profile[1]
+	if head.VerbIndex != 0 || head.Verb != "head -n 5" {
+		t.Errorf("Profile 0 = verb %d %q, want verb 0 %q", head.VerbIndex, head.Verb, "head -n 5")
+	}
+	if head.RecordsIn != 20 || head.RecordsOut != 5 {
+		t.Errorf("head records in/out = %d/%d, want 20/5", head.RecordsIn, head.RecordsOut)
+	}
+	if head.PeakBatchSize < 1 || head.PeakBatchSize > head.RecordsIn {
+		t.Errorf("head PeakBatchSize = %d, want between 1 and %d", head.PeakBatchSize, head.RecordsIn)
+	}
+	if tac.VerbIndex != 2 || tac.RecordsIn != 5 || tac.RecordsOut != 5 {
+		t.Errorf("tac profile = %+v, want verb 2 with 5 records in and out", tac)
+	}
+	if head.WallTimeMs < 0 || tac.WallTimeMs < 0 {
+		t.Errorf("Expected non-negative wall times, got %v and %v", head.WallTimeMs, tac.WallTimeMs)
+	}
+}
+
+func TestPreviewFilesWithProfile(t *testing.T) {
+	app := NewApp()
+	path := writeTestCSV(t, 20)
+	verbs := []VerbConfig{
+		{Value: "head -n 5", Enabled: true},
+		{Value: "tac", Enabled: true},
+	}
+
+	result, err := app.PreviewFilesWithProfile([]string{path}, verbs, "", "--icsv", false, false, ",", "--ocsv")
+	if err != nil {
+		t.Fatalf("PreviewFilesWithProfile failed: %v", err)
+	}
+	if result.Output == "" || len(result.Profile) != 2 {
+		t.Fatalf("Expected output and a profile entry per verb, got %q and %+v", result.Output, result.Profile)
+	}
+	if head := result.Profile[0]; head.VerbIndex != 0 || head.RecordsIn != 20 || head.RecordsOut != 5 {
+		t.Errorf("head profile = %+v, want verb 0 with 20 records in and 5 out", head)
+	}
+
+	// Output served from the cache keeps the profile of the run behind it
+	cached, err := app.PreviewFilesWithProfile([]string{path}, verbs, "", "--icsv", false, false, ",", "--ocsv")
+	if err != nil {
+		t.Fatalf("PreviewFilesWithProfile failed: %v", err)
+	}
+	if cached.Output != result.Output || len(cached.Profile) != 2 || cached.Profile[0].RecordsIn != 20 {
+		t.Errorf("Cached preview = %+v, want the first run's output and profile", cached)
+	}
+}
+
+func TestPreviewWithProfile(t *testing.T) {
+	app := NewApp()
+	input := "a=1\na=2\na=3\n"
+	verbs := []VerbConfig{
+		{Value: "cat", Enabled: true},
+		{Value: "head -n 2", Enabled: true},
+	}
+
+	result, err := app.PreviewWithProfile(input, verbs, "", "", false, false, ",", "")
+	if err != nil {
+		t.Fatalf("PreviewWithProfile failed: %v", err)
+	}
+	if len(result.Profile) != 2 || result.Profile[1].VerbIndex != 1 || result.Profile[1].RecordsOut != 2 {
+		t.Fatalf("Profile = %+v, want cat then head with 2 records out", result.Profile)
+	}
+
+	// With only the last verb edited, the leading one is replayed from its
+	// snapshot and the profile covers the verb that ran
+	verbs[1].Value = "head -n 1"
+	result, err = app.PreviewWithProfile(input, verbs, "", "", false, false, ",", "")
+	if err != nil {
+		t.Fatalf("PreviewWithProfile failed: %v", err)
+	}
+	if len(result.Profile) != 1 || result.Profile[0].VerbIndex != 1 || result.Profile[0].RecordsIn != 3 || result.Profile[0].RecordsOut != 1 {
+		t.Errorf("Profile after replay = %+v, want head alone with 3 records in and 1 out", result.Profile)
+	}
+}
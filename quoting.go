@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Shells GetShellCommand writes commands for
+const (
+	ShellPOSIX      = "posix"      // sh, bash, zsh and the like; what ParseCommand reads
+	ShellPowerShell = "powershell" // PowerShell 7.3 or later
+	ShellCmd        = "cmd"        // cmd.exe at the prompt, rather than in a batch file
+	ShellFish       = "fish"
+)
+
+// shellQuoters quote a word for each shell so that the shell hands the
+// program exactly that word
+var shellQuoters = map[string]func(string) (string, error){
+	ShellPOSIX:      func(word string) (string, error) { return quoteIfNeeded(word), nil },
+	ShellPowerShell: func(word string) (string, error) { return quotePowerShell(word), nil },
+	ShellCmd:        quoteCmd,
+	ShellFish:       func(word string) (string, error) { return quoteFish(word), nil },
+}
+
+// quoteArg quotes a word for the given shell. Words holding a NUL byte can't
+// be passed to a program at all, nor can line breaks through cmd.exe.
+func quoteArg(shell, word string) (string, error) {
+	quoter, ok := shellQuoters[shell]
+	if !ok {
+		return "", fmt.Errorf("unknown shell %q", shell)
+	}
+	if strings.ContainsRune(word, 0) {
+		return "", fmt.Errorf("argument %q holds a NUL byte", word)
+	}
+	return quoter(word)
+}
+
+// isBareWord reports whether word is non-empty and made only of letters,
+// digits and the characters in safe
+func isBareWord(word string, safe string) bool {
+	if word == "" {
+		return false
+	}
+	for _, r := range word {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(safe, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// posixSafeChars are the characters besides letters and digits that a POSIX
+// shell word can hold unquoted. A leading = is quoted all the same, since zsh
+// expands it to a command's path.
+const posixSafeChars = "-_=+.,/:@%"
+
+// quoteIfNeeded quotes a token for a POSIX shell, unless it is made of
+// characters that mean nothing special there. Single quotes keep everything
+// literal, $field references included; a single quote itself is written by
+// closing the quotes, escaping it with a backslash and opening them again.
+func quoteIfNeeded(token string) string {
+	if isBareWord(token, posixSafeChars) && token[0] != '=' {
+		return token
+	}
+	return "'" + strings.ReplaceAll(token, "'", `'\''`) + "'"
+}
+
+// fishSafeChars are the characters besides letters and digits that a fish
+// word can hold unquoted
+const fishSafeChars = "-_=+.,/:@"
+
+// quoteFish quotes a word for fish, whose single quotes take \' and \\ as
+// escapes
+func quoteFish(word string) string {
+	if isBareWord(word, fishSafeChars) {
+		return word
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(word)
+	return "'" + escaped + "'"
+}
+
+// powerShellQuotes are the characters PowerShell takes for a single quote: the
+// ASCII one and the typographic ones
+const powerShellQuotes = "'‘’‚‛"
+
+// isPowerShellBare reports whether PowerShell passes word on as it is
+// written, unquoted. That rules out words it would read as numbers, such as
+// 1kb, and dashed words it would split or treat as its own, such as --% and
+// -a.b.
+func isPowerShellBare(word string) bool {
+	if word == "" {
+		return false
+	}
+	isASCIIAlnum := func(b byte) bool {
+		return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+	}
+	if strings.Trim(word, "0123456789") == "" {
+		return true
+	}
+	if word[0] == '-' {
+		flag := strings.TrimPrefix(strings.TrimPrefix(word, "-"), "-")
+		if flag == "" || !('a' <= flag[0] && flag[0] <= 'z' || 'A' <= flag[0] && flag[0] <= 'Z') {
+			return false
+		}
+		for i := 0; i < len(flag); i++ {
+			if !isASCIIAlnum(flag[i]) && flag[i] != '_' && flag[i] != '-' {
+				return false
+			}
+		}
+		return true
+	}
+	if !('a' <= word[0] && word[0] <= 'z' || 'A' <= word[0] && word[0] <= 'Z' || word[0] == '_' || word[0] == '/') {
+		return false
+	}
+	for i := 0; i < len(word); i++ {
+		if !isASCIIAlnum(word[i]) && !strings.ContainsRune("_./=-", rune(word[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// quotePowerShell quotes a word for PowerShell, whose single-quoted strings
+// are literal but for a quote, written twice
+func quotePowerShell(word string) string {
+	if isPowerShellBare(word) {
+		return word
+	}
+	var quoted strings.Builder
+	quoted.WriteByte('\'')
+	for i, r := range word {
+		// Copy the bytes rather than the rune, which may stand for bytes
+		// that aren't UTF-8
+		_, size := utf8.DecodeRuneInString(word[i:])
+		if strings.ContainsRune(powerShellQuotes, r) {
+			quoted.WriteString(word[i : i+size])
+		}
+		quoted.WriteString(word[i : i+size])
+	}
+	quoted.WriteByte('\'')
+	return quoted.String()
+}
+
+// cmdSafeChars are the characters besides letters and digits that cmd.exe
+// and the program's own parsing of its command line both leave alone
+const cmdSafeChars = `-_=+.,/:@\`
+
+// cmdMetaChars are the characters cmd.exe acts on; quoteCmd escapes every one
+// of them with a caret
+const cmdMetaChars = `()%!^"<>&|`
+
+// quoteCmd quotes a word for cmd.exe. cmd.exe hands the program its command
+// line as typed, and the program splits it with the Microsoft C runtime rules,
+// so the word is first quoted for those: inside double quotes, with the
+// backslashes before a quote doubled. Then every character cmd.exe acts on,
+// the double quotes included, is escaped with a caret, so that cmd.exe never
+// sees a quoted stretch in which a % would still expand.
+func quoteCmd(word string) (string, error) {
+	if strings.ContainsAny(word, "\r\n") {
+		return "", fmt.Errorf("argument %q holds a line break, which cmd.exe can't pass on", word)
+	}
+	if isBareWord(word, cmdSafeChars) {
+		return word, nil
+	}
+
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	backslashes := 0
+	for i := 0; i < len(word); i++ {
+		switch word[i] {
+		case '\\':
+			backslashes++
+			continue
+		case '"':
+			quoted.WriteString(strings.Repeat(`\`, 2*backslashes+1))
+		default:
+			quoted.WriteString(strings.Repeat(`\`, backslashes))
+		}
+		backslashes = 0
+		quoted.WriteByte(word[i])
+	}
+	quoted.WriteString(strings.Repeat(`\`, 2*backslashes))
+	quoted.WriteByte('"')
+
+	var escaped strings.Builder
+	for _, c := range []byte(quoted.String()) {
+		if strings.IndexByte(cmdMetaChars, c) >= 0 {
+			escaped.WriteByte('^')
+		}
+		escaped.WriteByte(c)
+	}
+	return escaped.String(), nil
+}
+
+// quotePath quotes an input path for the given shell, as one word or more.
+// A glob pattern is left for a POSIX shell to expand, which skips hidden files
+// as expandInputPaths does, and expanded here for the other shells. A
+// directory is written as its files, since mlr doesn't read directories. A
+// pattern that matches nothing, or a directory without files, is written as
+// it is, and fails in the shell as it would in a preview.
+func quotePath(shell, path string) ([]string, error) {
+	if !isGlobPattern(path) && !isDirectory(path) {
+		word, err := quoteArg(shell, path)
+		return []string{word}, err
+	}
+
+	if shell == ShellPOSIX && isGlobPattern(path) {
+		// Quote the stretches between the glob characters
+		var quoted strings.Builder
+		start := 0
+		for i := 0; i < len(path); i++ {
+			if strings.IndexByte(globMeta+"]", path[i]) < 0 {
+				continue
+			}
+			if start < i {
+				quoted.WriteString(quoteIfNeeded(path[start:i]))
+			}
+			quoted.WriteByte(path[i])
+			start = i + 1
+			// Go negates a class with ^, which POSIX leaves unspecified and
+			// which quoting would make literal; the shell's form is !
+			if path[i] == '[' && start < len(path) && path[start] == '^' {
+				quoted.WriteByte('!')
+				start++
+				i++
+			}
+		}
+		if start < len(path) {
+			quoted.WriteString(quoteIfNeeded(path[start:]))
+		}
+		return []string{quoted.String()}, nil
+	}
+
+	files, err := expandInputPaths([]string{path})
+	if err != nil {
+		word, err := quoteArg(shell, path)
+		return []string{word}, err
+	}
+	var words []string
+	for _, match := range files {
+		word, err := quoteArg(shell, match)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+	return words, nil
+}
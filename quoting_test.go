@@ -0,0 +1,479 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mattn/go-shellwords"
+)
+
+// The splitters below read a command line the way each shell would, as far as
+// the quoting functions use it, and fail on anything a shell would act on
+// rather than pass to the program
+
+// splitPOSIX splits a line the way a POSIX shell does
+func splitPOSIX(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == ' ':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			word.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '\\':
+			if i+1 == len(line) || line[i+1] == '\n' {
+				return nil, fmt.Errorf("backslash at the end of a line")
+			}
+			word.WriteByte(line[i+1])
+			i++
+			inWord = true
+		case strings.IndexByte("\"$`;&|<>(){}*?[]!#~\t\r\n", c) >= 0, c == '=' && !inWord:
+			return nil, fmt.Errorf("unquoted %q", c)
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// splitFish splits a line the way fish does
+func splitFish(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == ' ':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\'':
+			inWord = true
+			for i++; ; i++ {
+				if i == len(line) {
+					return nil, fmt.Errorf("unterminated single quote")
+				}
+				if line[i] == '\'' {
+					break
+				}
+				if line[i] == '\\' && i+1 < len(line) && (line[i+1] == '\\' || line[i+1] == '\'') {
+					i++
+				}
+				word.WriteByte(line[i])
+			}
+		case strings.IndexByte("\"\\$`;&|<>(){}*?[]^!#~%\t\r\n", c) >= 0:
+			return nil, fmt.Errorf("unquoted %q", c)
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// powerShellBareWord is what PowerShell passes on to a program as written
+var powerShellBareWord = regexp.MustCompile(`^([0-9]+|--?[A-Za-z][A-Za-z0-9_-]*|[A-Za-z_/][A-Za-z0-9_./=-]*)$`)
+
+// splitPowerShell splits a line the way PowerShell 7.3 does for a program it
+// runs: words are bare or single-quoted whole
+func splitPowerShell(line string) ([]string, error) {
+	isQuote := func(r rune) bool { return strings.ContainsRune("'‘’‚‛", r) }
+	var words []string
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(line[i:])
+		if !isQuote(r) {
+			end := strings.IndexByte(line[i:], ' ')
+			if end < 0 {
+				end = len(line) - i
+			}
+			if !powerShellBareWord.MatchString(line[i : i+end]) {
+				return nil, fmt.Errorf("unsafe bare word %q", line[i:i+end])
+			}
+			words = append(words, line[i:i+end])
+			i += end
+			continue
+		}
+
+		var word strings.Builder
+		for i += size; ; {
+			if i == len(line) {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			r, size := utf8.DecodeRuneInString(line[i:])
+			if isQuote(r) {
+				next, nextSize := utf8.DecodeRuneInString(line[i+size:])
+				if i+size == len(line) || !isQuote(next) {
+					i += size
+					break
+				}
+				word.WriteString(line[i : i+size])
+				i += size + nextSize
+				continue
+			}
+			word.WriteString(line[i : i+size])
+			i += size
+		}
+		if i < len(line) && line[i] != ' ' {
+			return nil, fmt.Errorf("quoted word runs into %q", line[i:])
+		}
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// splitCmd splits a line the way cmd.exe passes it to a program and the
+// program's C runtime splits it into arguments
+func splitCmd(line string) ([]string, error) {
+	// cmd.exe: carets escape outside double quotes, and % expands anywhere
+	// unless a caret breaks up the variable name
+	var passed strings.Builder
+	inQuote := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '%' && (i == 0 || line[i-1] != '^'):
+			return nil, fmt.Errorf("unescaped %%")
+		case c == '^' && !inQuote:
+			if i+1 == len(line) {
+				return nil, fmt.Errorf("caret at the end of the line")
+			}
+			passed.WriteByte(line[i+1])
+			i++
+		case c == '"':
+			inQuote = !inQuote
+			passed.WriteByte(c)
+		case !inQuote && strings.IndexByte("&|<>()!\r\n", c) >= 0:
+			return nil, fmt.Errorf("unescaped %q", c)
+		default:
+			passed.WriteByte(c)
+		}
+	}
+
+	// The C runtime: backslashes are literal but before a double quote
+	s := passed.String()
+	var words []string
+	var word strings.Builder
+	inWord, inQuote := false, false
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case (c == ' ' || c == '\t') && !inQuote:
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			i++
+		case c == '\\':
+			n := 0
+			for i+n < len(s) && s[i+n] == '\\' {
+				n++
+			}
+			if i+n < len(s) && s[i+n] == '"' {
+				word.WriteString(strings.Repeat(`\`, n/2))
+				if n%2 == 1 {
+					word.WriteByte('"')
+					n++
+				}
+			} else {
+				word.WriteString(strings.Repeat(`\`, n))
+			}
+			i += n
+			inWord = true
+		case c == '"':
+			if inQuote && i+1 < len(s) && s[i+1] == '"' {
+				word.WriteByte('"')
+				i += 2
+			} else {
+				inQuote = !inQuote
+				i++
+			}
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+			i++
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// shellSplitters read a line back the way each shell does
+var shellSplitters = map[string]func(string) ([]string, error){
+	ShellPOSIX:      splitPOSIX,
+	ShellFish:       splitFish,
+	ShellPowerShell: splitPowerShell,
+	ShellCmd:        splitCmd,
+}
+
+// quotingSeeds are words that are awkward to quote for one shell or another
+var quotingSeeds = []string{
+	"", "a", "it's", "$x", "'$total'", "a b", `"`, `\`, `\"`, `a\\`, `C:\data\`, `C:\my data\`,
+	"tab\there", "line\nbreak", "cr\r", "%PATH%", "100%", "^&|<>()!", "‘quoted’", "it‚s‛",
+	"1kb", "0x10", ".5", "--%", "--", "-a.b", "-f:x", "=cmd", "~home", "#comment", "*.csv",
+	"a,b", "{a,b}", "`date`", "$(date)", "ünïcödé", "\xff\xfe", "a\x00b",
+}
+
+// checkQuoteArg checks that every shell reads arg back from its quoted form
+func checkQuoteArg(t *testing.T, arg string) {
+	for shell, split := range shellSplitters {
+		quoted, err := quoteArg(shell, arg)
+		if err != nil {
+			if !strings.ContainsRune(arg, 0) && !(shell == ShellCmd && strings.ContainsAny(arg, "\r\n")) {
+				t.Errorf("%s: quoteArg(%q) failed: %v", shell, arg, err)
+			}
+			continue
+		}
+
+		line := "mlr " + quoted + " " + quoted + " x"
+		want := []string{"mlr", arg, arg, "x"}
+		got, err := split(line)
+		if err != nil {
+			t.Errorf("%s: %s does not split: %v", shell, line, err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: %s splits into %q, want %q", shell, line, got, want)
+		}
+
+		// ParseCommand reads POSIX commands, as far as go-shellwords takes
+		// anything but UTF-8
+		if shell == ShellPOSIX && utf8.ValidString(arg) {
+			if got, err := shellwords.Parse(line); err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("shellwords: %s splits into %q, %v, want %q", line, got, err, want)
+			}
+		}
+	}
+}
+
+func FuzzQuoteArg(f *testing.F) {
+	for _, seed := range quotingSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(checkQuoteArg)
+}
+
+func TestQuoteArgErrors(t *testing.T) {
+	if _, err := quoteArg("csh", "a"); err == nil {
+		t.Errorf("Expected an unknown shell to fail")
+	}
+	if _, err := quoteArg(ShellCmd, "a\nb"); err == nil {
+		t.Errorf("Expected a line break to fail for cmd.exe")
+	}
+	for shell := range shellSplitters {
+		if _, err := quoteArg(shell, "a\x00b"); err == nil {
+			t.Errorf("Expected a NUL byte to fail for %s", shell)
+		}
+	}
+}
+
+// TestGetShellCommandSplits checks that each shell splits the commands for
+// random configs into exactly the arguments the previews run with
+func TestGetShellCommandSplits(t *testing.T) {
+	app := NewApp()
+	rng := rand.New(rand.NewSource(2))
+
+	for i := 0; i < 200; i++ {
+		config := randomConfig(rng)
+		compression, _ := inputCompression(config)
+		args, err := app.constructArgs(config.Verbs, config.Options, config.InputFormat, config.Ragged, config.Headerless, config.FieldSeparator, config.OutputFormat, compression)
+		if err != nil {
+			t.Fatalf("constructArgs failed: %v", err)
+		}
+		want := append([]string{"mlr"}, args.flatten()...)
+		if config.InputMode == "file" {
+			want = append(want, config.inputFiles()...)
+		}
+
+		for shell, split := range shellSplitters {
+			command, err := app.GetShellCommand(config, shell)
+			if err != nil {
+				if shell == ShellCmd && strings.ContainsAny(strings.Join(want, ""), "\r\n") {
+					continue
+				}
+				t.Fatalf("GetShellCommand(%s) failed: %v", shell, err)
+			}
+			got, err := split(command)
+			if err != nil {
+				t.Fatalf("%s: %s does not split: %v", shell, command, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%s: %s splits into %q, want %q", shell, command, got, want)
+			}
+		}
+	}
+
+	if _, err := app.GetShellCommand(Config{Verbs: []VerbConfig{{Value: "cat", Enabled: true}}}, "csh"); err == nil {
+		t.Errorf("Expected an unknown shell to fail")
+	}
+}
+
+func TestQuotePathGlobs(t *testing.T) {
+	dir := writeInputTree(t, "b.csv", "a.csv", "c.txt")
+	pattern := dir + "/*.csv"
+
+	tests := []struct {
+		shell string
+		path  string
+		want  []string
+	}{
+		{shell: ShellPOSIX, path: "my data/2026-*.csv", want: []string{`'my data/2026-'*.csv`}},
+		{shell: ShellPOSIX, path: "data/[ab].csv", want: []string{`data/[ab].csv`}},
+		{shell: ShellPOSIX, path: "data/[^a].csv", want: []string{`data/[!a].csv`}},
+		{shell: ShellPowerShell, path: pattern, want: []string{quotePowerShell(dir + "/a.csv"), quotePowerShell(dir + "/b.csv")}},
+		{shell: ShellFish, path: pattern, want: []string{quoteFish(dir + "/a.csv"), quoteFish(dir + "/b.csv")}},
+		{shell: ShellPowerShell, path: "nothing/*.csv", want: []string{`'nothing/*.csv'`}},
+	}
+
+	for _, tt := range tests {
+		got, err := quotePath(tt.shell, tt.path)
+		if err != nil {
+			t.Fatalf("quotePath(%s, %q) failed: %v", tt.shell, tt.path, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("quotePath(%s, %q) = %q, want %q", tt.shell, tt.path, got, tt.want)
+		}
+	}
+}
+
+// TestGlobsWithRealShells has the POSIX shells installed here expand the
+// patterns quotePath leaves to them, and checks that they find the files
+// expandInputPaths does
+func TestGlobsWithRealShells(t *testing.T) {
+	dir := writeInputTree(t, "my data/b.csv", "my data/a.csv", "my data/.hidden.csv", "my data/c.txt")
+	patterns := []string{
+		filepath.Join(dir, "my data", "*.csv"),
+		filepath.Join(dir, "my data", ".*.csv"),
+		filepath.Join(dir, "my data", "[ab].csv"),
+		filepath.Join(dir, "my data", "[^a].csv"),
+	}
+
+	for _, program := range []string{"sh", "bash", "dash", "zsh"} {
+		path, err := exec.LookPath(program)
+		if err != nil {
+			continue
+		}
+		t.Run(program, func(t *testing.T) {
+			for _, pattern := range patterns {
+				words, err := quotePath(ShellPOSIX, pattern)
+				if err != nil {
+					t.Fatalf("quotePath(%q) failed: %v", pattern, err)
+				}
+				cmd := exec.Command(path, "-c", `printf '%s\000' `+strings.Join(words, " "))
+				cmd.Env = append(os.Environ(), "LC_ALL=C")
+				out, err := cmd.Output()
+				if err != nil {
+					t.Fatalf("%s failed: %v", program, err)
+				}
+				got := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+				want, err := expandInputPaths([]string{pattern})
+				if err != nil {
+					t.Fatalf("expandInputPaths(%q) failed: %v", pattern, err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s expanded %q to %q, want %q", program, pattern, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestQuotingWithRealShells has the shells installed here print back the
+// words they were given. cmd.exe can't run here, so its quoting is checked
+// only against splitCmd's model of its parser, in FuzzQuoteArg.
+func TestQuotingWithRealShells(t *testing.T) {
+	shells := map[string]string{"sh": ShellPOSIX, "bash": ShellPOSIX, "dash": ShellPOSIX, "zsh": ShellPOSIX, "fish": ShellFish, "pwsh": ShellPowerShell}
+	// Each shell's command to print its arguments, each ending in a NUL
+	printers := map[string]string{
+		ShellPOSIX:      `printf '%s\000'`,
+		ShellFish:       `printf '%s\000'`,
+		ShellPowerShell: `[Console]::OutputEncoding = [Text.UTF8Encoding]::new($false); & { foreach ($word in $args) { [Console]::Out.Write($word + [char]0) } }`,
+	}
+
+	var words []string
+	for _, seed := range quotingSeeds {
+		if !strings.ContainsRune(seed, 0) {
+			words = append(words, seed)
+		}
+	}
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 20; i++ {
+		config := randomConfig(rng)
+		args, err := NewApp().constructArgs(config.Verbs, config.Options, config.InputFormat, config.Ragged, config.Headerless, config.FieldSeparator, config.OutputFormat, "")
+		if err != nil {
+			t.Fatalf("constructArgs failed: %v", err)
+		}
+		words = append(words, args.flatten()...)
+	}
+
+	for program, shell := range shells {
+		path, err := exec.LookPath(program)
+		if err != nil {
+			continue
+		}
+		t.Run(program, func(t *testing.T) {
+			var want []string
+			line := printers[shell]
+			for _, word := range words {
+				// PowerShell reads its command line as UTF-8
+				if shell == ShellPowerShell && !utf8.ValidString(word) {
+					continue
+				}
+				quoted, err := quoteArg(shell, word)
+				if err != nil {
+					t.Fatalf("quoteArg(%q) failed: %v", word, err)
+				}
+				line += " " + quoted
+				want = append(want, word)
+			}
+
+			args := []string{"-c", line}
+			if shell == ShellPowerShell {
+				args = append([]string{"-NoProfile", "-NonInteractive"}, args...)
+			}
+			out, err := exec.Command(path, args...).Output()
+			if err != nil {
+				t.Fatalf("%s failed: %v", program, err)
+			}
+			got := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s printed %q, want %q", program, got, want)
+			}
+		})
+	}
+}
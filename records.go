@@ -0,0 +1,171 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/johnkerl/miller/v6/pkg/mlrval"
+	"github.com/johnkerl/miller/v6/pkg/transformers"
+	"github.com/johnkerl/miller/v6/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// RecordValue is one field value with its Miller type: int, float, boolean,
+// string, empty, absent, map or array. Maps and arrays carry their JSON text.
+type RecordValue struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// RecordsResult holds transformer output as a table: the field names in order
+// of first appearance, and one row per record with a value for every field
+type RecordsResult struct {
+	Fields    []string        `json:"fields"`
+	Rows      [][]RecordValue `json:"rows"`
+	Truncated bool            `json:"truncated"`
+}
+
+// recordCollector is the last transformer in a PreviewRecords chain. It keeps
+// the records instead of passing them on, so the writer never sees them.
+type recordCollector struct {
+	maxRecords int64 // 0 means unlimited
+	stop       *readerStop
+
+	mu         sync.Mutex
+	fields     []string
+	fieldIndex map[string]int
+	rows       [][]RecordValue
+}
+
+func (c *recordCollector) Transform(
+	inrecAndContext *types.RecordAndContext,
+	outputRecordsAndContexts *list.List,
+	inputDownstreamDoneChannel <-chan bool,
+	outputDownstreamDoneChannel chan<- bool,
+) {
+	transformers.HandleDefaultDownstreamDone(inputDownstreamDoneChannel, outputDownstreamDoneChannel)
+
+	if inrecAndContext.EndOfStream {
+		outputRecordsAndContexts.PushBack(inrecAndContext)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxRecords > 0 && int64(len(c.rows)) >= c.maxRecords {
+		c.stop.Stop()
+		return
+	}
+
+	row := make([]RecordValue, len(c.fields))
+	for pe := inrecAndContext.Record.Head; pe != nil; pe = pe.Next {
+		i, ok := c.fieldIndex[pe.Key]
+		if !ok {
+			i = len(c.fields)
+			c.fields = append(c.fields, pe.Key)
+			c.fieldIndex[pe.Key] = i
+			row = append(row, RecordValue{})
+		}
+		row[i] = toRecordValue(pe.Value)
+	}
+	c.rows = append(c.rows, row)
+}
+
+// result pads every row out to the full field list and returns the table
+func (c *recordCollector) result() RecordsResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, row := range c.rows {
+		for len(row) < len(c.fields) {
+			row = append(row, RecordValue{})
+		}
+		for j := range row {
+			if row[j].Type == "" {
+				row[j] = toRecordValue(nil)
+			}
+		}
+		c.rows[i] = row
+	}
+	return RecordsResult{Fields: c.fields, Rows: c.rows, Truncated: c.stop.truncated.Load()}
+}
+
+// toRecordValue converts a Miller value into its JSON-friendly typed form.
+// A nil value is a field the record doesn't have.
+func toRecordValue(mv *mlrval.Mlrval) RecordValue {
+	if mv == nil {
+		return RecordValue{Type: "absent", Value: nil}
+	}
+
+	typeName := mv.GetTypeName()
+	switch typeName {
+	case "int":
+		if i, ok := mv.GetIntValue(); ok {
+			return RecordValue{Type: typeName, Value: i}
+		}
+	case "float":
+		// NaN and infinities have no JSON number form
+		if f, ok := mv.GetFloatValue(); ok && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return RecordValue{Type: typeName, Value: f}
+		}
+	case "boolean":
+		return RecordValue{Type: typeName, Value: mv.String() == "true"}
+	case "empty":
+		return RecordValue{Type: typeName, Value: ""}
+	case "absent":
+		return RecordValue{Type: typeName, Value: nil}
+	}
+	return RecordValue{Type: typeName, Value: mv.String()}
+}
+
+// PreviewRecords runs the pipeline and returns the transformer output as typed
+// records, taken before any writer runs, so it doesn't depend on the output
+// format. At most maxRecords records are returned; 0 means all of them.
+// The pipeline runs on the sample of the input config.Sampling picks.
+func (a *App) PreviewRecords(config Config, maxRecords int) (_ RecordsResult, err error) {
+	defer RecoverToError("PreviewRecords", &err)
+
+	ctx, cancel, err := a.beginPreview()
+	if err != nil {
+		return RecordsResult{}, err
+	}
+	defer cancel()
+
+	LogInfo("PreviewRecords started", logrus.Fields{
+		"input_mode":  config.InputMode,
+		"verbs_count": len(config.Verbs),
+		"max_records": maxRecords,
+	})
+
+	in, mlrOptions, recordTransformers, err := a.sampledPipeline(config)
+	if err != nil {
+		return RecordsResult{}, err
+	}
+	defer in.cleanup()
+
+	stop := newReaderStop()
+	collector := &recordCollector{maxRecords: int64(maxRecords), stop: stop, fieldIndex: map[string]int{}}
+	collectingTransformers := append(append([]transformers.IRecordTransformer{}, recordTransformers...), collector)
+
+	err = runMillerTransformation(ctx, in.fileNames, mlrOptions, in.chain(collectingTransformers), io.Discard, stop.ch)
+	err = locateVerb(err, config.Verbs)
+	if err != nil {
+		if !errors.Is(err, ErrPreviewCancelled) {
+			LogError(err, "Miller transformation failed", logrus.Fields{"files": in.fileNames})
+		}
+		return RecordsResult{}, err
+	}
+
+	result := collector.result()
+
+	LogInfo("PreviewRecords completed", logrus.Fields{
+		"fields":    len(result.Fields),
+		"records":   len(result.Rows),
+		"truncated": result.Truncated,
+	})
+	return result, nil
+}
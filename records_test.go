@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPreviewRecords(t *testing.T) {
+	app := NewApp()
+	config := Config{
+		InputMode:    "text",
+		InputPath:    "a,b,c\n1,2.5,x\n3,,y\n4,5,z\n",
+		InputFormat:  "--icsv",
+		OutputFormat: "--opprint",
+		Verbs:        []VerbConfig{{Value: "cat", Enabled: true}},
+	}
+
+	result, err := app.PreviewRecords(config, 0)
+	if err != nil {
+		t.Fatalf("PreviewRecords failed: %v", err)
+	}
+
+	if len(result.Fields) != 3 || result.Fields[0] != "a" || result.Fields[1] != "b" || result.Fields[2] != "c" {
+		t.Errorf("Fields = %v, want [a b c]", result.Fields)
+	}
+	if len(result.Rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(result.Rows))
+	}
+	if result.Truncated {
+		t.Errorf("Expected no truncation without a limit")
+	}
+
+	first := result.Rows[0]
+	if first[0].Type != "int" || first[0].Value != int64(1) {
+		t.Errorf("a = %+v, want int 1", first[0])
+	}
+	if first[1].Type != "float" || first[1].Value != 2.5 {
+		t.Errorf("b = %+v, want float 2.5", first[1])
+	}
+	if first[2].Type != "string" || first[2].Value != "x" {
+		t.Errorf("c = %+v, want string x", first[2])
+	}
+	if empty := result.Rows[1][1]; empty.Type != "empty" {
+		t.Errorf("Empty field = %+v, want type empty", empty)
+	}
+
+	limited, err := app.PreviewRecords(config, 2)
+	if err != nil {
+		t.Fatalf("PreviewRecords with a limit failed: %v", err)
+	}
+	if len(limited.Rows) != 2 || !limited.Truncated {
+		t.Errorf("Expected 2 rows and truncation, got %d rows, truncated %v", len(limited.Rows), limited.Truncated)
+	}
+}
+
+func TestToRecordValueAbsent(t *testing.T) {
+	value := toRecordValue(nil)
+	if value.Type != "absent" || value.Value != nil {
+		t.Errorf("toRecordValue(nil) = %+v, want absent", value)
+	}
+}
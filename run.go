@@ -0,0 +1,396 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/johnkerl/miller/v6/pkg/cli"
+	"github.com/johnkerl/miller/v6/pkg/input"
+	"github.com/johnkerl/miller/v6/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Events emitted while RunToFile runs
+const (
+	EventRunProgress = "run:progress"
+	EventRunDone     = "run:done"
+	EventRunError    = "run:error"
+)
+
+// runProgressInterval is how often run:progress events are emitted
+const runProgressInterval = 250 * time.Millisecond
+
+// ErrRunCancelled is returned by a run that was cancelled with CancelRun
+var ErrRunCancelled = errors.New("run cancelled")
+
+// RunProgress is the payload of the run:progress, run:done and run:error
+// events, and what RunToFile returns
+type RunProgress struct {
+	OutputPath     string   `json:"outputPath"`
+	InputFiles     []string `json:"inputFiles"` // the files read, after expanding globs and directories
+	BytesRead      int64    `json:"bytesRead"`
+	TotalBytes     int64    `json:"totalBytes"` // size of all input, to put BytesRead in proportion
+	RecordsRead    int64    `json:"recordsRead"`
+	RecordsWritten int64    `json:"recordsWritten"`
+	BytesWritten   int64    `json:"bytesWritten"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r     io.Reader
+	count *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.count.Add(int64(n))
+	return n, err
+}
+
+// countedInputSource is one input of a run: a file, or the text of a
+// text-mode run, which has no name
+type countedInputSource struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+// countedInputReader is the record reader of runs. It hands each input in
+// turn to a Miller reader of its own, through a pipe that counts the bytes
+// read, and opens an input only once the one before it is done, so that a run
+// over many files holds one of them open at a time. Records are numbered on
+// across inputs as Miller numbers them across files, and FILENAME names the
+// file rather than the pipe.
+type countedInputReader struct {
+	options         *cli.TReaderOptions
+	recordsPerBatch int64
+	inputs          []countedInputSource
+	bytesRead       *atomic.Int64
+}
+
+// countedInput describes the input of config to a countedInputReader, and
+// returns the total input size
+func countedInput(config Config, options *cli.TOptions, bytesRead *atomic.Int64) (*countedInputReader, int64, error) {
+	reader := &countedInputReader{
+		options:         &options.ReaderOptions,
+		recordsPerBatch: options.ReaderOptions.RecordsPerBatch,
+		bytesRead:       bytesRead,
+	}
+	var totalBytes int64
+
+	if config.InputMode == "file" {
+		if len(config.inputFiles()) == 0 {
+			return nil, 0, errors.New("no input files")
+		}
+		for _, path := range config.inputFiles() {
+			info, err := os.Stat(path)
+			if err != nil {
+				LogError(err, "Failed to open input file", logrus.Fields{"path": path})
+				return nil, 0, err
+			}
+			totalBytes += info.Size()
+			reader.inputs = append(reader.inputs, countedInputSource{
+				name: path,
+				open: func() (io.ReadCloser, error) { return os.Open(path) },
+			})
+		}
+	} else {
+		text := config.InputPath
+		reader.inputs = append(reader.inputs, countedInputSource{
+			open: func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(text)), nil },
+		})
+		totalBytes = int64(len(text))
+	}
+	return reader, totalBytes, nil
+}
+
+// Read reads the inputs one after the other. The file names Miller passes
+// are ignored, the inputs being known already.
+func (r *countedInputReader) Read(
+	_ []string,
+	context types.Context,
+	readerChannel chan<- *list.List,
+	errorChannel chan error,
+	downstreamDoneChannel <-chan bool,
+) {
+	for _, source := range r.inputs {
+		var stopped, ok bool
+		context, stopped, ok = r.readInput(source, context, readerChannel, errorChannel, downstreamDoneChannel)
+		if !ok {
+			return
+		}
+		if stopped {
+			break
+		}
+	}
+	readerChannel <- types.NewEndOfStreamMarkerList(&context)
+}
+
+// readInput reads one input through a Miller reader of its own, passing its
+// records on, and returns the context after its last record. stopped is set
+// once the chain has said it needs no more records. ok is false if reading
+// failed; the error has gone to errorChannel then.
+func (r *countedInputReader) readInput(
+	source countedInputSource,
+	context types.Context,
+	readerChannel chan<- *list.List,
+	errorChannel chan error,
+	downstreamDoneChannel <-chan bool,
+) (_ types.Context, stopped bool, ok bool) {
+	file, err := source.open()
+	if err != nil {
+		LogError(err, "Failed to open input file", logrus.Fields{"path": source.name})
+		errorChannel <- err
+		return context, false, false
+	}
+	defer file.Close()
+	pipeName, closePipe, err := pipeInput(&countingReader{r: file, count: r.bytesRead})
+	if err != nil {
+		errorChannel <- err
+		return context, false, false
+	}
+	defer closePipe()
+	recordReader, err := input.Create(r.options, r.recordsPerBatch)
+	if err != nil {
+		errorChannel <- fmt.Errorf("error creating record reader: %v", err)
+		return context, false, false
+	}
+
+	batches := make(chan *list.List, 2)
+	readerDone := make(chan bool, 1)
+	returned := make(chan struct{})
+	go func() {
+		defer close(returned)
+		recordReader.Read([]string{pipeName}, context, batches, errorChannel, readerDone)
+	}()
+
+	// forward passes a batch on without its end of stream, and reports
+	// whether it had one
+	forward := func(batch *list.List) (endOfStream bool) {
+		for e := batch.Front(); e != nil; {
+			next := e.Next()
+			recordAndContext := e.Value.(*types.RecordAndContext)
+			if source.name != "" {
+				recordAndContext.Context.FILENAME = source.name
+			}
+			if recordAndContext.EndOfStream {
+				context = recordAndContext.Context
+				endOfStream = true
+				batch.Remove(e)
+			}
+			e = next
+		}
+		if batch.Len() > 0 {
+			readerChannel <- batch
+		}
+		return endOfStream
+	}
+
+	for {
+		select {
+		case batch := <-batches:
+			if forward(batch) {
+				<-returned
+				return context, stopped, true
+			}
+		case <-downstreamDoneChannel:
+			stopped = true
+			select {
+			case readerDone <- true:
+			default:
+			}
+		case <-returned:
+			// Miller's reader returns without an end of stream when it fails;
+			// pass on what it read before that
+			for {
+				select {
+				case batch := <-batches:
+					if forward(batch) {
+						return context, stopped, true
+					}
+				default:
+					return context, stopped, false
+				}
+			}
+		}
+	}
+}
+
+// beginRun claims the single run slot, waits for the executor to admit the
+// run, and returns a context for it. CancelRun works while it waits too. The
+// caller must call the returned func when the run is over.
+func (a *App) beginRun() (context.Context, func(), error) {
+	a.runMu.Lock()
+	if a.runCancel != nil {
+		a.runMu.Unlock()
+		return nil, nil, errors.New("a run is already in progress")
+	}
+	ctx, cancel := context.WithCancel(a.parentContext())
+	a.runCancel = cancel
+	a.runMu.Unlock()
+
+	end := func() {
+		a.runMu.Lock()
+		defer a.runMu.Unlock()
+		cancel()
+		a.runCancel = nil
+	}
+
+	// A run is never superseded by a preview
+	ctx, release, err := a.executor.acquire(ctx, false)
+	if err != nil {
+		end()
+		return nil, nil, ErrRunCancelled
+	}
+	return ctx, func() {
+		release()
+		end()
+	}, nil
+}
+
+// CancelRun cancels the RunToFile in progress, if any
+func (a *App) CancelRun() (err error) {
+	defer RecoverToError("CancelRun", &err)
+
+	a.runMu.Lock()
+	defer a.runMu.Unlock()
+
+	if a.runCancel != nil {
+		LogInfo("Cancelling run", nil)
+		a.runCancel()
+	}
+	return nil
+}
+
+// RunToFile runs the pipeline over the whole input and streams the output
+// straight into outputPath, without holding it in memory. It emits
+// run:progress events while running and run:done or run:error at the end.
+// A cancelled or failed run removes the partial output file.
+func (a *App) RunToFile(config Config, outputPath string) (_ RunProgress, err error) {
+	defer RecoverToError("RunToFile", &err)
+
+	ctx, endRun, err := a.beginRun()
+	if err != nil {
+		return RunProgress{OutputPath: outputPath}, err
+	}
+	defer endRun()
+
+	progress, err := a.runToFile(ctx, config, outputPath, func(progress RunProgress) {
+		a.emit(EventRunProgress, progress)
+	})
+	if err != nil {
+		progress.Error = err.Error()
+		a.emit(EventRunError, progress)
+		return progress, err
+	}
+	a.emit(EventRunDone, progress)
+	return progress, nil
+}
+
+// runToFile does the work of RunToFile and of jobs, once they are admitted
+// to run. It calls onProgress every runProgressInterval while running.
+// ErrRunCancelled is returned once ctx is cancelled.
+func (a *App) runToFile(ctx context.Context, config Config, outputPath string, onProgress func(RunProgress)) (RunProgress, error) {
+	progress := RunProgress{OutputPath: outputPath}
+
+	LogInfo("Run to file started", logrus.Fields{
+		"input_mode":  config.InputMode,
+		"output_path": outputPath,
+		"verbs_count": len(config.Verbs),
+	})
+
+	compression, err := inputCompression(config)
+	if err != nil {
+		return progress, err
+	}
+
+	mlrOptions, recordTransformers, err := a.buildPipeline(config.Verbs, config.Options, config.InputFormat, config.Ragged, config.Headerless, config.FieldSeparator, config.OutputFormat, compression)
+	if err != nil {
+		return progress, err
+	}
+
+	if config.InputMode == "file" {
+		config.InputPaths, err = expandInputPaths(config.inputFiles())
+		if err != nil {
+			return progress, err
+		}
+		progress.InputFiles = config.InputPaths
+	}
+
+	var bytesRead atomic.Int64
+	recordReader, totalBytes, err := countedInput(config, mlrOptions, &bytesRead)
+	if err != nil {
+		return progress, err
+	}
+	progress.TotalBytes = totalBytes
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		LogError(err, "Failed to create output file", logrus.Fields{"path": outputPath})
+		return progress, err
+	}
+
+	// Count records on their way into and out of the chain, and bytes on their way out
+	countedTransformers, inputCounter, outputCounter := withRecordLimits(recordTransformers, PreviewLimits{}, newReaderStop())
+	countedOutput := &byteLimitWriter{w: outputFile}
+
+	snapshot := func() RunProgress {
+		return RunProgress{
+			OutputPath:     outputPath,
+			InputFiles:     config.InputPaths,
+			BytesRead:      bytesRead.Load(),
+			TotalBytes:     totalBytes,
+			RecordsRead:    inputCounter.passed.Load(),
+			RecordsWritten: outputCounter.passed.Load(),
+			BytesWritten:   countedOutput.written.Load(),
+		}
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(runProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				onProgress(snapshot())
+			case <-finished:
+				return
+			}
+		}
+	}()
+
+	err = runPipeline(ctx, recordReader, nil, mlrOptions, countedTransformers, countedOutput, nil)
+	err = locateVerb(err, config.Verbs)
+	close(finished)
+	if closeErr := outputFile.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("error writing output file: %v", closeErr)
+	}
+
+	progress = snapshot()
+	if err != nil {
+		if errors.Is(err, ErrPreviewCancelled) {
+			err = ErrRunCancelled
+			LogInfo("Run to file cancelled", logrus.Fields{"output_path": outputPath})
+		} else {
+			LogError(err, "Run to file failed", logrus.Fields{"output_path": outputPath})
+		}
+		os.Remove(outputPath)
+		return progress, err
+	}
+
+	LogInfo("Run to file completed", logrus.Fields{
+		"output_path":     outputPath,
+		"bytes_read":      progress.BytesRead,
+		"records_written": progress.RecordsWritten,
+		"bytes_written":   progress.BytesWritten,
+	})
+	return progress, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"container/list"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/johnkerl/miller/v6/pkg/mlrval"
+	"github.com/johnkerl/miller/v6/pkg/output"
+	"github.com/johnkerl/miller/v6/pkg/transformers"
+	"github.com/johnkerl/miller/v6/pkg/types"
+)
+
+// setRecord fills in where in the data the error happened
+func (e *PreviewError) setRecord(context *types.Context) {
+	if context == nil {
+		return
+	}
+	if !isPipeInput(context.FILENAME) {
+		e.FileName = context.FILENAME
+	}
+	e.NR = context.NR
+	e.FNR = context.FNR
+}
+
+// locateVerb fills in which of verbs a verb failure came from. Errors other
+// than a *PreviewError pass through unchanged.
+func locateVerb(err error, verbs []VerbConfig) error {
+	var previewErr *PreviewError
+	if !errors.As(err, &previewErr) || previewErr.Phase != PhaseTransform {
+		return err
+	}
+	enabledVerbs := enabledVerbIndexes(verbs)
+	if previewErr.chainPosition < len(enabledVerbs) {
+		previewErr.VerbIndex = enabledVerbs[previewErr.chainPosition]
+		previewErr.Verb = verbs[previewErr.VerbIndex].Value
+	}
+	return err
+}
+
+// internalTransformer marks the transformers this app adds around the verbs
+// (limiters, collectors and the like), which don't count as verbs when
+// locating a failure
+type internalTransformer interface {
+	internal()
+}
+
+func (*recordLimiter) internal()   {}
+func (*recordCollector) internal() {}
+func (*recordSampler) internal()   {}
+
+// runFailure holds the first failure of a run and tells the run to stop
+type runFailure struct {
+	once sync.Once
+	ch   chan struct{}
+	err  *PreviewError
+}
+
+func newRunFailure() *runFailure {
+	return &runFailure{ch: make(chan struct{})}
+}
+
+// fail records err unless a failure was recorded already
+func (f *runFailure) fail(err *PreviewError) {
+	f.once.Do(func() {
+		f.err = err
+		close(f.ch)
+	})
+}
+
+// verbGuard runs a transformer and turns a panic in it into a PreviewError that
+// points at the record being processed, instead of crashing the app.
+// Once failed it drops all further records, passing on only the end of stream.
+type verbGuard struct {
+	inner         transformers.IRecordTransformer
+	chainPosition int
+	failure       *runFailure
+	failed        bool
+}
+
+func (g *verbGuard) Transform(
+	inrecAndContext *types.RecordAndContext,
+	outputRecordsAndContexts *list.List,
+	inputDownstreamDoneChannel <-chan bool,
+	outputDownstreamDoneChannel chan<- bool,
+) {
+	if g.failed {
+		transformers.HandleDefaultDownstreamDone(inputDownstreamDoneChannel, outputDownstreamDoneChannel)
+		if inrecAndContext.EndOfStream {
+			outputRecordsAndContexts.PushBack(inrecAndContext)
+		}
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			g.failed = true
+			previewErr := &PreviewError{
+				Phase:         PhaseTransform,
+				VerbIndex:     -1,
+				Message:       fmt.Sprintf("%v", r),
+				chainPosition: g.chainPosition,
+			}
+			if !inrecAndContext.EndOfStream {
+				previewErr.setRecord(&inrecAndContext.Context)
+			} else {
+				// Pass the end of stream on, or the rest of the chain never finishes
+				outputRecordsAndContexts.PushBack(inrecAndContext)
+			}
+			LogError(previewErr, "Verb panicked during Miller transformation", nil)
+			g.failure.fail(previewErr)
+		}
+	}()
+	g.inner.Transform(inrecAndContext, outputRecordsAndContexts, inputDownstreamDoneChannel, outputDownstreamDoneChannel)
+}
+
+// guardVerbs wraps every transformer in the chain in a verbGuard, numbering
+// the verbs among them
+func guardVerbs(recordTransformers []transformers.IRecordTransformer, failure *runFailure) []transformers.IRecordTransformer {
+	guarded := make([]transformers.IRecordTransformer, len(recordTransformers))
+	position := 0
+	for i, recordTransformer := range recordTransformers {
+		guarded[i] = &verbGuard{inner: recordTransformer, chainPosition: position, failure: failure}
+		if _, ok := recordTransformer.(internalTransformer); !ok {
+			position++
+		}
+	}
+	return guarded
+}
+
+// locatingWriter is a record writer that records the first error writing a
+// record, with the record it failed on. Miller's channel writer only prints
+// the message to stderr.
+type locatingWriter struct {
+	inner   output.IRecordWriter
+	failure *runFailure
+
+	// reachedEnd is set once the end of stream has come through to the writer
+	reachedEnd bool
+}
+
+func (w *locatingWriter) Write(outrec *mlrval.Mlrmap, context *types.Context, bufferedOutputStream *bufio.Writer, outputIsStdout bool) error {
+	if outrec == nil {
+		w.reachedEnd = true
+	}
+	err := w.inner.Write(outrec, context, bufferedOutputStream, outputIsStdout)
+	if err != nil {
+		previewErr := &PreviewError{Phase: PhaseWrite, VerbIndex: -1, Message: strings.TrimSpace(err.Error())}
+		if outrec != nil {
+			previewErr.setRecord(context)
+		}
+		w.failure.fail(previewErr)
+	}
+	return err
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/johnkerl/miller/v6/pkg/climain"
+	"github.com/johnkerl/miller/v6/pkg/transformers"
+	"github.com/johnkerl/miller/v6/pkg/types"
+)
+
+// panicOnRecord is a verb that panics on record number nr
+type panicOnRecord struct {
+	nr int64
+}
+
+func (p *panicOnRecord) Transform(
+	inrecAndContext *types.RecordAndContext,
+	outputRecordsAndContexts *list.List,
+	inputDownstreamDoneChannel <-chan bool,
+	outputDownstreamDoneChannel chan<- bool,
+) {
+	if !inrecAndContext.EndOfStream && inrecAndContext.Context.NR == p.nr {
+		panic("boom")
+	}
+	outputRecordsAndContexts.PushBack(inrecAndContext)
+}
+
+func TestRunErrorWriter(t *testing.T) {
+	app := NewApp()
+	path := filepath.Join(t.TempDir(), "data.dkvp")
+	os.WriteFile(path, []byte("a=1,b=2\na=3,b=4\nc=5\n"), 0644)
+	verbs := []VerbConfig{{Value: "cat", Enabled: true}}
+
+	_, err := app.PreviewFile(path, verbs, "", "--idkvp", false, false, ",", "--ocsv")
+
+	var previewErr *PreviewError
+	if !errors.As(err, &previewErr) {
+		t.Fatalf("Expected a *PreviewError, got %T: %v", err, err)
+	}
+	if previewErr.Phase != PhaseWrite {
+		t.Errorf("Phase = %q, want %q", previewErr.Phase, PhaseWrite)
+	}
+	if !strings.Contains(previewErr.Message, "schema change") {
+		t.Errorf("Message = %q, want Miller's diagnostic", previewErr.Message)
+	}
+	if previewErr.FileName != path || previewErr.NR != 3 || previewErr.FNR != 3 {
+		t.Errorf("Location = %s NR %d FNR %d, want %s NR 3 FNR 3", previewErr.FileName, previewErr.NR, previewErr.FNR, path)
+	}
+	if !strings.Contains(err.Error(), "record 3") {
+		t.Errorf("Error() = %q, want it to name the record", err.Error())
+	}
+}
+
+func TestRunErrorWriterTextInput(t *testing.T) {
+	app := NewApp()
+	verbs := []VerbConfig{{Value: "cat", Enabled: true}}
+
+	_, err := app.Preview("a=1\nb=2\n", verbs, "--idkvp", "", false, false, ",", "--ocsv")
+
+	var previewErr *PreviewError
+	if !errors.As(err, &previewErr) {
+		t.Fatalf("Expected a *PreviewError, got %T: %v", err, err)
+	}
+	// The pipe the text is read through means nothing to the user
+	if previewErr.FileName != "" {
+		t.Errorf("FileName = %q, want it empty for text input", previewErr.FileName)
+	}
+	if previewErr.NR != 2 {
+		t.Errorf("NR = %d, want 2", previewErr.NR)
+	}
+}
+
+func TestRunErrorReader(t *testing.T) {
+	app := NewApp()
+	path := writeTestCSV(t, 2)
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("3,name3,extra\n")
+	f.Close()
+	verbs := []VerbConfig{{Value: "cat", Enabled: true}}
+
+	_, err := app.PreviewFile(path, verbs, "", "--icsv", false, false, ",", "--ojson")
+
+	var previewErr *PreviewError
+	if !errors.As(err, &previewErr) {
+		t.Fatalf("Expected a *PreviewError, got %T: %v", err, err)
+	}
+	if previewErr.Phase != PhaseRead || previewErr.VerbIndex != -1 {
+		t.Errorf("Phase = %q, VerbIndex = %d, want %q and -1", previewErr.Phase, previewErr.VerbIndex, PhaseRead)
+	}
+	if !strings.Contains(previewErr.Message, "mismatch") {
+		t.Errorf("Message = %q, want Miller's diagnostic", previewErr.Message)
+	}
+}
+
+func TestRunErrorVerbPanic(t *testing.T) {
+	path := writeTestCSV(t, 10)
+	mlrOptions, _, err := climain.ParseCommandLine([]string{"mlr", "--icsv", "--ojson", "cat"})
+	if err != nil {
+		t.Fatalf("ParseCommandLine failed: %v", err)
+	}
+
+	// The limiter is ours and doesn't count as a verb, so the panicking
+	// transformer is the first enabled verb: the second in the list
+	verbs := []VerbConfig{
+		{Value: "head -n 1", Enabled: false},
+		{Value: "put '$x = 1'", Enabled: true},
+	}
+	chain, _, _ := withRecordLimits([]transformers.IRecordTransformer{&panicOnRecord{nr: 4}}, PreviewLimits{}, newReaderStop())
+
+	err = runMillerTransformation(context.Background(), []string{path}, mlrOptions, chain, io.Discard, nil)
+	err = locateVerb(err, verbs)
+
+	var previewErr *PreviewError
+	if !errors.As(err, &previewErr) {
+		t.Fatalf("Expected a *PreviewError, got %T: %v", err, err)
+	}
+	if previewErr.Phase != PhaseTransform || previewErr.VerbIndex != 1 || previewErr.Verb != verbs[1].Value {
+		t.Errorf("Phase = %q, VerbIndex = %d, Verb = %q, want verb 1", previewErr.Phase, previewErr.VerbIndex, previewErr.Verb)
+	}
+	if previewErr.Message != "boom" {
+		t.Errorf("Message = %q, want %q", previewErr.Message, "boom")
+	}
+	if previewErr.FileName != path || previewErr.FNR != 4 {
+		t.Errorf("Location = %s FNR %d, want %s FNR 4", previewErr.FileName, previewErr.FNR, path)
+	}
+}
+
+func TestRunErrorMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  PreviewError
+		want string
+	}{
+		{
+			name: "Message only",
+			err:  PreviewError{Message: "mlr: oops", VerbIndex: -1},
+			want: "mlr: oops",
+		},
+		{
+			name: "Verb and record",
+			err:  PreviewError{Message: "boom", VerbIndex: 0, Verb: "put '$y = 1'", FileName: "feb.csv", NR: 12, FNR: 2},
+			want: `boom (verb 1 "put '$y = 1'", file feb.csv, record 12, record 2 of the file)`,
+		},
+		{
+			name: "Single file",
+			err:  PreviewError{Message: "mlr: bad", VerbIndex: -1, FileName: "jan.csv", NR: 5, FNR: 5},
+			want: "mlr: bad (file jan.csv, record 5)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunToFile(t *testing.T) {
+	app := NewApp()
+	inputPath := writeTestCSV(t, 50)
+	outputPath := filepath.Join(t.TempDir(), "out.csv")
+
+	config := Config{
+		InputMode:    "file",
+		InputPath:    inputPath,
+		InputFormat:  "--icsv",
+		OutputFormat: "--ocsv",
+		Verbs:        []VerbConfig{{Value: "head -n 10", Enabled: true}},
+	}
+
+	progress, err := app.RunToFile(config, outputPath)
+	if err != nil {
+		t.Fatalf("RunToFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if int64(len(data)) != progress.BytesWritten {
+		t.Errorf("BytesWritten = %d, but the file has %d bytes", progress.BytesWritten, len(data))
+	}
+	if progress.RecordsWritten != 10 {
+		t.Errorf("RecordsWritten = %d, want 10", progress.RecordsWritten)
+	}
+
+	info, _ := os.Stat(inputPath)
+	if progress.TotalBytes != info.Size() {
+		t.Errorf("TotalBytes = %d, want %d", progress.TotalBytes, info.Size())
+	}
+	if progress.BytesRead <= 0 || progress.BytesRead > progress.TotalBytes {
+		t.Errorf("BytesRead = %d, want between 1 and %d", progress.BytesRead, progress.TotalBytes)
+	}
+}
+
+func TestRunToFileOneAtATime(t *testing.T) {
+	app := NewApp()
+	outputPath := filepath.Join(t.TempDir(), "out.csv")
+	config := Config{
+		InputMode:    "text",
+		InputPath:    "a,b\n1,2\n",
+		InputFormat:  "--icsv",
+		OutputFormat: "--ocsv",
+		Verbs:        []VerbConfig{{Value: "cat", Enabled: true}},
+	}
+
+	// Hold the run slot so RunToFile has to wait its turn
+	ctx, endRun, err := app.beginRun()
+	if err != nil {
+		t.Fatalf("beginRun failed: %v", err)
+	}
+	if _, err := app.RunToFile(config, outputPath); err == nil {
+		t.Errorf("Expected a second concurrent run to be refused")
+	}
+	app.CancelRun()
+	if ctx.Err() == nil {
+		t.Errorf("Expected CancelRun to cancel the run in progress")
+	}
+	endRun()
+
+	if _, err := app.RunToFile(config, outputPath); err != nil {
+		t.Errorf("RunToFile failed after the previous run ended: %v", err)
+	}
+}
+
+func TestRunToFileMissingInput(t *testing.T) {
+	app := NewApp()
+	outputPath := filepath.Join(t.TempDir(), "out.csv")
+	config := Config{
+		InputMode:    "file",
+		InputPath:    filepath.Join(t.TempDir(), "missing.csv"),
+		InputFormat:  "--icsv",
+		OutputFormat: "--ocsv",
+		Verbs:        []VerbConfig{{Value: "cat", Enabled: true}},
+	}
+
+	if _, err := app.RunToFile(config, outputPath); err == nil {
+		t.Fatalf("Expected an error for a missing input file")
+	}
+	if _, err := os.Stat(outputPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected no output file to be left behind, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// TestRunToFileManyFiles runs over more files than the process may hold open
+// at once, which only works if they are opened one at a time
+func TestRunToFileManyFiles(t *testing.T) {
+	const files = 200
+	app := NewApp()
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < files; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("%03d.csv", i))
+		if err := os.WriteFile(path, []byte("id\na\nb\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		paths = append(paths, path)
+	}
+	outputPath := filepath.Join(t.TempDir(), "out.csv")
+
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		t.Skipf("Can't read the open file limit: %v", err)
+	}
+	lowered := limit
+	lowered.Cur = 64
+	if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &lowered); err != nil {
+		t.Skipf("Can't lower the open file limit: %v", err)
+	}
+	defer syscall.Setrlimit(syscall.RLIMIT_NOFILE, &limit)
+
+	config := Config{
+		InputMode:    "file",
+		InputPaths:   paths,
+		InputFormat:  "--icsv",
+		OutputFormat: "--ocsv",
+		Verbs:        []VerbConfig{{Value: "put '$file = FILENAME; $nr = NR; $fnr = FNR'", Enabled: true}},
+	}
+	progress, err := app.RunToFile(config, outputPath)
+	if err != nil {
+		t.Fatalf("RunToFile failed: %v", err)
+	}
+	if progress.RecordsRead != 2*files {
+		t.Errorf("RecordsRead = %d, want %d", progress.RecordsRead, 2*files)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2*files+1 {
+		t.Fatalf("Expected %d lines, got %d", 2*files+1, len(lines))
+	}
+	// Records are numbered on across the files and FNR starts over in each
+	for i, line := range lines[1:] {
+		want := fmt.Sprintf("%s,%s,%d,%d", "ab"[i%2:i%2+1], paths[i/2], i+1, i%2+1)
+		if line != want {
+			t.Fatalf("Line %d = %q, want %q", i+1, line, want)
+		}
+	}
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/johnkerl/miller/v6/pkg/cli"
+	"github.com/johnkerl/miller/v6/pkg/transformers"
+	"github.com/johnkerl/miller/v6/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Sampling strategies, deciding which input records a preview runs on
+const (
+	SampleFirst     = "first"     // the first Records records
+	SampleReservoir = "reservoir" // Records records picked at random across the whole input
+	SampleEvery     = "every"     // every Every-th record, at most Records of them (0 means no limit)
+	SampleJump      = "jump"      // Records lines read from Jumps places spread over the input, skipping the rest
+)
+
+// defaultSampleJumps is how many places a jump sample reads from unless told
+// otherwise
+const defaultSampleJumps = 10
+
+// SamplingConfig picks the input records a preview runs on, so that a big
+// sorted file isn't only ever seen from its top. Runs to a file ignore it.
+type SamplingConfig struct {
+	Strategy string `json:"strategy"`
+	Records  int64  `json:"records"`
+	Every    int64  `json:"every,omitempty"` // SampleEvery: the step between records taken
+	Seed     int64  `json:"seed,omitempty"`  // SampleReservoir: the same seed picks the same records
+	Jumps    int    `json:"jumps,omitempty"` // SampleJump: how many places to read from, default 10
+}
+
+func (s SamplingConfig) validate() error {
+	switch s.Strategy {
+	case SampleFirst, SampleReservoir, SampleJump:
+		if s.Records < 1 {
+			return fmt.Errorf("%s sampling needs at least 1 record, got %d", s.Strategy, s.Records)
+		}
+	case SampleEvery:
+		if s.Every < 1 {
+			return fmt.Errorf("every sampling needs a step of at least 1, got %d", s.Every)
+		}
+		if s.Records < 0 {
+			return fmt.Errorf("every sampling can't take %d records", s.Records)
+		}
+	default:
+		return fmt.Errorf("unknown sampling strategy %q", s.Strategy)
+	}
+	if s.Jumps < 0 {
+		return fmt.Errorf("jump sampling can't read from %d places", s.Jumps)
+	}
+	return nil
+}
+
+// recordSampler is the first transformer in a sampled preview's chain. It
+// passes on only the records its strategy picks, and tells the reader to stop
+// once no more will be picked.
+type recordSampler struct {
+	sampling SamplingConfig
+	rng      *rand.Rand
+	seen     int64
+	passed   int64
+	stopped  bool
+
+	// reservoir holds the records picked so far by SampleReservoir, which
+	// passes them on in input order at the end of stream
+	reservoir []sampledRecord
+}
+
+type sampledRecord struct {
+	position int64
+	record   *types.RecordAndContext
+}
+
+func newRecordSampler(sampling SamplingConfig) *recordSampler {
+	return &recordSampler{sampling: sampling, rng: rand.New(rand.NewSource(sampling.Seed))}
+}
+
+func (s *recordSampler) Transform(
+	inrecAndContext *types.RecordAndContext,
+	outputRecordsAndContexts *list.List,
+	inputDownstreamDoneChannel <-chan bool,
+	outputDownstreamDoneChannel chan<- bool,
+) {
+	select {
+	case <-inputDownstreamDoneChannel:
+		s.stopReading(outputDownstreamDoneChannel)
+	default:
+	}
+
+	if inrecAndContext.EndOfStream {
+		sort.Slice(s.reservoir, func(i, j int) bool { return s.reservoir[i].position < s.reservoir[j].position })
+		for _, picked := range s.reservoir {
+			outputRecordsAndContexts.PushBack(picked.record)
+		}
+		outputRecordsAndContexts.PushBack(inrecAndContext)
+		return
+	}
+
+	s.seen++
+	switch s.sampling.Strategy {
+	case SampleReservoir:
+		// Algorithm R: the n-th record replaces a random pick with probability Records/n
+		if int64(len(s.reservoir)) < s.sampling.Records {
+			s.reservoir = append(s.reservoir, sampledRecord{s.seen, inrecAndContext})
+			s.passed++
+		} else if j := s.rng.Int63n(s.seen); j < s.sampling.Records {
+			s.reservoir[j] = sampledRecord{s.seen, inrecAndContext}
+		}
+		return
+	case SampleEvery:
+		if (s.seen-1)%s.sampling.Every != 0 {
+			return
+		}
+	}
+
+	if s.sampling.Records > 0 && s.passed >= s.sampling.Records {
+		s.stopReading(outputDownstreamDoneChannel)
+		return
+	}
+	outputRecordsAndContexts.PushBack(inrecAndContext)
+	s.passed++
+}
+
+// stopReading tells the reader to stop, once
+func (s *recordSampler) stopReading(outputDownstreamDoneChannel chan<- bool) {
+	if !s.stopped {
+		s.stopped = true
+		outputDownstreamDoneChannel <- true
+	}
+}
+
+// skipped reports whether the sampler left out any of the records it saw
+func (s *recordSampler) skipped() bool {
+	return s.seen > s.passed
+}
+
+// sampledInput is the input of a preview with its sampling applied
+type sampledInput struct {
+	fileNames []string
+	sampler   *recordSampler // nil unless records are sampled in the chain
+	partial   bool           // a jump sample skipped parts of the input
+	cleanup   func()
+}
+
+// sampledPipeline builds config's pipeline and opens the input Miller should
+// read for it, which the caller must clean up
+func (a *App) sampledPipeline(config Config) (*sampledInput, *cli.TOptions, []transformers.IRecordTransformer, error) {
+	if config.Sampling != nil {
+		if err := config.Sampling.validate(); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	compression, err := inputCompression(config)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	mlrOptions, recordTransformers, err := a.buildPipeline(config.Verbs, config.Options, config.InputFormat, config.Ragged, config.Headerless, config.FieldSeparator, config.OutputFormat, compression)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	in, err := openSampledInput(config, &mlrOptions.ReaderOptions)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return in, mlrOptions, recordTransformers, nil
+}
+
+// openSampledInput returns the input Miller should read for config, with the
+// reader options its pipeline parsed to. A jump sample is read up front and
+// served from memory; the other strategies pick records as they come through
+// the chain.
+func openSampledInput(config Config, readerOptions *cli.TReaderOptions) (*sampledInput, error) {
+	sampling := config.Sampling
+	if sampling == nil || sampling.Strategy != SampleJump {
+		fileNames, cleanup, err := configInput(config)
+		if err != nil {
+			return nil, err
+		}
+		in := &sampledInput{fileNames: fileNames, cleanup: cleanup}
+		if sampling != nil {
+			in.sampler = newRecordSampler(*sampling)
+		}
+		return in, nil
+	}
+
+	sample, partial, err := jumpSampleInput(config, *sampling, readerOptions)
+	if err != nil {
+		return nil, err
+	}
+	inputName, cleanup, err := memoryInput(sample)
+	if err != nil {
+		return nil, err
+	}
+	return &sampledInput{fileNames: []string{inputName}, partial: partial, cleanup: cleanup}, nil
+}
+
+// chain puts the sampler, if any, in front of the transformer chain
+func (in *sampledInput) chain(recordTransformers []transformers.IRecordTransformer) []transformers.IRecordTransformer {
+	if in.sampler == nil {
+		return recordTransformers
+	}
+	return append([]transformers.IRecordTransformer{in.sampler}, recordTransformers...)
+}
+
+// truncated reports whether the sample left out any of the input
+func (in *sampledInput) truncated() bool {
+	if in.sampler != nil {
+		return in.sampler.skipped()
+	}
+	return in.partial
+}
+
+// jumpSampleInput reads a jump sample of config's input, which must be one
+// uncompressed file or the input text, in a format with one record per line.
+// The format and header come from the reader options Miller parsed, so that
+// flags in Options count as much as InputFormat does.
+func jumpSampleInput(config Config, sampling SamplingConfig, readerOptions *cli.TReaderOptions) (string, bool, error) {
+	headerLines := 0
+	quotable := false
+	switch format := readerOptions.InputFileFormat; format {
+	case "csv", "csvlite":
+		// A quoted field can hold line breaks, which a jump could land
+		// between
+		quotable = true
+		fallthrough
+	case "tsv", "tsvlite":
+		if !readerOptions.UseImplicitHeader {
+			headerLines = 1
+		}
+	case "dkvp", "nidx", "jsonl":
+	default:
+		return "", false, fmt.Errorf("jump sampling needs one record per line, which %s input doesn't have", format)
+	}
+
+	jumps := sampling.Jumps
+	if jumps == 0 {
+		jumps = defaultSampleJumps
+	}
+
+	if config.InputMode != "file" {
+		return checkedJumpSample(strings.NewReader(config.InputPath), int64(len(config.InputPath)), headerLines, jumps, sampling.Records, quotable)
+	}
+
+	files, err := expandInputPaths(config.inputFiles())
+	if err != nil {
+		return "", false, err
+	}
+	if len(files) != 1 {
+		return "", false, fmt.Errorf("jump sampling reads one file, not %d", len(files))
+	}
+	compression, err := inputCompression(config)
+	if err != nil {
+		return "", false, err
+	}
+	if compression != "" {
+		return "", false, fmt.Errorf("jump sampling can't seek in %s compressed files", compression)
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", false, err
+	}
+	return checkedJumpSample(f, info.Size(), headerLines, jumps, sampling.Records, quotable)
+}
+
+// checkedJumpSample is jumpSample, refusing a sample of input whose fields
+// may be quoted if any quote shows up in it. Lines without quotes are one
+// record each; a quote may open a field that runs over several lines. A jump
+// landing within such a field, none of whose sampled lines hold a quote, goes
+// unnoticed.
+func checkedJumpSample(r io.ReaderAt, size int64, headerLines int, jumps int, records int64, quotable bool) (string, bool, error) {
+	sample, partial, err := jumpSample(r, size, headerLines, jumps, records)
+	if err != nil {
+		return "", false, err
+	}
+	if quotable && strings.Contains(sample, `"`) {
+		return "", false, errors.New("jump sampling can't tell where CSV records start when fields are quoted, since a quoted field may span lines; use another sampling strategy")
+	}
+	return sample, partial, nil
+}
+
+// jumpSample copies the first headerLines lines of r, then lines from jumps
+// places spread evenly over the rest, records lines in all. Each place starts
+// at the first whole line at or after its offset. It also reports whether any
+// lines were left out.
+func jumpSample(r io.ReaderAt, size int64, headerLines int, jumps int, records int64) (string, bool, error) {
+	var sample bytes.Buffer
+	header := bufio.NewReader(io.NewSectionReader(r, 0, size))
+	bodyStart := int64(0)
+	for i := 0; i < headerLines; i++ {
+		line, err := header.ReadString('\n')
+		sample.WriteString(line)
+		bodyStart += int64(len(line))
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", false, err
+		}
+	}
+	if line := sample.Bytes(); len(line) > 0 && line[len(line)-1] != '\n' {
+		sample.WriteByte('\n')
+	}
+
+	if int64(jumps) > records {
+		jumps = int(records)
+	}
+	perJump := (records + int64(jumps) - 1) / int64(jumps)
+	bodySize := size - bodyStart
+	taken := int64(0)
+	partial := false
+
+	for i := 0; i < jumps && taken < records; i++ {
+		start := bodyStart + int64(i)*bodySize/int64(jumps)
+		end := bodyStart + int64(i+1)*bodySize/int64(jumps)
+		reader := bufio.NewReader(io.NewSectionReader(r, start, size-start))
+		position := start
+
+		// Unless the offset is at the start of a line, skip to the next one
+		if start > bodyStart {
+			previous := make([]byte, 1)
+			if _, err := r.ReadAt(previous, start-1); err != nil {
+				return "", false, err
+			}
+			if previous[0] != '\n' {
+				skipped, err := reader.ReadString('\n')
+				position += int64(len(skipped))
+				if err != nil && !errors.Is(err, io.EOF) {
+					return "", false, err
+				}
+			}
+		}
+
+		for n := int64(0); n < perJump && taken < records && position < end; n++ {
+			line, err := reader.ReadString('\n')
+			position += int64(len(line))
+			if line != "" {
+				sample.WriteString(line)
+				if !strings.HasSuffix(line, "\n") {
+					sample.WriteByte('\n')
+				}
+				taken++
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return "", false, err
+			}
+		}
+		if position < end {
+			partial = true
+		}
+	}
+	return sample.String(), partial, nil
+}
+
+// PreviewSample runs config's pipeline on the sample of its input that
+// config.Sampling picks (all of it when unset), and returns the output with
+// the number of records sampled
+func (a *App) PreviewSample(config Config) (_ PreviewResult, err error) {
+	defer RecoverToError("PreviewSample", &err)
+
+	ctx, cancel, err := a.beginPreview()
+	if err != nil {
+		return PreviewResult{}, err
+	}
+	defer cancel()
+
+	fields := logrus.Fields{
+		"input_mode":  config.InputMode,
+		"verbs_count": len(config.Verbs),
+	}
+	if config.Sampling != nil {
+		fields["strategy"] = config.Sampling.Strategy
+		fields["records"] = config.Sampling.Records
+	}
+	LogInfo("PreviewSample started", fields)
+
+	in, mlrOptions, recordTransformers, err := a.sampledPipeline(config)
+	if err != nil {
+		LogError(err, "Failed to sample input", nil)
+		return PreviewResult{}, err
+	}
+	defer in.cleanup()
+
+	// Count the sampled records on their way into the verbs, and the records
+	// coming out of them
+	profiledTransformers, profilers := withProfiling(recordTransformers)
+	countedTransformers, inputCounter, outputCounter := withRecordLimits(profiledTransformers, PreviewLimits{}, newReaderStop())
+	var outputBuffer bytes.Buffer
+
+	err = runMillerTransformation(ctx, in.fileNames, mlrOptions, in.chain(countedTransformers), &outputBuffer, nil)
+	err = locateVerb(err, config.Verbs)
+	if err != nil {
+		if !errors.Is(err, ErrPreviewCancelled) {
+			LogError(err, "Miller transformation failed", logrus.Fields{"files": in.fileNames})
+		}
+		return PreviewResult{}, err
+	}
+
+	result := PreviewResult{
+		Output:         outputBuffer.String(),
+		Truncated:      in.truncated(),
+		RecordsRead:    inputCounter.passed.Load(),
+		RecordsWritten: outputCounter.passed.Load(),
+		BytesWritten:   int64(outputBuffer.Len()),
+		Profile:        buildProfile(config.Verbs, profilers),
+	}
+
+	LogInfo("PreviewSample completed", logrus.Fields{
+		"output_size":     len(result.Output),
+		"truncated":       result.Truncated,
+		"records_sampled": result.RecordsRead,
+	})
+	return result, nil
+}
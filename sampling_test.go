@@ -0,0 +1,277 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// sampledIDs returns the id column of CSV output from writeTestCSV input
+func sampledIDs(t *testing.T, output string) []int {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	var ids []int
+	for _, line := range lines[1:] {
+		id, err := strconv.Atoi(strings.SplitN(line, ",", 2)[0])
+		if err != nil {
+			t.Fatalf("Unexpected output line %q", line)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestPreviewSample(t *testing.T) {
+	app := NewApp()
+	path := writeTestCSV(t, 1000)
+
+	tests := []struct {
+		name          string
+		sampling      *SamplingConfig
+		wantIDs       []int // nil to check count and order only
+		wantCount     int
+		wantTruncated bool
+	}{
+		{
+			name:      "No sampling",
+			wantCount: 1000,
+		},
+		{
+			name:          "First",
+			sampling:      &SamplingConfig{Strategy: SampleFirst, Records: 3},
+			wantIDs:       []int{1, 2, 3},
+			wantTruncated: true,
+		},
+		{
+			name:          "Every",
+			sampling:      &SamplingConfig{Strategy: SampleEvery, Every: 250},
+			wantIDs:       []int{1, 251, 501, 751},
+			wantTruncated: true,
+		},
+		{
+			name:          "Every with a limit",
+			sampling:      &SamplingConfig{Strategy: SampleEvery, Every: 100, Records: 2},
+			wantIDs:       []int{1, 101},
+			wantTruncated: true,
+		},
+		{
+			name:          "Reservoir",
+			sampling:      &SamplingConfig{Strategy: SampleReservoir, Records: 20, Seed: 7},
+			wantCount:     20,
+			wantTruncated: true,
+		},
+		{
+			name:          "Jump",
+			sampling:      &SamplingConfig{Strategy: SampleJump, Records: 8, Jumps: 4},
+			wantCount:     8,
+			wantTruncated: true,
+		},
+		{
+			name:      "First beyond the end",
+			sampling:  &SamplingConfig{Strategy: SampleFirst, Records: 5000},
+			wantCount: 1000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := jobConfig(path, "cat")
+			config.Sampling = tt.sampling
+
+			result, err := app.PreviewSample(config)
+			if err != nil {
+				t.Fatalf("PreviewSample failed: %v", err)
+			}
+			ids := sampledIDs(t, result.Output)
+
+			if tt.wantIDs != nil {
+				if len(ids) != len(tt.wantIDs) {
+					t.Fatalf("Sampled ids %v, want %v", ids, tt.wantIDs)
+				}
+				for i := range ids {
+					if ids[i] != tt.wantIDs[i] {
+						t.Fatalf("Sampled ids %v, want %v", ids, tt.wantIDs)
+					}
+				}
+			} else if len(ids) != tt.wantCount {
+				t.Fatalf("Sampled %d records, want %d", len(ids), tt.wantCount)
+			}
+			for i := 1; i < len(ids); i++ {
+				if ids[i] <= ids[i-1] {
+					t.Fatalf("Sampled ids %v are out of input order", ids)
+				}
+			}
+
+			if result.Truncated != tt.wantTruncated {
+				t.Errorf("Truncated = %v, want %v", result.Truncated, tt.wantTruncated)
+			}
+			if result.RecordsRead != int64(len(ids)) {
+				t.Errorf("RecordsRead = %d, want %d", result.RecordsRead, len(ids))
+			}
+		})
+	}
+}
+
+func TestSamplesSpanTheInput(t *testing.T) {
+	app := NewApp()
+	path := writeTestCSV(t, 1000)
+
+	for _, sampling := range []*SamplingConfig{
+		{Strategy: SampleReservoir, Records: 20, Seed: 7},
+		{Strategy: SampleJump, Records: 8, Jumps: 4},
+	} {
+		t.Run(sampling.Strategy, func(t *testing.T) {
+			config := jobConfig(path, "cat")
+			config.Sampling = sampling
+
+			first, err := app.PreviewSample(config)
+			if err != nil {
+				t.Fatalf("PreviewSample failed: %v", err)
+			}
+			ids := sampledIDs(t, first.Output)
+			if ids[len(ids)-1] < 750 {
+				t.Errorf("Sampled ids %v only come from the top of the input", ids)
+			}
+
+			// The same config samples the same records
+			again, err := app.PreviewSample(config)
+			if err != nil {
+				t.Fatalf("PreviewSample failed: %v", err)
+			}
+			if again.Output != first.Output {
+				t.Errorf("Second sample %q differs from the first %q", again.Output, first.Output)
+			}
+		})
+	}
+}
+
+func TestSamplingErrors(t *testing.T) {
+	app := NewApp()
+	path := writeTestCSV(t, 10)
+
+	tests := []struct {
+		name     string
+		config   Config
+		sampling SamplingConfig
+	}{
+		{name: "Unknown strategy", config: jobConfig(path, "cat"), sampling: SamplingConfig{Strategy: "middle", Records: 5}},
+		{name: "No records", config: jobConfig(path, "cat"), sampling: SamplingConfig{Strategy: SampleReservoir}},
+		{name: "No step", config: jobConfig(path, "cat"), sampling: SamplingConfig{Strategy: SampleEvery}},
+		{
+			name:     "Jump over JSON",
+			config:   Config{InputMode: "text", InputPath: `[{"a": 1}]`, InputFormat: "--ijson", Verbs: []VerbConfig{{Value: "cat", Enabled: true}}},
+			sampling: SamplingConfig{Strategy: SampleJump, Records: 5},
+		},
+		{
+			name:     "Jump over JSON given in the options",
+			config:   Config{InputMode: "text", InputPath: `[{"a": 1}]`, Options: "--ijson", Verbs: []VerbConfig{{Value: "cat", Enabled: true}}},
+			sampling: SamplingConfig{Strategy: SampleJump, Records: 5},
+		},
+		{
+			name:     "Jump over quoted CSV",
+			config:   Config{InputMode: "text", InputPath: "a,b\n1,\"x\ny\"\n2,z\n", InputFormat: "--icsv", Verbs: []VerbConfig{{Value: "cat", Enabled: true}}},
+			sampling: SamplingConfig{Strategy: SampleJump, Records: 2, Jumps: 2},
+		},
+		{
+			name:     "Jump over two files",
+			config:   Config{InputMode: "file", InputPaths: []string{path, writeTestCSV(t, 10)}, InputFormat: "--icsv", Verbs: []VerbConfig{{Value: "cat", Enabled: true}}},
+			sampling: SamplingConfig{Strategy: SampleJump, Records: 5},
+		},
+		{
+			name:     "Jump over a compressed file",
+			config:   jobConfig(writeCompressedFile(t, t.TempDir(), "data.csv.gz", CompressionGzip, compressionTestCSV), "cat"),
+			sampling: SamplingConfig{Strategy: SampleJump, Records: 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Sampling = &tt.sampling
+			if _, err := app.PreviewSample(tt.config); err == nil {
+				t.Errorf("Expected PreviewSample to fail")
+			}
+		})
+	}
+}
+
+// TestJumpSampleReaderOptions checks that jump sampling reads the input format
+// and header from Miller's options, wherever they were given
+func TestJumpSampleReaderOptions(t *testing.T) {
+	app := NewApp()
+	path := writeTestCSV(t, 100)
+
+	tests := []struct {
+		name        string
+		inputFormat string
+		options     string
+		wantHeader  string
+	}{
+		{name: "Format in the options", options: "--icsv", wantHeader: "id,name"},
+		{name: "Format in a combined flag", options: "--csv", wantHeader: "id,name"},
+		{name: "Implicit header", inputFormat: "--icsv", options: "--implicit-csv-header", wantHeader: "1,2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := jobConfig(path, "cat")
+			config.InputFormat = tt.inputFormat
+			config.Options = tt.options
+			config.Sampling = &SamplingConfig{Strategy: SampleJump, Records: 4, Jumps: 2}
+
+			result, err := app.PreviewSample(config)
+			if err != nil {
+				t.Fatalf("PreviewSample failed: %v", err)
+			}
+			lines := strings.Split(strings.TrimSuffix(result.Output, "\n"), "\n")
+			if lines[0] != tt.wantHeader {
+				t.Errorf("Header = %q, want %q (output %q)", lines[0], tt.wantHeader, result.Output)
+			}
+			if len(lines) != 5 {
+				t.Errorf("Output %q has %d records, want 4", result.Output, len(lines)-1)
+			}
+		})
+	}
+}
+
+func TestJumpSample(t *testing.T) {
+	input := "h\n1\n2\n3\n4\n5\n6\n7\n8\n"
+
+	tests := []struct {
+		name        string
+		headerLines int
+		jumps       int
+		records     int64
+		want        string
+		wantPartial bool
+	}{
+		{name: "Whole input", headerLines: 1, jumps: 2, records: 100, want: input},
+		{name: "Spread out", headerLines: 1, jumps: 2, records: 2, want: "h\n1\n5\n", wantPartial: true},
+		{name: "No header", headerLines: 0, jumps: 3, records: 3, want: "h\n3\n6\n", wantPartial: true},
+		{name: "More jumps than records", headerLines: 1, jumps: 10, records: 1, want: "h\n1\n", wantPartial: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, partial, err := jumpSample(strings.NewReader(input), int64(len(input)), tt.headerLines, tt.jumps, tt.records)
+			if err != nil {
+				t.Fatalf("jumpSample failed: %v", err)
+			}
+			if got != tt.want || partial != tt.wantPartial {
+				t.Errorf("jumpSample = %q, %v, want %q, %v", got, partial, tt.want, tt.wantPartial)
+			}
+		})
+	}
+}
+
+func TestPreviewRecordsSampled(t *testing.T) {
+	config := jobConfig(writeTestCSV(t, 100), "cat")
+	config.Sampling = &SamplingConfig{Strategy: SampleEvery, Every: 10}
+
+	result, err := NewApp().PreviewRecords(config, 0)
+	if err != nil {
+		t.Fatalf("PreviewRecords failed: %v", err)
+	}
+	if len(result.Rows) != 10 || result.Rows[1][0].Value != int64(11) {
+		t.Errorf("Rows = %v, want every 10th record", result.Rows)
+	}
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-shellwords"
+	"github.com/sirupsen/logrus"
+)
+
+// ShellImport is the mlr command found in a shell snippet, with what the
+// snippet around it says about its input and output
+type ShellImport struct {
+	Config     Config   `json:"config"`
+	OutputPath string   `json:"outputPath,omitempty"` // where the snippet redirects mlr's output, if it does
+	Append     bool     `json:"append,omitempty"`     // the redirect appends to OutputPath
+	Warnings   []string `json:"warnings,omitempty"`   // parts of the snippet that weren't imported
+}
+
+// inputCommands are commands that pass files on to mlr unchanged but for
+// decompressing them, by the compression they undo
+var inputCommands = map[string]string{
+	"cat":     "",
+	"zcat":    CompressionGzip,
+	"gzcat":   CompressionGzip,
+	"bzcat":   CompressionBzip2,
+	"zstdcat": CompressionZstd,
+}
+
+// decompressCommands are compressors that pass files on decompressed when
+// given -d and -c, or just -c for the un- forms
+var decompressCommands = map[string]string{
+	"gzip":    CompressionGzip,
+	"gunzip":  CompressionGzip,
+	"bzip2":   CompressionBzip2,
+	"bunzip2": CompressionBzip2,
+	"zstd":    CompressionZstd,
+	"unzstd":  CompressionZstd,
+}
+
+// shellToken is a word or an operator of a shell snippet. Words are kept as
+// written, quotes and all.
+type shellToken struct {
+	text     string
+	operator bool
+}
+
+// lexShell splits a shell snippet into words and the operators between them:
+// newlines, ; & && || |, and redirections such as < > >> 2> and &>. Line
+// continuations and comments are dropped.
+func lexShell(snippet string) ([]shellToken, error) {
+	var tokens []shellToken
+	var word strings.Builder
+	inWord := false
+	endWord := func() {
+		if inWord {
+			tokens = append(tokens, shellToken{text: word.String()})
+			word.Reset()
+			inWord = false
+		}
+	}
+	operator := func(op string) {
+		endWord()
+		tokens = append(tokens, shellToken{text: op, operator: true})
+	}
+
+	runes := []rune(snippet)
+	next := func(i int) rune {
+		if i < len(runes) {
+			return runes[i]
+		}
+		return 0
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && next(i+1) == '\n':
+			i++
+		case r == '\\' && next(i+1) == '\r' && next(i+2) == '\n':
+			i += 2
+		case r == '\\':
+			if i+1 == len(runes) {
+				return nil, fmt.Errorf("snippet ends in a backslash")
+			}
+			word.WriteRune(r)
+			word.WriteRune(runes[i+1])
+			inWord = true
+			i++
+		case r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			word.WriteString(string(runes[i : end+1]))
+			inWord = true
+			i = end
+		case r == '"':
+			word.WriteRune(r)
+			inWord = true
+			for i++; ; i++ {
+				if i == len(runes) {
+					return nil, fmt.Errorf("unterminated double quote")
+				}
+				if runes[i] == '\\' && next(i+1) == '\n' {
+					i++
+					continue
+				}
+				word.WriteRune(runes[i])
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					word.WriteRune(runes[i])
+				} else if runes[i] == '"' {
+					break
+				}
+			}
+		case r == '\n':
+			operator("\n")
+		case r == ' ' || r == '\t' || r == '\r':
+			endWord()
+		case r == '#' && !inWord:
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+		case r == '|':
+			if next(i+1) == '|' {
+				operator("||")
+				i++
+			} else {
+				operator("|")
+			}
+		case r == '&':
+			switch {
+			case next(i+1) == '&':
+				operator("&&")
+				i++
+			case next(i+1) == '>' && next(i+2) == '>':
+				operator("&>>")
+				i += 2
+			case next(i+1) == '>':
+				operator("&>")
+				i++
+			default:
+				operator("&")
+			}
+		case r == ';':
+			operator(";")
+		case r == '<':
+			if next(i+1) == '<' {
+				return nil, fmt.Errorf("here-documents are not supported")
+			}
+			operator("<")
+		case r == '>':
+			// A file descriptor number written right before the > is part of it
+			op := ">"
+			if inWord && strings.Trim(word.String(), "0123456789") == "" {
+				op = word.String() + op
+				word.Reset()
+				inWord = false
+			}
+			if next(i+1) == '>' || next(i+1) == '&' {
+				op += string(runes[i+1])
+				i++
+			}
+			operator(op)
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	endWord()
+	return tokens, nil
+}
+
+// shellRedirect is a redirection of a pipeline stage, its target as written
+type shellRedirect struct {
+	op     string
+	target string
+}
+
+// shellStage is one command of a pipeline
+type shellStage struct {
+	words     []string // as written, quotes and all
+	redirects []shellRedirect
+}
+
+// String returns the stage as it was written, near enough
+func (s *shellStage) String() string {
+	parts := append([]string{}, s.words...)
+	for _, redirect := range s.redirects {
+		parts = append(parts, redirect.op+" "+redirect.target)
+	}
+	return strings.Join(parts, " ")
+}
+
+// args returns the stage's words unquoted, without the environment
+// assignments leading them. ok is false if the words don't parse.
+func (s *shellStage) args() (assignments []string, args []string, ok bool) {
+	words := s.words
+	for len(words) > 0 && isShellAssignment(words[0]) {
+		assignments = append(assignments, words[0])
+		words = words[1:]
+	}
+	args, err := shellwords.Parse(strings.Join(words, " "))
+	if err != nil || len(args) == 0 {
+		return assignments, nil, false
+	}
+	return assignments, args, true
+}
+
+// isShellAssignment reports whether a word sets an environment variable for
+// the command after it, as in LC_ALL=C sort
+func isShellAssignment(word string) bool {
+	name, _, found := strings.Cut(word, "=")
+	if !found || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// splitPipelines groups the tokens of a snippet into pipelines of stages
+func splitPipelines(tokens []shellToken) ([][]*shellStage, error) {
+	var pipelines [][]*shellStage
+	var pipeline []*shellStage
+	stage := &shellStage{}
+	afterPipe := false
+	endPipeline := func() error {
+		if afterPipe {
+			return fmt.Errorf("missing command after |")
+		}
+		if len(pipeline) > 0 {
+			pipelines = append(pipelines, pipeline)
+			pipeline = nil
+		}
+		return nil
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch {
+		case !token.operator:
+			stage.words = append(stage.words, token.text)
+			afterPipe = false
+		case token.text == "|":
+			if len(stage.words) == 0 && len(stage.redirects) == 0 {
+				return nil, fmt.Errorf("missing command before |")
+			}
+			pipeline = append(pipeline, stage)
+			stage = &shellStage{}
+			afterPipe = true
+		case token.text == "\n" && afterPipe:
+			// A pipeline may go on on the next line after a |
+		case token.text == "\n" || token.text == ";" || token.text == "&" || token.text == "&&" || token.text == "||":
+			if len(stage.words) > 0 || len(stage.redirects) > 0 {
+				pipeline = append(pipeline, stage)
+				stage = &shellStage{}
+			}
+			if err := endPipeline(); err != nil {
+				return nil, err
+			}
+		default:
+			if i+1 == len(tokens) || tokens[i+1].operator {
+				return nil, fmt.Errorf("redirection %q has no target", token.text)
+			}
+			i++
+			stage.redirects = append(stage.redirects, shellRedirect{op: token.text, target: tokens[i].text})
+			afterPipe = false
+		}
+	}
+	if len(stage.words) > 0 || len(stage.redirects) > 0 {
+		pipeline = append(pipeline, stage)
+	}
+	if err := endPipeline(); err != nil {
+		return nil, err
+	}
+	return pipelines, nil
+}
+
+// commandName returns the name of the program args run, without its
+// directory or a .exe suffix
+func commandName(args []string) string {
+	name := args[0]
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, ".exe")
+}
+
+// inputStage reports whether a stage only reads files for the next one, as
+// cat and zcat do, and if so which files and how they're compressed. Files it
+// reads from stdin come from a < redirect.
+func inputStage(stage *shellStage) (files []string, compression string, ok bool) {
+	assignments, args, ok := stage.args()
+	if !ok || len(assignments) > 0 {
+		return nil, "", false
+	}
+
+	name := commandName(args)
+	compression, isInput := inputCommands[name]
+	decompression, isDecompress := decompressCommands[name]
+	if !isInput && !isDecompress {
+		return nil, "", false
+	}
+	var letters string
+	for _, arg := range args[1:] {
+		switch {
+		case arg == "--stdout" || arg == "--to-stdout":
+			letters += "c"
+		case arg == "--decompress" || arg == "--uncompress":
+			letters += "d"
+		case len(arg) > 1 && arg[0] == '-':
+			letters += arg[1:]
+		default:
+			files = append(files, arg)
+		}
+	}
+	if isDecompress {
+		compression = decompression
+		if strings.HasPrefix(name, "un") {
+			letters += "d"
+		}
+		if !strings.Contains(letters, "c") || !strings.Contains(letters, "d") {
+			return nil, "", false
+		}
+		letters = strings.NewReplacer("c", "", "d", "", "k", "", "f", "", "q", "").Replace(letters)
+	}
+	if letters != "" {
+		return nil, "", false
+	}
+
+	for _, redirect := range stage.redirects {
+		if redirect.op != "<" {
+			return nil, "", false
+		}
+		target, ok := redirectTarget(redirect)
+		if !ok {
+			return nil, "", false
+		}
+		files = append(files, target)
+	}
+	return files, compression, len(files) > 0
+}
+
+// redirectTarget returns the file a redirection names, unquoted
+func redirectTarget(redirect shellRedirect) (string, bool) {
+	target, err := shellwords.Parse(redirect.target)
+	if err != nil || len(target) != 1 {
+		return "", false
+	}
+	return target[0], true
+}
+
+// ParseShellSnippet imports the mlr command from a shell snippet, such as a
+// runbook line like "zcat in.gz | mlr --icsv --ojson cut -f a,b > out.json".
+// Files fed to mlr by cat, zcat and the like, or by a < redirect, become its
+// input files and compression, and a > or >> redirect of its output becomes
+// the suggested output path. The rest of the snippet is reported in warnings.
+func (a *App) ParseShellSnippet(snippet string) (_ ShellImport, err error) {
+	defer RecoverToError("ParseShellSnippet", &err)
+
+	LogInfo("Parsing shell snippet", logrus.Fields{"snippet": snippet})
+
+	var result ShellImport
+	tokens, err := lexShell(snippet)
+	if err != nil {
+		return result, fmt.Errorf("error parsing shell snippet: %v", err)
+	}
+	pipelines, err := splitPipelines(tokens)
+	if err != nil {
+		return result, fmt.Errorf("error parsing shell snippet: %v", err)
+	}
+
+	// The first stage that runs mlr is the one imported
+	var pipeline []*shellStage
+	mlrIndex := -1
+	var mlrArgs []string
+	for _, candidate := range pipelines {
+		if mlrIndex < 0 {
+			for i, stage := range candidate {
+				assignments, args, ok := stage.args()
+				if ok && commandName(args) == "mlr" {
+					pipeline, mlrIndex, mlrArgs = candidate, i, args
+					if len(assignments) > 0 {
+						result.Warnings = append(result.Warnings, fmt.Sprintf("Skipped the environment settings %s of mlr", strings.Join(assignments, " ")))
+					}
+					break
+				}
+			}
+			if mlrIndex >= 0 {
+				continue
+			}
+		}
+		for _, stage := range candidate {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Skipped %q, which is not part of the mlr pipeline", stage.String()))
+		}
+	}
+	if mlrIndex < 0 {
+		return result, fmt.Errorf("no mlr command found in the snippet")
+	}
+
+	mlrStage := pipeline[mlrIndex]
+	config, err := a.ParseCommand(joinVerbTokens(append([]string{"mlr"}, mlrArgs[1:]...)))
+	if err != nil {
+		return result, err
+	}
+
+	// Input: a < redirect, or the stage piping into mlr if it only reads files
+	var inputFiles []string
+	var compression string
+	var outputRedirects []shellRedirect
+	for _, redirect := range mlrStage.redirects {
+		switch redirect.op {
+		case "<":
+			target, ok := redirectTarget(redirect)
+			if !ok {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Skipped the input redirection %q", redirect.target))
+				continue
+			}
+			inputFiles = []string{target}
+		case ">", "1>", ">>", "1>>", "&>", "&>>":
+			outputRedirects = append(outputRedirects, redirect)
+		default:
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Skipped the redirection %q of mlr", redirect.op+" "+redirect.target))
+		}
+	}
+	upstream := pipeline[:mlrIndex]
+	if len(upstream) > 0 && inputFiles == nil {
+		if files, stageCompression, ok := inputStage(upstream[len(upstream)-1]); ok {
+			inputFiles, compression = files, stageCompression
+			upstream = upstream[:len(upstream)-1]
+		}
+	}
+	for _, stage := range upstream {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("Skipped %q, which feeds mlr its input", stage.String()))
+	}
+	switch {
+	case config.InputMode == "file" && inputFiles != nil:
+		// mlr reads the files it names and ignores its standard input
+		result.Warnings = append(result.Warnings, fmt.Sprintf("Skipped the input from %s, since mlr names its own input files", strings.Join(inputFiles, " ")))
+	case inputFiles != nil:
+		config.InputMode = "file"
+		config.InputPath = inputFiles[0]
+		config.InputPaths = inputFiles
+		if config.Compression == "" {
+			config.Compression = compression
+		}
+	}
+
+	// Output: the last redirect of mlr's own output wins, as in the shell
+	if len(outputRedirects) > 0 {
+		redirect := outputRedirects[len(outputRedirects)-1]
+		if target, ok := redirectTarget(redirect); ok {
+			result.OutputPath = target
+			result.Append = strings.HasSuffix(redirect.op, ">>")
+		} else {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Skipped the output redirection %q", redirect.target))
+		}
+	}
+	for _, stage := range pipeline[mlrIndex+1:] {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("Skipped %q, which mlr's output is piped into", stage.String()))
+	}
+
+	result.Config = config
+	LogInfo("Shell snippet parsed", logrus.Fields{
+		"input_paths":   config.inputFiles(),
+		"output_path":   result.OutputPath,
+		"warnings":      len(result.Warnings),
+		"pipeline_size": len(pipeline),
+	})
+	return result, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseShellSnippet(t *testing.T) {
+	app := NewApp()
+
+	tests := []struct {
+		name            string
+		snippet         string
+		wantVerbs       []string
+		wantFiles       []string
+		wantCompression string
+		wantOutput      string
+		wantAppend      bool
+		wantWarnings    []string // a piece of each warning, in order
+	}{
+		{
+			name:            "Decompressed input and redirected output",
+			snippet:         "zcat in.gz | mlr --icsv --ojson cut -f a,b > out.json",
+			wantVerbs:       []string{"cut -f a,b"},
+			wantFiles:       []string{"in.gz"},
+			wantCompression: CompressionGzip,
+			wantOutput:      "out.json",
+		},
+		{
+			name: "Continuations and comments",
+			snippet: "# Summarise the orders\n" +
+				"cat '/data/my orders.csv' \\\n" +
+				"  | mlr --icsv --opprint \\\n" +
+				"      sort -nr total \\\n" +
+				"      then head -n 5 # the top five\n",
+			wantVerbs: []string{"sort -nr total", "head -n 5"},
+			wantFiles: []string{"/data/my orders.csv"},
+		},
+		{
+			name:       "Input and appending output redirects",
+			snippet:    "mlr --icsv --ojsonl cat < in.csv >> all.jsonl",
+			wantVerbs:  []string{"cat"},
+			wantFiles:  []string{"in.csv"},
+			wantOutput: "all.jsonl",
+			wantAppend: true,
+		},
+		{
+			name:            "Compressor writing to stdout",
+			snippet:         "gzip -dc a.csv.gz b.csv.gz | mlr --icsv --ojson cat",
+			wantVerbs:       []string{"cat"},
+			wantFiles:       []string{"a.csv.gz", "b.csv.gz"},
+			wantCompression: CompressionGzip,
+		},
+		{
+			name:            "Decompressor reading a redirect",
+			snippet:         "bzcat < in.csv.bz2 | mlr --icsv --ojson cat",
+			wantVerbs:       []string{"cat"},
+			wantFiles:       []string{"in.csv.bz2"},
+			wantCompression: CompressionBzip2,
+		},
+		{
+			name:            "Pipeline going on after a newline",
+			snippet:         "zstdcat in.zst |\n  mlr --icsv --ojson cat",
+			wantVerbs:       []string{"cat"},
+			wantFiles:       []string{"in.zst"},
+			wantCompression: CompressionZstd,
+		},
+		{
+			name:         "Filtering stages around mlr",
+			snippet:      "grep -v '^#' in.csv | mlr --icsv --ojson put '$x = 1' | jq . > out.json",
+			wantVerbs:    []string{"put '$x = 1'"},
+			wantWarnings: []string{`"grep -v '^#' in.csv", which feeds mlr`, `"jq . > out.json", which mlr's output is piped into`},
+		},
+		{
+			name:         "mlr naming its own files",
+			snippet:      "cat a.csv | mlr --icsv --ojson cat b.csv",
+			wantVerbs:    []string{"cat"},
+			wantFiles:    []string{"b.csv"},
+			wantWarnings: []string{"input from a.csv"},
+		},
+		{
+			name:         "Other commands",
+			snippet:      "set -e\ncd /data && mlr --icsv --ojson head -n 2 in.csv 2> errors.log; echo done",
+			wantVerbs:    []string{"head -n 2"},
+			wantFiles:    []string{"in.csv"},
+			wantWarnings: []string{`"set -e"`, `"cd /data"`, `"echo done"`, `redirection "2> errors.log"`},
+		},
+		{
+			name:         "Environment settings and a path to mlr",
+			snippet:      "LC_ALL=C /usr/local/bin/mlr --c2p cat",
+			wantVerbs:    []string{"cat"},
+			wantWarnings: []string{"LC_ALL=C"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := app.ParseShellSnippet(tt.snippet)
+			if err != nil {
+				t.Fatalf("ParseShellSnippet failed: %v", err)
+			}
+
+			var verbs []string
+			for _, verb := range result.Config.Verbs {
+				verbs = append(verbs, verb.Value)
+			}
+			if !reflect.DeepEqual(verbs, tt.wantVerbs) {
+				t.Errorf("Verbs = %q, want %q", verbs, tt.wantVerbs)
+			}
+			if !reflect.DeepEqual(result.Config.inputFiles(), tt.wantFiles) {
+				t.Errorf("Input files = %q, want %q", result.Config.inputFiles(), tt.wantFiles)
+			}
+			if tt.wantFiles != nil && result.Config.InputMode != "file" {
+				t.Errorf("InputMode = %q, want file", result.Config.InputMode)
+			}
+			if result.Config.Compression != tt.wantCompression {
+				t.Errorf("Compression = %q, want %q", result.Config.Compression, tt.wantCompression)
+			}
+			if result.OutputPath != tt.wantOutput || result.Append != tt.wantAppend {
+				t.Errorf("Output = %q, append %v, want %q, append %v", result.OutputPath, result.Append, tt.wantOutput, tt.wantAppend)
+			}
+
+			if len(result.Warnings) != len(tt.wantWarnings) {
+				t.Fatalf("Warnings = %q, want %d", result.Warnings, len(tt.wantWarnings))
+			}
+			for i, want := range tt.wantWarnings {
+				if !strings.Contains(result.Warnings[i], want) {
+					t.Errorf("Warning %q does not mention %s", result.Warnings[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseShellSnippetErrors(t *testing.T) {
+	app := NewApp()
+
+	for _, snippet := range []string{
+		"zcat in.gz | head -n 5",
+		"mlr --icsv cat 'in.csv",
+		"mlr --icsv cat |",
+		"| mlr --icsv cat",
+		"mlr --icsv cat >",
+		"mlr --icsv cat <<EOF\na=1\nEOF",
+		"cat in.csv | mlr --icsv frobnicate",
+		"cat in.csv | mlr --icsv head -x",
+		"mlr --icsv sort -z f < in.csv",
+		"mlr --ifs < in.csv",
+	} {
+		if _, err := app.ParseShellSnippet(snippet); err == nil {
+			t.Errorf("Expected ParseShellSnippet(%q) to fail", snippet)
+		}
+	}
+}
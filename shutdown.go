@@ -0,0 +1,74 @@
+package main
+
+import (
+	"container/list"
+
+	"github.com/johnkerl/miller/v6/pkg/types"
+)
+
+// pipelineStages follows the reader, transformer chain and writer goroutines
+// of one Miller run. Miller only winds them down on an end of stream, which a
+// failed reader never sends and a failed writer never consumes, so a run that
+// stops early has to see each stage through to its end itself.
+type pipelineStages struct {
+	readerChannel     chan *list.List // reader -> chain
+	writerChannel     chan *list.List // chain -> writer
+	inputErrorChannel chan error
+	readerReturned    chan struct{} // closed when the reader goroutine returns
+	writerReturned    chan struct{} // closed when the writer goroutine returns
+	writer            *locatingWriter
+	endOfStream       *list.List
+
+	// readerFailed is set once the reader's error has been taken off
+	// inputErrorChannel
+	readerFailed bool
+}
+
+// wait returns once the reader, every stage of the chain and the writer have
+// returned. For a run that stopped early, the reader must already be told to
+// stop, and the writer's output thrown away.
+func (s *pipelineStages) wait() {
+	readerReturned, writerReturned := s.readerReturned, s.writerReturned
+	var sendEnd chan<- *list.List // the chain, while it needs an end of stream the reader didn't send
+	var discard <-chan *list.List // the chain's output, once the writer has stopped reading it
+	chainDone := false
+
+	for readerReturned != nil || writerReturned != nil || !chainDone {
+		select {
+		case <-readerReturned:
+			readerReturned = nil
+			// A reader that failed returns without sending an end of stream
+			if s.readerFailed || len(s.inputErrorChannel) > 0 {
+				sendEnd = s.readerChannel
+			}
+
+		case sendEnd <- s.endOfStream:
+			sendEnd = nil
+
+		case <-writerReturned:
+			writerReturned = nil
+			if s.writer.reachedEnd {
+				chainDone = true
+			} else {
+				discard = s.writerChannel
+			}
+
+		case batch := <-discard:
+			if containsEndOfStream(batch) {
+				chainDone = true
+				discard = nil
+			}
+		}
+	}
+}
+
+// containsEndOfStream reports whether a batch of records carries the end of
+// stream, which the last stage of the chain sends just before it returns
+func containsEndOfStream(batch *list.List) bool {
+	for e := batch.Front(); e != nil; e = e.Next() {
+		if e.Value.(*types.RecordAndContext).EndOfStream {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/johnkerl/miller/v6/pkg/climain"
+	"github.com/johnkerl/miller/v6/pkg/input"
+	"github.com/johnkerl/miller/v6/pkg/transformers"
+	"github.com/johnkerl/miller/v6/pkg/types"
+)
+
+// openFileCount returns how many files the process has open, or -1 where that
+// can't be told
+func openFileCount() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(entries)
+}
+
+// leakTolerance is how many goroutines or open files more than before the
+// runs may be left after them, for what the runtime and the test framework
+// start meanwhile. A leak in each of the runs leaves more than that.
+const leakTolerance = 5
+
+// waitForGoroutines waits for the goroutine count to drop back to within
+// leakTolerance of baseline, and fails the test if it doesn't
+func waitForGoroutines(t *testing.T, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > baseline+leakTolerance {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			n := runtime.Stack(buf, true)
+			t.Fatalf("Goroutines = %d, want at most %d\n%s", runtime.NumGoroutine(), baseline+leakTolerance, buf[:n])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFailingRunsDoNotLeak(t *testing.T) {
+	app := NewApp()
+	dir := t.TempDir()
+
+	// Enough records for several batches, so every stage has work in flight
+	// when the run fails
+	mismatch := writeTestCSV(t, 2000)
+	f, _ := os.OpenFile(mismatch, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("2001,name2001,extra\n")
+	f.Close()
+
+	var schemaChange strings.Builder
+	schemaChange.WriteString("a=1,b=2\n")
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&schemaChange, "c=%d\n", i)
+	}
+	schemaChangePath := filepath.Join(dir, "schema.dkvp")
+	os.WriteFile(schemaChangePath, []byte(schemaChange.String()), 0644)
+
+	good := writeTestCSV(t, 2000)
+	missing := filepath.Join(dir, "missing.csv")
+	catVerbs := []VerbConfig{{Value: "cat", Enabled: true}, {Value: "put '$x = NR'", Enabled: true}}
+
+	mlrOptions, _, err := climain.ParseCommandLine([]string{"mlr", "--icsv", "--ojson", "cat"})
+	if err != nil {
+		t.Fatalf("ParseCommandLine failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{
+			name: "Reader error",
+			run: func() error {
+				_, err := app.PreviewFile(mismatch, catVerbs, "", "--icsv", false, false, ",", "--ojson")
+				return err
+			},
+		},
+		{
+			name: "Missing second file",
+			run: func() error {
+				chain := []transformers.IRecordTransformer{&panicOnRecord{nr: -1}}
+				return runMillerTransformation(context.Background(), []string{good, missing}, mlrOptions, chain, io.Discard, nil)
+			},
+		},
+		{
+			name: "Writer error",
+			run: func() error {
+				_, err := app.PreviewFile(schemaChangePath, catVerbs, "", "--idkvp", false, false, ",", "--ocsv")
+				return err
+			},
+		},
+		{
+			name: "Writer error on text input",
+			run: func() error {
+				_, err := app.Preview(schemaChange.String(), catVerbs, "--idkvp", "", false, false, ",", "--ocsv")
+				return err
+			},
+		},
+		{
+			name: "Verb panic",
+			run: func() error {
+				chain := []transformers.IRecordTransformer{&panicOnRecord{nr: 2}}
+				return runMillerTransformation(context.Background(), []string{good}, mlrOptions, chain, io.Discard, nil)
+			},
+		},
+		{
+			name: "Run to file",
+			run: func() error {
+				config := Config{
+					InputMode:    "file",
+					InputPaths:   []string{good, mismatch},
+					InputFormat:  "--icsv",
+					OutputFormat: "--ojson",
+					Verbs:        catVerbs,
+				}
+				_, err := app.RunToFile(config, filepath.Join(dir, "out.json"))
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The first run may start goroutines that live on, such as the
+			// runtime's own
+			if err := tt.run(); err == nil {
+				t.Fatalf("Expected the run to fail")
+			}
+			goroutines := runtime.NumGoroutine()
+			files := openFileCount()
+
+			for i := 0; i < 20; i++ {
+				if err := tt.run(); err == nil {
+					t.Fatalf("Expected run %d to fail", i)
+				}
+			}
+
+			waitForGoroutines(t, goroutines)
+			if files >= 0 {
+				if got := openFileCount(); got > files+leakTolerance {
+					t.Errorf("Open files = %d after 20 failing runs, want at most %d", got, files+leakTolerance)
+				}
+			}
+		})
+	}
+}
+
+// stageLinger is how long the stages in TestRunReturnsWithStagesFinished take
+// over their last step, so that a run not waiting for them returns first
+const stageLinger = 50 * time.Millisecond
+
+// signallingReader is a record reader that records when it returns
+type signallingReader struct {
+	inner    input.IRecordReader
+	returned *atomic.Bool
+}
+
+func (r *signallingReader) Read(
+	fileNames []string,
+	context types.Context,
+	readerChannel chan<- *list.List,
+	errorChannel chan error,
+	downstreamDoneChannel <-chan bool,
+) {
+	r.inner.Read(fileNames, context, readerChannel, errorChannel, downstreamDoneChannel)
+	time.Sleep(stageLinger)
+	r.returned.Store(true)
+}
+
+// endOfStreamSignal is a verb that records when the end of stream reaches it
+type endOfStreamSignal struct {
+	seen *atomic.Bool
+}
+
+func (e *endOfStreamSignal) Transform(
+	inrecAndContext *types.RecordAndContext,
+	outputRecordsAndContexts *list.List,
+	inputDownstreamDoneChannel <-chan bool,
+	outputDownstreamDoneChannel chan<- bool,
+) {
+	transformers.HandleDefaultDownstreamDone(inputDownstreamDoneChannel, outputDownstreamDoneChannel)
+	if inrecAndContext.EndOfStream {
+		time.Sleep(stageLinger)
+		e.seen.Store(true)
+	}
+	outputRecordsAndContexts.PushBack(inrecAndContext)
+}
+
+func TestRunReturnsWithStagesFinished(t *testing.T) {
+	path := writeTestCSV(t, 2000)
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("2001,name2001,extra\n")
+	f.Close()
+	mlrOptions, recordTransformers, err := climain.ParseCommandLine([]string{"mlr", "--icsv", "--ojson", "cat"})
+	if err != nil {
+		t.Fatalf("ParseCommandLine failed: %v", err)
+	}
+	recordReader, err := input.Create(&mlrOptions.ReaderOptions, mlrOptions.ReaderOptions.RecordsPerBatch)
+	if err != nil {
+		t.Fatalf("Failed to create record reader: %v", err)
+	}
+
+	var readerReturned, chainEnded atomic.Bool
+	reader := &signallingReader{inner: recordReader, returned: &readerReturned}
+	chain := append(recordTransformers, &endOfStreamSignal{seen: &chainEnded})
+	if err := runPipeline(context.Background(), reader, []string{path}, mlrOptions, chain, io.Discard, nil); err == nil {
+		t.Fatalf("Expected the run to fail")
+	}
+	// Nothing of the pipeline is still running once the run has returned: the
+	// reader is done and the end of stream has come through the chain
+	if !readerReturned.Load() {
+		t.Errorf("The reader was still running when the run returned")
+	}
+	if !chainEnded.Load() {
+		t.Errorf("The end of stream had not come through the chain when the run returned")
+	}
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/johnkerl/miller/v6/pkg/cli"
+	"github.com/johnkerl/miller/v6/pkg/transformers"
+	"github.com/johnkerl/miller/v6/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultSnapshotBudget is how much memory the record snapshots of the
+// current input may take
+const defaultSnapshotBudget = 128 << 20
+
+// snapshotFieldOverhead is roughly what a record field costs in memory on top
+// of the text of its key and value
+const snapshotFieldOverhead = 64
+
+// defaultRecordsPerBatch is the batch size Miller's readers use unless told
+// otherwise
+const defaultRecordsPerBatch = 500
+
+// stageSnapshot is a copy of the whole record stream coming out of one verb
+// of a preview, from which the verbs after it can be run again without
+// reading the input or running the verbs before it
+type stageSnapshot struct {
+	key        string
+	records    []*types.RecordAndContext
+	endContext types.Context
+	size       int64
+}
+
+// snapshotStore keeps record snapshots for the input last previewed, so that
+// editing a verb near the end of a long chain only re-runs the verbs from
+// there on. It holds at most budget bytes, evicting the least recently used
+// snapshot first.
+type snapshotStore struct {
+	mu       sync.Mutex
+	budget   int64
+	size     int64
+	inputKey string
+	order    *list.List // of *stageSnapshot, most recently used first
+	entries  map[string]*list.Element
+}
+
+func newSnapshotStore(budget int64) *snapshotStore {
+	return &snapshotStore{budget: budget, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// get returns the snapshot with the given key, if it was taken for the input
+// with inputKey
+func (s *snapshotStore) get(inputKey, key string) (*stageSnapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok || inputKey != s.inputKey {
+		return nil, false
+	}
+	s.order.MoveToFront(element)
+	return element.Value.(*stageSnapshot), true
+}
+
+// put stores snapshots taken for the input with inputKey. Snapshots of any
+// other input are dropped first.
+func (s *snapshotStore) put(inputKey string, snapshots []*stageSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if inputKey != s.inputKey {
+		s.order.Init()
+		s.entries = map[string]*list.Element{}
+		s.size = 0
+		s.inputKey = inputKey
+	}
+	for _, snapshot := range snapshots {
+		if element, ok := s.entries[snapshot.key]; ok {
+			s.remove(element)
+		}
+		if snapshot.size > s.budget {
+			continue
+		}
+		s.entries[snapshot.key] = s.order.PushFront(snapshot)
+		s.size += snapshot.size
+	}
+	for s.size > s.budget {
+		s.remove(s.order.Back())
+	}
+}
+
+func (s *snapshotStore) getBudget() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.budget
+}
+
+func (s *snapshotStore) remove(element *list.Element) {
+	snapshot := s.order.Remove(element).(*stageSnapshot)
+	delete(s.entries, snapshot.key)
+	s.size -= snapshot.size
+}
+
+// snapshotKeys returns one key per enabled verb in args, identifying the
+// record stream after that verb: the input with inputKey, the main flags that
+// bear on reading it and the words of the verbs up to and including it. Flags
+// that only shape the output, like the output format, are left out, so that
+// changing them keeps the snapshots.
+func snapshotKeys(inputKey string, args pipelineArgs) []string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "input %s\x00", inputKey)
+	for _, arg := range readerFlags(args.mainFlags) {
+		fmt.Fprintf(hash, "arg %s\x00", arg)
+	}
+
+	keys := make([]string, len(args.verbs))
+	for i, verb := range args.verbs {
+		io.WriteString(hash, "then\x00")
+		for _, token := range verb.tokens {
+			fmt.Fprintf(hash, "arg %s\x00", token)
+		}
+		keys[i] = hex.EncodeToString(hash.Sum(nil))
+	}
+	return keys
+}
+
+// readerFlags returns the main flags, with their arguments, that change
+// anything besides Miller's writer options. Each is told by parsing it with
+// Miller's own flag table, which can't exit on main flags checkArgs has
+// passed. Should Miller not take a flag, it and those after it are kept.
+func readerFlags(mainFlags []string) []string {
+	defaults := cli.DefaultOptions()
+	var flags []string
+	for argi := 0; argi < len(mainFlags); {
+		start := argi
+		options := cli.DefaultOptions()
+		if !cli.FLAG_TABLE.Parse(mainFlags, len(mainFlags), &argi, options) || argi <= start {
+			return append(flags, mainFlags[start:]...)
+		}
+		options.WriterOptions = defaults.WriterOptions
+		if !reflect.DeepEqual(options, defaults) {
+			flags = append(flags, mainFlags[start:argi]...)
+		}
+	}
+	return flags
+}
+
+// snapshotBudget is the memory the snapshots of one run may take between them
+type snapshotBudget struct {
+	max  int64
+	used atomic.Int64
+}
+
+// reserve takes n bytes out of the budget, unless that would overrun it
+func (b *snapshotBudget) reserve(n int64) bool {
+	if b.used.Add(n) > b.max {
+		b.used.Add(-n)
+		return false
+	}
+	return true
+}
+
+// snapshotTap wraps a verb and copies the records it emits into a snapshot.
+// It gives up on the snapshot if the run's budget runs out, or if a verb
+// further down asks for no more input, since the verb's output is then cut
+// short.
+type snapshotTap struct {
+	inner          transformers.IRecordTransformer
+	budget         *snapshotBudget
+	snapshot       *stageSnapshot
+	downstreamDone chan bool // what the inner verb sees of the signal from further down
+	complete       bool
+	abandoned      bool
+
+	// printed is set if the verb emitted text, which Miller passes around the
+	// verbs after it, so that it never reaches their snapshots
+	printed bool
+}
+
+func (t *snapshotTap) Transform(
+	inrecAndContext *types.RecordAndContext,
+	outputRecordsAndContexts *list.List,
+	inputDownstreamDoneChannel <-chan bool,
+	outputDownstreamDoneChannel chan<- bool,
+) {
+	select {
+	case b := <-inputDownstreamDoneChannel:
+		t.abandon()
+		select {
+		case t.downstreamDone <- b:
+		default:
+		}
+	default:
+	}
+
+	before := outputRecordsAndContexts.Back()
+	t.inner.Transform(inrecAndContext, outputRecordsAndContexts, t.downstreamDone, outputDownstreamDoneChannel)
+
+	if inrecAndContext.EndOfStream {
+		t.snapshot.endContext = inrecAndContext.Context
+		t.complete = true
+	}
+	e := outputRecordsAndContexts.Front()
+	if before != nil {
+		e = before.Next()
+	}
+	for ; e != nil && !t.abandoned; e = e.Next() {
+		recordAndContext := e.Value.(*types.RecordAndContext)
+		if recordAndContext.OutputString != "" {
+			t.printed = true
+		}
+		if recordAndContext.Record == nil {
+			continue
+		}
+		// Downstream verbs modify records in place, so keep a copy
+		recordAndContext = copyRecordAndContext(recordAndContext)
+		size := recordSize(recordAndContext)
+		if !t.budget.reserve(size) {
+			t.abandon()
+			break
+		}
+		t.snapshot.records = append(t.snapshot.records, recordAndContext)
+		t.snapshot.size += size
+	}
+}
+
+// abandon drops the records copied so far and returns their memory to the
+// budget
+func (t *snapshotTap) abandon() {
+	if t.abandoned {
+		return
+	}
+	t.abandoned = true
+	t.budget.used.Add(-t.snapshot.size)
+	t.snapshot.records = nil
+	t.snapshot.size = 0
+}
+
+// taken returns the snapshot if the tap saw the whole stream go through
+func (t *snapshotTap) taken() (*stageSnapshot, bool) {
+	if !t.complete || t.abandoned {
+		return nil, false
+	}
+	return t.snapshot, true
+}
+
+// copyRecordAndContext copies a record with its context
+func copyRecordAndContext(recordAndContext *types.RecordAndContext) *types.RecordAndContext {
+	return types.NewRecordAndContext(recordAndContext.Record.Copy(), &recordAndContext.Context)
+}
+
+// recordSize estimates the memory a copied record takes
+func recordSize(recordAndContext *types.RecordAndContext) int64 {
+	var size int64
+	for pe := recordAndContext.Record.Head; pe != nil; pe = pe.Next {
+		size += int64(len(pe.Key)+len(pe.Value.String())) + snapshotFieldOverhead
+	}
+	return size
+}
+
+// withSnapshotTaps wraps every transformer in the chain in a snapshotTap, the
+// one around transformer i taking the snapshot with keys[i]
+func withSnapshotTaps(recordTransformers []transformers.IRecordTransformer, keys []string, budget *snapshotBudget) ([]transformers.IRecordTransformer, []*snapshotTap) {
+	tapped := make([]transformers.IRecordTransformer, len(recordTransformers))
+	taps := make([]*snapshotTap, len(recordTransformers))
+	for i, recordTransformer := range recordTransformers {
+		taps[i] = &snapshotTap{
+			inner:          recordTransformer,
+			budget:         budget,
+			snapshot:       &stageSnapshot{key: keys[i]},
+			downstreamDone: make(chan bool, 1),
+		}
+		tapped[i] = taps[i]
+	}
+	return tapped, taps
+}
+
+// snapshotReader is a record reader that replays a snapshot instead of
+// reading files, in batches the way Miller's readers send them
+type snapshotReader struct {
+	snapshot        *stageSnapshot
+	recordsPerBatch int
+}
+
+func (r *snapshotReader) Read(
+	_ []string,
+	_ types.Context,
+	readerChannel chan<- *list.List,
+	_ chan error,
+	downstreamDoneChannel <-chan bool,
+) {
+	batch := list.New()
+	for _, recordAndContext := range r.snapshot.records {
+		// The verbs replayed modify records in place, and the snapshot may be
+		// replayed again
+		batch.PushBack(copyRecordAndContext(recordAndContext))
+		if batch.Len() < r.recordsPerBatch {
+			continue
+		}
+		readerChannel <- batch
+		batch = list.New()
+
+		select {
+		case <-downstreamDoneChannel:
+			readerChannel <- types.NewEndOfStreamMarkerList(&r.snapshot.endContext)
+			return
+		default:
+		}
+	}
+	if batch.Len() > 0 {
+		readerChannel <- batch
+	}
+	readerChannel <- types.NewEndOfStreamMarkerList(&r.snapshot.endContext)
+}
+
+// withoutLeadingVerbs returns verbs with the first n enabled ones disabled,
+// which lines the rest up with a chain that starts after them
+func withoutLeadingVerbs(verbs []VerbConfig, n int) []VerbConfig {
+	remaining := make([]VerbConfig, len(verbs))
+	copy(remaining, verbs)
+	for i := range remaining {
+		if n == 0 {
+			break
+		}
+		if remaining[i].Enabled {
+			remaining[i].Enabled = false
+			n--
+		}
+	}
+	return remaining
+}
+
+// runPreviewPipeline runs a preview's transformer chain into outputStream,
+// and returns the profile of the verbs that ran. When the stream out of some
+// of the leading verbs was snapshotted for this input (inputKey, empty if
+// unknown), only the verbs after them run, over the snapshot. The stream out
+// of every verb that runs is snapshotted in turn for the next preview.
+func (a *App) runPreviewPipeline(
+	ctx context.Context,
+	method string,
+	inputKey string,
+	args pipelineArgs,
+	fileNames []string,
+	mlrOptions *cli.TOptions,
+	recordTransformers []transformers.IRecordTransformer,
+	verbs []VerbConfig,
+	outputStream io.Writer,
+) ([]VerbProfile, error) {
+	var keys []string
+	if inputKey != "" && len(args.verbs) == len(recordTransformers) {
+		keys = snapshotKeys(inputKey, args)
+	}
+
+	// Start after the most leading verbs whose output is at hand, leaving at
+	// least one to run
+	start := 0
+	var snapshot *stageSnapshot
+	for k := len(keys) - 1; k > 0; k-- {
+		if found, ok := a.snapshots.get(inputKey, keys[k-1]); ok {
+			start, snapshot = k, found
+			break
+		}
+	}
+	remainingVerbs := withoutLeadingVerbs(verbs, start)
+
+	chain, profilers := withProfiling(recordTransformers[start:])
+	var taps []*snapshotTap
+	if keys != nil {
+		chain, taps = withSnapshotTaps(chain, keys[start:], &snapshotBudget{max: a.snapshots.getBudget()})
+	}
+
+	var err error
+	if snapshot != nil {
+		LogInfo("Replaying pipeline from snapshot", logrus.Fields{
+			"method":        method,
+			"skipped_verbs": start,
+			"records":       len(snapshot.records),
+		})
+		recordsPerBatch := int(mlrOptions.ReaderOptions.RecordsPerBatch)
+		if recordsPerBatch <= 0 {
+			recordsPerBatch = defaultRecordsPerBatch
+		}
+		replay := &snapshotReader{snapshot: snapshot, recordsPerBatch: recordsPerBatch}
+		err = runPipeline(ctx, replay, nil, mlrOptions, chain, outputStream, nil)
+	} else {
+		err = runMillerTransformation(ctx, fileNames, mlrOptions, chain, outputStream, nil)
+	}
+	if err != nil {
+		return nil, locateVerb(err, remainingVerbs)
+	}
+
+	profile := buildProfile(remainingVerbs, profilers)
+	if keys != nil {
+		var snapshots []*stageSnapshot
+		for _, tap := range taps {
+			// Text a verb printed went around the snapshots of the verbs after it
+			if tap.printed {
+				break
+			}
+			if taken, ok := tap.taken(); ok {
+				snapshots = append(snapshots, taken)
+			}
+		}
+		a.snapshots.put(inputKey, snapshots)
+	}
+	return profile, nil
+}
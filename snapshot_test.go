@@ -0,0 +1,206 @@
+package main
+
+import (
+	"container/list"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/johnkerl/miller/v6/pkg/mlrval"
+	"github.com/johnkerl/miller/v6/pkg/transformers"
+	"github.com/johnkerl/miller/v6/pkg/types"
+)
+
+func TestSnapshotReplay(t *testing.T) {
+	logged := captureLog(t)
+	app := NewApp()
+	path := writeTestCSV(t, 1200)
+	verbs := func(last ...string) []VerbConfig {
+		chain := []VerbConfig{
+			{Value: "put '$nr = NR'", Enabled: true},
+			{Value: "sort -nr id", Enabled: true},
+		}
+		for _, verb := range last {
+			chain = append(chain, VerbConfig{Value: verb, Enabled: true})
+		}
+		return chain
+	}
+
+	if _, err := app.PreviewFile(path, verbs("head -n 3"), "", "--icsv", false, false, ",", "--ocsv"); err != nil {
+		t.Fatalf("PreviewFile failed: %v", err)
+	}
+	if strings.Contains(logged.String(), "Replaying pipeline from snapshot") {
+		t.Fatalf("Expected the first preview to read the input")
+	}
+
+	tests := []struct {
+		name        string
+		verbs       []VerbConfig
+		wantSkipped string
+	}{
+		{name: "Last verb edited", verbs: verbs("head -n 5"), wantSkipped: `"skipped_verbs":2`},
+		{name: "Verb appended", verbs: verbs("head -n 5", "cut -f id,nr"), wantSkipped: `"skipped_verbs":3`},
+		{name: "Middle verb edited", verbs: []VerbConfig{verbs()[0], {Value: "sort -f name", Enabled: true}, {Value: "head -n 5", Enabled: true}}, wantSkipped: `"skipped_verbs":1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logged.Reset()
+			got, err := app.PreviewFile(path, tt.verbs, "", "--icsv", false, false, ",", "--ocsv")
+			if err != nil {
+				t.Fatalf("PreviewFile failed: %v", err)
+			}
+			if !strings.Contains(logged.String(), tt.wantSkipped) {
+				t.Errorf("Expected a replay with %s, log: %s", tt.wantSkipped, logged.String())
+			}
+
+			want, err := NewApp().PreviewFile(path, tt.verbs, "", "--icsv", false, false, ",", "--ocsv")
+			if err != nil {
+				t.Fatalf("PreviewFile failed: %v", err)
+			}
+			if got != want {
+				t.Errorf("Replayed output = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSnapshotKeysIgnoreOutputFlags(t *testing.T) {
+	keys := func(mainFlags ...string) []string {
+		return snapshotKeys("input", pipelineArgs{mainFlags: mainFlags, verbs: []verbArgs{{tokens: []string{"cat"}}}})
+	}
+	csv := keys("--icsv", "--ocsv")
+
+	if got := keys("--icsv", "--ojson"); !reflect.DeepEqual(got, csv) {
+		t.Errorf("Changing the output format changed the keys")
+	}
+	if got := keys("--icsv", "--opprint", "--ofs", ";"); !reflect.DeepEqual(got, csv) {
+		t.Errorf("Changing the output separator changed the keys")
+	}
+	if got := keys("--icsv", "--ifs", ";", "--ocsv"); reflect.DeepEqual(got, csv) {
+		t.Errorf("Changing the input separator kept the keys")
+	}
+	if got := keys("--c2p"); reflect.DeepEqual(got, csv) {
+		t.Errorf("A flag setting the input format as well kept the keys")
+	}
+}
+
+func TestSnapshotReplayAfterOutputFormatChange(t *testing.T) {
+	logged := captureLog(t)
+	app := NewApp()
+	path := writeTestCSV(t, 1200)
+	verbs := []VerbConfig{
+		{Value: "put '$nr = NR'", Enabled: true},
+		{Value: "sort -nr id", Enabled: true},
+		{Value: "head -n 3", Enabled: true},
+	}
+
+	if _, err := app.PreviewFile(path, verbs, "", "--icsv", false, false, ",", "--ocsv"); err != nil {
+		t.Fatalf("PreviewFile failed: %v", err)
+	}
+	logged.Reset()
+	got, err := app.PreviewFile(path, verbs, "", "--icsv", false, false, ",", "--ojson")
+	if err != nil {
+		t.Fatalf("PreviewFile failed: %v", err)
+	}
+	if !strings.Contains(logged.String(), `"skipped_verbs":2`) {
+		t.Errorf("Expected a replay from the snapshots, log: %s", logged.String())
+	}
+
+	want, err := NewApp().PreviewFile(path, verbs, "", "--icsv", false, false, ",", "--ojson")
+	if err != nil {
+		t.Fatalf("PreviewFile failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("Replayed output = %q, want %q", got, want)
+	}
+}
+
+func TestSnapshotSkippedWhenCutShort(t *testing.T) {
+	logged := captureLog(t)
+	app := NewApp()
+	path := writeTestCSV(t, 2000)
+
+	// head stops the reader early, so the stream out of put is incomplete
+	if _, err := app.PreviewFile(path, []VerbConfig{{Value: "put '$nr = NR'", Enabled: true}, {Value: "head -n 2", Enabled: true}}, "", "--icsv", false, false, ",", "--ocsv"); err != nil {
+		t.Fatalf("PreviewFile failed: %v", err)
+	}
+
+	output, err := app.PreviewFile(path, []VerbConfig{{Value: "put '$nr = NR'", Enabled: true}, {Value: "head -n 1500", Enabled: true}}, "", "--icsv", false, false, ",", "--ocsv")
+	if err != nil {
+		t.Fatalf("PreviewFile failed: %v", err)
+	}
+	if strings.Contains(logged.String(), "Replaying pipeline from snapshot") {
+		t.Errorf("Expected no replay from a stream that was cut short")
+	}
+	if lines := strings.Count(output, "\n"); lines != 1501 {
+		t.Errorf("Output has %d lines, want a header and 1500 records", lines)
+	}
+}
+
+func TestSnapshotStore(t *testing.T) {
+	snapshot := func(key string, size int64) *stageSnapshot {
+		return &stageSnapshot{key: key, size: size}
+	}
+	store := newSnapshotStore(30)
+
+	store.put("input1", []*stageSnapshot{snapshot("a", 10), snapshot("b", 10), snapshot("c", 10)})
+	if _, ok := store.get("input2", "a"); ok {
+		t.Errorf("Expected no snapshot for another input")
+	}
+	// a is now the most recently used, so b goes first
+	store.get("input1", "a")
+	store.put("input1", []*stageSnapshot{snapshot("d", 10), snapshot("big", 40)})
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "d": true, "big": false} {
+		if _, ok := store.get("input1", key); ok != want {
+			t.Errorf("Stored(%s) = %v, want %v", key, ok, want)
+		}
+	}
+
+	// Snapshots of a new input replace the old ones
+	store.put("input2", []*stageSnapshot{snapshot("a", 10)})
+	if _, ok := store.get("input1", "c"); ok || len(store.entries) != 1 || store.size != 10 {
+		t.Errorf("Expected only the new input's snapshot, have %d entries of %d bytes", len(store.entries), store.size)
+	}
+}
+
+func TestSnapshotTapBudget(t *testing.T) {
+	record := mlrval.NewMlrmapAsRecord()
+	record.PutReference("a", mlrval.FromString("1"))
+	recordAndContext := types.NewRecordAndContext(record, types.NewContext())
+	size := recordSize(recordAndContext)
+
+	// Two taps share a budget of three records
+	budget := &snapshotBudget{max: 3 * size}
+	_, taps := withSnapshotTaps([]transformers.IRecordTransformer{&recordLimiter{stop: newReaderStop()}, &recordLimiter{stop: newReaderStop()}}, []string{"k1", "k2"}, budget)
+	for i := 0; i < 2; i++ {
+		for _, tap := range taps {
+			tap.Transform(recordAndContext, list.New(), nil, nil)
+		}
+	}
+	for _, tap := range taps {
+		tap.Transform(types.NewEndOfStreamMarker(types.NewContext()), list.New(), nil, nil)
+	}
+
+	if _, ok := taps[0].taken(); !ok {
+		t.Errorf("Expected the first tap's snapshot to fit the budget")
+	}
+	if _, ok := taps[1].taken(); ok {
+		t.Errorf("Expected the second tap to give up once the budget ran out")
+	}
+	if used := budget.used.Load(); used != 2*size {
+		t.Errorf("Budget used = %d, want %d", used, 2*size)
+	}
+}
+
+func TestWithoutLeadingVerbs(t *testing.T) {
+	verbs := []VerbConfig{{Value: "a", Enabled: true}, {Value: "b", Enabled: false}, {Value: "c", Enabled: true}, {Value: "d", Enabled: true}}
+
+	remaining := withoutLeadingVerbs(verbs, 2)
+	if got := enabledVerbIndexes(remaining); len(got) != 1 || got[0] != 3 {
+		t.Errorf("Enabled verbs = %v, want [3]", got)
+	}
+	if !verbs[0].Enabled {
+		t.Errorf("Expected the verbs passed in to be left alone")
+	}
+}
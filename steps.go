@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/johnkerl/miller/v6/pkg/cli"
+	"github.com/johnkerl/miller/v6/pkg/output"
+	"github.com/johnkerl/miller/v6/pkg/transformers"
+	"github.com/johnkerl/miller/v6/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultStepRecords is how many records per step PreviewSteps keeps when no
+// limit is given
+const defaultStepRecords = 100
+
+// StepResult is the output of the pipeline right after one enabled verb
+type StepResult struct {
+	VerbIndex  int    `json:"verbIndex"` // index into Config.Verbs, -1 if unknown
+	Verb       string `json:"verb"`
+	RecordsIn  int64  `json:"recordsIn"`
+	RecordsOut int64  `json:"recordsOut"`
+	Output     string `json:"output"`    // the first records out, in the selected output format
+	Truncated  bool   `json:"truncated"` // true if more records came out than Output shows
+}
+
+// stepTap wraps a transformer, counting the records going in and out of it
+// and keeping copies of the first few records it emits
+type stepTap struct {
+	inner      transformers.IRecordTransformer
+	maxRecords int
+	recordsIn  atomic.Int64
+	recordsOut atomic.Int64
+	captured   []*types.RecordAndContext
+	lastCtx    types.Context
+}
+
+func (s *stepTap) Transform(
+	inrecAndContext *types.RecordAndContext,
+	outputRecordsAndContexts *list.List,
+	inputDownstreamDoneChannel <-chan bool,
+	outputDownstreamDoneChannel chan<- bool,
+) {
+	if !inrecAndContext.EndOfStream {
+		s.recordsIn.Add(1)
+	}
+	s.lastCtx = inrecAndContext.Context
+
+	before := outputRecordsAndContexts.Back()
+	s.inner.Transform(inrecAndContext, outputRecordsAndContexts, inputDownstreamDoneChannel, outputDownstreamDoneChannel)
+
+	for _, outrecAndContext := range appendedRecords(outputRecordsAndContexts, before) {
+		s.recordsOut.Add(1)
+		// Downstream verbs modify records in place, so keep a copy
+		if len(s.captured) < s.maxRecords {
+			s.captured = append(s.captured, types.NewRecordAndContext(outrecAndContext.Record.Copy(), &outrecAndContext.Context))
+		}
+	}
+}
+
+// PreviewSteps runs the pipeline once and returns the output after each
+// enabled verb (at most maxRecords records per step), with record counts in
+// and out, so that the UI can show how the data changes at every stage. The
+// pipeline runs on the sample of the input config.Sampling picks.
+func (a *App) PreviewSteps(config Config, maxRecords int) (_ []StepResult, err error) {
+	defer RecoverToError("PreviewSteps", &err)
+
+	if maxRecords <= 0 {
+		maxRecords = defaultStepRecords
+	}
+
+	ctx, cancel, err := a.beginPreview()
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	LogInfo("PreviewSteps started", logrus.Fields{
+		"input_mode":  config.InputMode,
+		"verbs_count": len(config.Verbs),
+		"max_records": maxRecords,
+	})
+
+	in, mlrOptions, recordTransformers, err := a.sampledPipeline(config)
+	if err != nil {
+		return nil, err
+	}
+	defer in.cleanup()
+
+	taps := make([]*stepTap, len(recordTransformers))
+	tappedTransformers := make([]transformers.IRecordTransformer, len(recordTransformers))
+	for i, recordTransformer := range recordTransformers {
+		taps[i] = &stepTap{inner: recordTransformer, maxRecords: maxRecords}
+		tappedTransformers[i] = taps[i]
+	}
+
+	// The steps carry the output we want; the final output itself isn't needed
+	err = runMillerTransformation(ctx, in.fileNames, mlrOptions, in.chain(tappedTransformers), io.Discard, nil)
+	err = locateVerb(err, config.Verbs)
+	if err != nil {
+		if !errors.Is(err, ErrPreviewCancelled) {
+			LogError(err, "Miller transformation failed", logrus.Fields{"files": in.fileNames})
+		}
+		return nil, err
+	}
+
+	enabledVerbs := enabledVerbIndexes(config.Verbs)
+
+	steps := make([]StepResult, len(taps))
+	for i, tap := range taps {
+		step := StepResult{
+			VerbIndex:  -1,
+			RecordsIn:  tap.recordsIn.Load(),
+			RecordsOut: tap.recordsOut.Load(),
+			Truncated:  tap.recordsOut.Load() > int64(len(tap.captured)),
+		}
+		if i < len(enabledVerbs) {
+			step.VerbIndex = enabledVerbs[i]
+			step.Verb = config.Verbs[enabledVerbs[i]].Value
+		}
+
+		step.Output, err = formatRecords(mlrOptions, tap.captured, &tap.lastCtx)
+		if err != nil {
+			LogError(err, "Failed to format step output", logrus.Fields{"step": i})
+			return nil, err
+		}
+		steps[i] = step
+	}
+
+	LogInfo("PreviewSteps completed", logrus.Fields{"steps": len(steps)})
+	return steps, nil
+}
+
+// formatRecords renders records with the output format in options, the same
+// way the pipeline's writer would
+func formatRecords(options *cli.TOptions, records []*types.RecordAndContext, endContext *types.Context) (string, error) {
+	recordWriter, err := output.Create(&options.WriterOptions)
+	if err != nil {
+		return "", fmt.Errorf("error creating record writer: %v", err)
+	}
+
+	batch := list.New()
+	for _, record := range records {
+		batch.PushBack(record)
+	}
+	batch.PushBack(types.NewEndOfStreamMarker(endContext))
+
+	var outputBuffer bytes.Buffer
+	bufferedOutputStream := bufio.NewWriter(&outputBuffer)
+	writerChannel := make(chan *list.List, 1)
+	doneWritingChannel := make(chan bool, 1)
+	dataProcessingErrorChannel := make(chan bool, 1)
+
+	writerChannel <- batch
+	go output.ChannelWriter(writerChannel, recordWriter, &options.WriterOptions, doneWritingChannel, dataProcessingErrorChannel, bufferedOutputStream, false)
+
+	select {
+	case <-doneWritingChannel:
+	case <-dataProcessingErrorChannel:
+		// Let the writer wind down if it is still waiting for input
+		writerChannel <- types.NewEndOfStreamMarkerList(endContext)
+		return "", errors.New("data processing error")
+	}
+
+	bufferedOutputStream.Flush()
+	return outputBuffer.String(), nil
+}
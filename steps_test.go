@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPreviewSteps(t *testing.T) {
+	app := NewApp()
+	config := Config{
+		InputMode:    "text",
+		InputPath:    "a,b\n1,2\n3,4\n5,6\n",
+		InputFormat:  "--icsv",
+		OutputFormat: "--ocsv",
+		Verbs: []VerbConfig{
+			{Value: "head -n 2", Enabled: true},
+			{Value: "cut -f a", Enabled: false},
+			{Value: "tac", Enabled: true},
+		},
+	}
+
+	steps, err := app.PreviewSteps(config, 1)
+	if err != nil {
+		t.Fatalf("PreviewSteps failed: %v", err)
+	}
+	if len(steps) != 2 {
+		t.Fatalf("Expected one step per enabled verb, got %d", len(steps))
+	}
+
+	if steps[0].VerbIndex != 0 || steps[0].Verb != "head -n 2" {
+		t.Errorf("Step 0 = verb %d %q, want verb 0 %q", steps[0].VerbIndex, steps[0].Verb, "head -n 2")
+	}
+	if steps[0].RecordsOut != 2 {
+		t.Errorf("Step 0 RecordsOut = %d, want 2", steps[0].RecordsOut)
+	}
+
+	// The disabled verb is skipped, so the second step is the third verb
+	if steps[1].VerbIndex != 2 || steps[1].Verb != "tac" {
+		t.Errorf("Step 1 = verb %d %q, want verb 2 %q", steps[1].VerbIndex, steps[1].Verb, "tac")
+	}
+	if steps[1].RecordsIn != 2 || steps[1].RecordsOut != 2 {
+		t.Errorf("Step 1 records in/out = %d/%d, want 2/2", steps[1].RecordsIn, steps[1].RecordsOut)
+	}
+
+	// Only the first record of each step is kept
+	if !steps[1].Truncated {
+		t.Errorf("Expected step 1 output to be truncated to one record")
+	}
+	if steps[1].Output != "a,b\n3,4\n" {
+		t.Errorf("Step 1 output = %q, want %q", steps[1].Output, "a,b\n3,4\n")
+	}
+	if !strings.Contains(steps[0].Output, "1,2") {
+		t.Errorf("Step 0 output should contain the first record, got %q", steps[0].Output)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"github.com/johnkerl/miller/v6/pkg/cli"
+	"github.com/johnkerl/miller/v6/pkg/transformers"
+	"github.com/sirupsen/logrus"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Events emitted while a streaming preview runs
+const (
+	EventPreviewChunk = "preview:chunk"
+	EventPreviewDone  = "preview:done"
+	EventPreviewError = "preview:error"
+)
+
+// Chunks are emitted once this much output is pending, or once this long has
+// passed since the previous chunk, whichever comes first
+const (
+	streamChunkSize     = 256 * 1024
+	streamChunkInterval = 100 * time.Millisecond
+)
+
+// PreviewChunk is the payload of a preview:chunk event
+type PreviewChunk struct {
+	StreamID string `json:"streamId"`
+	Data     string `json:"data"`
+}
+
+// PreviewStreamEnd is the payload of the preview:done and preview:error events
+type PreviewStreamEnd struct {
+	StreamID string `json:"streamId"`
+	Bytes    int64  `json:"bytes"`
+	Error    string `json:"error,omitempty"`
+}
+
+// emit sends an event to the frontend. It is a no-op before startup (e.g. in tests).
+func (a *App) emit(eventName string, data ...interface{}) {
+	if a.ctx == nil {
+		return
+	}
+	runtime.EventsEmit(a.ctx, eventName, data...)
+}
+
+// chunkWriter batches output into chunks and hands each one to emit.
+// The first write is emitted right away so the first rows show up quickly.
+// Each chunk goes out as a JSON string, so a character split between writes
+// is held back until its last byte arrives. The interval is only checked on
+// writes: output pending when a run pauses waits for the next write or the
+// final flush. A timer wouldn't get it out sooner, since the same holds for
+// the buffer Miller's writer fills before this one sees anything.
+type chunkWriter struct {
+	emit     func(chunk string)
+	pending  bytes.Buffer
+	lastEmit time.Time
+	total    int64
+}
+
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	c.pending.Write(p)
+	c.total += int64(len(p))
+	if c.pending.Len() >= streamChunkSize || time.Since(c.lastEmit) >= streamChunkInterval {
+		c.emitReady()
+	}
+	return len(p), nil
+}
+
+// emitReady emits the pending output, bar a character it ends partway through
+func (c *chunkWriter) emitReady() {
+	ready := c.pending.Len() - incompleteRuneTail(c.pending.Bytes())
+	if ready == 0 {
+		return
+	}
+	c.emit(string(c.pending.Next(ready)))
+	c.lastEmit = time.Now()
+}
+
+// Flush emits whatever output is pending
+func (c *chunkWriter) Flush() {
+	if c.pending.Len() == 0 {
+		return
+	}
+	c.emit(c.pending.String())
+	c.pending.Reset()
+	c.lastEmit = time.Now()
+}
+
+// incompleteRuneTail returns how many bytes at the end of p begin a UTF-8
+// character without finishing it
+func incompleteRuneTail(p []byte) int {
+	for i := 1; i < utf8.UTFMax && i <= len(p); i++ {
+		if tail := p[len(p)-i:]; utf8.RuneStart(tail[0]) {
+			if utf8.FullRune(tail) {
+				return 0
+			}
+			return i
+		}
+	}
+	return 0
+}
+
+// PreviewStream executes the mlr transformation on text input like Preview,
+// but emits the output as preview:chunk events while it is produced, followed
+// by a preview:done or preview:error event. streamID is echoed in every event.
+func (a *App) PreviewStream(streamID string, input string, verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string) (err error) {
+	defer RecoverToError("PreviewStream", &err)
+
+	ctx, cancel, err := a.beginPreview()
+	if err != nil {
+		a.emit(EventPreviewError, PreviewStreamEnd{StreamID: streamID, Error: err.Error()})
+		return err
+	}
+	defer cancel()
+
+	LogInfo("PreviewStream transformation started", logrus.Fields{
+		"stream_id":     streamID,
+		"input_format":  inputFormat,
+		"output_format": outputFormat,
+		"verbs_count":   len(verbs),
+		"input_size":    len(input),
+	})
+
+	mlrOptions, recordTransformers, err := a.buildPipeline(verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat, "")
+	if err != nil {
+		a.emit(EventPreviewError, PreviewStreamEnd{StreamID: streamID, Error: err.Error()})
+		return err
+	}
+
+	inputName, closeInput, err := memoryInput(input)
+	if err != nil {
+		a.emit(EventPreviewError, PreviewStreamEnd{StreamID: streamID, Error: err.Error()})
+		return err
+	}
+	defer closeInput()
+
+	return a.runStream(ctx, streamID, []string{inputName}, mlrOptions, recordTransformers, verbs)
+}
+
+// PreviewFileStream executes the mlr transformation on a file like PreviewFile,
+// streaming the output the same way as PreviewStream
+func (a *App) PreviewFileStream(streamID string, filePath string, verbs []VerbConfig, options string, inputFormat string, ragged bool, headerless bool, fieldSeparator string, outputFormat string) (err error) {
+	defer RecoverToError("PreviewFileStream", &err)
+
+	ctx, cancel, err := a.beginPreview()
+	if err != nil {
+		a.emit(EventPreviewError, PreviewStreamEnd{StreamID: streamID, Error: err.Error()})
+		return err
+	}
+	defer cancel()
+
+	LogInfo("PreviewFileStream transformation started", logrus.Fields{
+		"stream_id":     streamID,
+		"file_path":     filePath,
+		"input_format":  inputFormat,
+		"output_format": outputFormat,
+		"verbs_count":   len(verbs),
+	})
+
+	filePaths, err := expandInputPaths([]string{filePath})
+	if err != nil {
+		a.emit(EventPreviewError, PreviewStreamEnd{StreamID: streamID, Error: err.Error()})
+		return err
+	}
+	compression, err := filesCompression(filePaths)
+	if err != nil {
+		a.emit(EventPreviewError, PreviewStreamEnd{StreamID: streamID, Error: err.Error()})
+		return err
+	}
+
+	mlrOptions, recordTransformers, err := a.buildPipeline(verbs, options, inputFormat, ragged, headerless, fieldSeparator, outputFormat, compression)
+	if err != nil {
+		a.emit(EventPreviewError, PreviewStreamEnd{StreamID: streamID, Error: err.Error()})
+		return err
+	}
+
+	return a.runStream(ctx, streamID, filePaths, mlrOptions, recordTransformers, verbs)
+}
+
+// runStream runs the pipeline with its output going out as preview:chunk
+// events, and finishes with a preview:done or preview:error event
+func (a *App) runStream(ctx context.Context, streamID string, fileNames []string, mlrOptions *cli.TOptions, recordTransformers []transformers.IRecordTransformer, verbs []VerbConfig) error {
+	writer := &chunkWriter{emit: func(chunk string) {
+		a.emit(EventPreviewChunk, PreviewChunk{StreamID: streamID, Data: chunk})
+	}}
+
+	err := runMillerTransformation(ctx, fileNames, mlrOptions, recordTransformers, writer, nil)
+	err = locateVerb(err, verbs)
+	if err != nil {
+		if errors.Is(err, ErrPreviewCancelled) {
+			LogInfo("Streaming transformation cancelled", logrus.Fields{"stream_id": streamID})
+		} else {
+			LogError(err, "Miller transformation failed", logrus.Fields{"stream_id": streamID, "files": fileNames})
+		}
+		a.emit(EventPreviewError, PreviewStreamEnd{StreamID: streamID, Bytes: writer.total, Error: err.Error()})
+		return err
+	}
+
+	writer.Flush()
+	a.emit(EventPreviewDone, PreviewStreamEnd{StreamID: streamID, Bytes: writer.total})
+
+	LogInfo("Streaming transformation completed", logrus.Fields{
+		"stream_id":   streamID,
+		"output_size": writer.total,
+	})
+	return nil
+}
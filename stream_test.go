@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestChunkWriter(t *testing.T) {
+	var chunks []string
+	writer := &chunkWriter{emit: func(chunk string) { chunks = append(chunks, chunk) }}
+
+	writer.Write([]byte("a,b\n"))
+	if len(chunks) != 1 {
+		t.Fatalf("Expected the first write to be emitted right away, got %d chunks", len(chunks))
+	}
+
+	// Writes in quick succession are batched until flushed
+	writer.lastEmit = time.Now()
+	writer.Write([]byte("1,2\n"))
+	writer.Write([]byte("3,4\n"))
+	if len(chunks) != 1 {
+		t.Errorf("Expected quick writes to be batched, got %d chunks", len(chunks))
+	}
+
+	writer.Flush()
+	if got := strings.Join(chunks, ""); got != "a,b\n1,2\n3,4\n" {
+		t.Errorf("Chunks don't add up to the output written, got: %q", got)
+	}
+	if writer.total != int64(len("a,b\n1,2\n3,4\n")) {
+		t.Errorf("total = %d, want %d", writer.total, len("a,b\n1,2\n3,4\n"))
+	}
+
+	// Large writes are emitted without waiting for the interval
+	writer.lastEmit = time.Now()
+	writer.Write(make([]byte, streamChunkSize))
+	if len(chunks) != 3 {
+		t.Errorf("Expected a full chunk to be emitted immediately, got %d chunks", len(chunks))
+	}
+}
+
+func TestChunkWriterSplitCharacter(t *testing.T) {
+	var chunks []string
+	writer := &chunkWriter{emit: func(chunk string) { chunks = append(chunks, chunk) }}
+	input := "name\ncafé\n"
+	split := strings.Index(input, "é") + 1
+
+	// The first write ends halfway through é, and the second is emitted as
+	// soon as it comes
+	writer.Write([]byte(input[:split]))
+	writer.lastEmit = time.Time{}
+	writer.Write([]byte(input[split:]))
+	writer.Flush()
+
+	for _, chunk := range chunks {
+		if !utf8.ValidString(chunk) {
+			t.Errorf("Chunk %q is not valid UTF-8", chunk)
+		}
+	}
+	if got := strings.Join(chunks, ""); got != input {
+		t.Errorf("Chunks add up to %q, want %q", got, input)
+	}
+	if len(chunks) != 2 {
+		t.Errorf("Expected 2 chunks, got %q", chunks)
+	}
+}
+
+func TestPreviewStream(t *testing.T) {
+	app := NewApp()
+	verbs := []VerbConfig{{Value: "cat", Enabled: true}}
+
+	if err := app.PreviewStream("test", "a,b\n1,2\n", verbs, "", "--icsv", false, false, ",", "--ojson"); err != nil {
+		t.Errorf("PreviewStream failed: %v", err)
+	}
+	if err := app.PreviewStream("test", "a,b\n1,2\n", verbs, "'unterminated", "--icsv", false, false, ",", "--ojson"); err == nil {
+		t.Errorf("Expected PreviewStream to fail for unparseable options")
+	}
+}